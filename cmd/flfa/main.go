@@ -25,7 +25,12 @@ import (
 	"context"
 
 	"github.com/FlagrantGarden/flfa/cmd/flfa/editor"
+	modulecmd "github.com/FlagrantGarden/flfa/cmd/flfa/module"
+	"github.com/FlagrantGarden/flfa/cmd/flfa/odds"
 	"github.com/FlagrantGarden/flfa/cmd/flfa/play"
+	"github.com/FlagrantGarden/flfa/cmd/flfa/replay"
+	"github.com/FlagrantGarden/flfa/cmd/flfa/simulate"
+	"github.com/FlagrantGarden/flfa/cmd/flfa/skirmish"
 	"github.com/FlagrantGarden/flfa/docs"
 	"github.com/FlagrantGarden/flfa/emfs"
 	"github.com/FlagrantGarden/flfa/pkg/flfa"
@@ -34,6 +39,7 @@ import (
 	"github.com/FlagrantGarden/flfa/pkg/tympan/forme/explain"
 	"github.com/FlagrantGarden/flfa/pkg/tympan/forme/root"
 	"github.com/FlagrantGarden/flfa/pkg/tympan/forme/version"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/module"
 	"github.com/FlagrantGarden/flfa/pkg/tympan/telemetry"
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
@@ -93,6 +99,12 @@ func main() {
 		BuildDate: date,
 		Commit:    commit,
 		Metadata:  api.Tympan.Metadata,
+		Modules: func() ([]module.Manifest, error) {
+			if err := api.InitializeGameState(); err != nil {
+				return nil, err
+			}
+			return api.Cache.Modules, nil
+		},
 	}
 	root_cmd.AddCommand(version_cmder.CreateCommand())
 
@@ -114,6 +126,41 @@ func main() {
 	play_cmd := play_cmder.CreateCommand()
 	root_cmd.AddCommand(play_cmd)
 
+	// flfa replay
+	replay_cmder := replay.ReplayCommand{
+		Api: api,
+	}
+	replay_cmd := replay_cmder.CreateCommand()
+	root_cmd.AddCommand(replay_cmd)
+
+	// flfa skirmish
+	skirmish_cmder := skirmish.SkirmishCommand{
+		Api: api,
+	}
+	skirmish_cmd := skirmish_cmder.CreateCommand()
+	root_cmd.AddCommand(skirmish_cmd)
+
+	// flfa simulate
+	simulate_cmder := simulate.SimulateCommand{
+		Api: api,
+	}
+	simulate_cmd := simulate_cmder.CreateCommand()
+	root_cmd.AddCommand(simulate_cmd)
+
+	// flfa odds
+	odds_cmder := odds.OddsCommand{
+		Api: api,
+	}
+	odds_cmd := odds_cmder.CreateCommand()
+	root_cmd.AddCommand(odds_cmd)
+
+	// flfa module
+	module_cmder := modulecmd.ModuleCommand{
+		Api: api,
+	}
+	module_cmd := module_cmder.CreateCommand()
+	root_cmd.AddCommand(module_cmd)
+
 	// flfa editor
 
 	editor_cmder := editor.EditorCommand{
@@ -0,0 +1,213 @@
+package module
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type ModuleCommand struct {
+	Api *flfa.Api
+}
+
+type ModuleCommander interface {
+	CreateCommand() *cobra.Command
+}
+
+func (m *ModuleCommand) CreateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "module",
+		Short:             "Manage your modules",
+		Long:              "Install, list, update, remove, and lint the modules which provide profiles, traits, companies, and more, and print the schemas of their files",
+		PersistentPreRunE: m.initialize,
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "install <path>",
+		Short: "Install a module",
+		Long:  "Install the module in a folder or a .zip, .tar, .tar.gz, or .tgz archive after making sure it has a valid manifest and the modules it requires are installed",
+		Args:  cobra.ExactArgs(1),
+		RunE:  m.executeInstall,
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the available modules",
+		Long:  "List every module with its id, version, where it is loaded from, and whether it is enabled; modules named in disabled_modules in the configuration are not loaded",
+		Args:  cobra.NoArgs,
+		RunE:  m.executeList,
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "update <path>",
+		Short: "Update an installed module",
+		Long:  "Replace an installed module with the module in a folder or archive, as long as every installed module's requirements are still met",
+		Args:  cobra.ExactArgs(1),
+		RunE:  m.executeUpdate,
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "remove <id>",
+		Short: "Remove an installed module",
+		Long:  "Remove an installed module unless another module requires it; removing an installed core module restores the built-in one",
+		Args:  cobra.ExactArgs(1),
+		RunE:  m.executeRemove,
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "lint <path|id>",
+		Short: "Check a module for problems",
+		Long:  "Load a module from a folder, an archive, or the id of an available module alongside only the modules it requires and report every problem in its manifest, data, and scripts with the file and line it is on",
+		Args:  cobra.ExactArgs(1),
+		RunE:  m.executeLint,
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "schema [type]",
+		Short: "Print the JSON Schema for a module file",
+		Long:  "Print the JSON Schema for one type of module data, such as profile, trait, spell, or company, so editors can validate and autocomplete module files; without a type, list the types which have one",
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  m.executeSchema,
+	})
+
+	return cmd
+}
+
+// Managing modules only needs the configuration; loading them would fail on the very problems these commands fix.
+func (m *ModuleCommand) initialize(cmd *cobra.Command, args []string) error {
+	return m.Api.Tympan.InitializeConfig()
+}
+
+func (m *ModuleCommand) executeInstall(cmd *cobra.Command, args []string) error {
+	manifest, err := m.Api.InstallModule(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Installed %s.\n", manifest)
+	return nil
+}
+
+func (m *ModuleCommand) executeUpdate(cmd *cobra.Command, args []string) error {
+	manifest, err := m.Api.UpdateModule(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Updated %s.\n", manifest)
+	return nil
+}
+
+func (m *ModuleCommand) executeRemove(cmd *cobra.Command, args []string) error {
+	if err := m.Api.RemoveModule(args[0]); err != nil {
+		return err
+	}
+	fmt.Printf("Removed module '%s'.\n", args[0])
+	return nil
+}
+
+type issueEntry struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+func (m *ModuleCommand) executeLint(cmd *cobra.Command, args []string) error {
+	issues, err := m.Api.LintModule(args[0])
+	if err != nil {
+		return err
+	}
+
+	if strings.ToLower(viper.GetString("format")) == "json" {
+		entries := []issueEntry{}
+		for _, issue := range issues {
+			entries = append(entries, issueEntry{File: issue.File, Line: issue.Line, Column: issue.Column, Message: issue.Message})
+		}
+		output, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(output))
+	} else if len(issues) == 0 {
+		fmt.Printf("No issues found in module '%s'.\n", args[0])
+	} else {
+		for _, issue := range issues {
+			fmt.Println(issue)
+		}
+	}
+
+	// Failing when there are issues lets the command guard a module's releases
+	if len(issues) > 0 {
+		return fmt.Errorf("found %d issues in module '%s'", len(issues), args[0])
+	}
+	return nil
+}
+
+func (m *ModuleCommand) executeSchema(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		var table strings.Builder
+		table.WriteString(fmt.Sprintf("%-12s %s\n", "Type", "Describes"))
+		for _, moduleSchema := range flfa.ModuleSchemas() {
+			table.WriteString(fmt.Sprintf("%-12s %s\n", moduleSchema.Name, moduleSchema.File))
+		}
+		fmt.Print(table.String())
+		return nil
+	}
+
+	moduleSchema, err := flfa.GetModuleSchema(args[0])
+	if err != nil {
+		return err
+	}
+	output, err := json.MarshalIndent(moduleSchema.Schema(), "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(output))
+	return nil
+}
+
+type moduleEntry struct {
+	Id      string `json:"id"`
+	Display string `json:"display"`
+	Version string `json:"version"`
+	Author  string `json:"author"`
+	Source  string `json:"source"`
+	Enabled bool   `json:"enabled"`
+}
+
+func (m *ModuleCommand) executeList(cmd *cobra.Command, args []string) error {
+	entries := []moduleEntry{}
+	for _, listing := range m.Api.ListModules() {
+		source := listing.Path
+		if listing.Embedded {
+			source = "built-in"
+		}
+		entries = append(entries, moduleEntry{
+			Id:      listing.Manifest.Id,
+			Display: listing.Manifest.Display,
+			Version: listing.Manifest.Version,
+			Author:  listing.Manifest.Author,
+			Source:  source,
+			Enabled: listing.Enabled,
+		})
+	}
+
+	if strings.ToLower(viper.GetString("format")) == "json" {
+		output, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(output))
+		return nil
+	}
+
+	var table strings.Builder
+	table.WriteString(fmt.Sprintf("%-16s %-12s %-8s %s\n", "Id", "Version", "Enabled", "Source"))
+	for _, entry := range entries {
+		enabled := "no"
+		if entry.Enabled {
+			enabled = "yes"
+		}
+		table.WriteString(fmt.Sprintf("%-16s %-12s %-8s %s\n", entry.Id, entry.Version, enabled, entry.Source))
+	}
+	fmt.Print(table.String())
+	return nil
+}
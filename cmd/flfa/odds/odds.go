@@ -0,0 +1,92 @@
+package odds
+
+import (
+	"fmt"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/state/player"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/state/skirmish"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/state/persona"
+	"github.com/spf13/cobra"
+)
+
+type OddsCommand struct {
+	Api     *flfa.Api
+	Persona string
+	TraitsA []string
+	TraitsB []string
+}
+
+type OddsCommander interface {
+	CreateCommand() *cobra.Command
+}
+
+func (o *OddsCommand) CreateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "odds <groupA> <groupB>",
+		Short: "Work out the odds of one group fighting another",
+		Long: "Work out the exact chances of each group activating, the hits and losses inflicted and received in melee " +
+			"and shooting, and the resolve tests forced when each group fights the other. A group is either the name " +
+			"of a profile or Company/Group for a group in one of your companies or those from modules.",
+		Args:              cobra.ExactArgs(2),
+		PersistentPreRunE: o.initialize,
+		RunE:              o.execute,
+	}
+	cmd.Flags().SortFlags = false
+	cmd.Flags().StringArrayVar(&o.TraitsA, "a-trait", []string{}, "a special trait to add to the first group before working out the odds")
+	cmd.Flags().StringArrayVar(&o.TraitsB, "b-trait", []string{}, "a special trait to add to the second group before working out the odds")
+	cmd.Flags().StringVarP(&o.Persona, "persona", "p", "", "a persona whose companies' groups may be compared; defaults to the active persona")
+
+	return cmd
+}
+
+func (o *OddsCommand) initialize(cmd *cobra.Command, args []string) error {
+	return o.Api.InitializeGameState()
+}
+
+// userPersona returns the persona to look for groups with, if there is one; profiles and the groups from modules can
+// be compared without one.
+func (o *OddsCommand) userPersona() (*persona.Persona[player.Data, player.Settings], error) {
+	personaName := o.Persona
+	if personaName == "" {
+		personaName = o.Api.Tympan.Configuration.ActiveUserPersona
+	}
+	if personaName == "" {
+		return nil, nil
+	}
+
+	foundPlayer, err := o.Api.GetPlayer(personaName, "")
+	if err != nil {
+		if o.Persona == "" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to load persona '%s': %s", personaName, err)
+	}
+	return foundPlayer.Persona, nil
+}
+
+func (o *OddsCommand) execute(cmd *cobra.Command, args []string) error {
+	userPersona, err := o.userPersona()
+	if err != nil {
+		return err
+	}
+
+	a, err := o.Api.FindGroup(args[0], userPersona)
+	if err != nil {
+		return err
+	}
+	if a, err = o.Api.AddTraits(a, o.TraitsA...); err != nil {
+		return err
+	}
+	b, err := o.Api.FindGroup(args[1], userPersona)
+	if err != nil {
+		return err
+	}
+	if b, err = o.Api.AddTraits(b, o.TraitsB...); err != nil {
+		return err
+	}
+
+	fmt.Println(skirmish.CalculateOdds(a, b).String())
+	fmt.Print(skirmish.CalculateOdds(b, a).String())
+	return nil
+}
@@ -5,16 +5,23 @@ import (
 	"os"
 
 	"github.com/FlagrantGarden/flfa/pkg/flfa"
-	"github.com/FlagrantGarden/flfa/pkg/flfa/tui/company"
-	"github.com/FlagrantGarden/flfa/pkg/flfa/tui/persona"
+	playerstate "github.com/FlagrantGarden/flfa/pkg/flfa/state/player"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/state/skirmish"
+	playtui "github.com/FlagrantGarden/flfa/pkg/flfa/tui/play"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/tui/player"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/tui/seating"
 	"github.com/FlagrantGarden/flfa/pkg/tympan/dossier"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/state/instance"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/state/persona"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/spf13/cobra"
 )
 
 type PlayCommand struct {
-	Api     *flfa.Api
-	Dossier *dossier.Dossier
+	Api      *flfa.Api
+	Dossier  *dossier.Dossier
+	HotSeat  bool
+	Skirmish string
 }
 
 type PlayCommander interface {
@@ -25,7 +32,7 @@ func (p *PlayCommand) CreateCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:               "play",
 		Short:             "Play the game",
-		Long:              "Play the game",
+		Long:              "Play the active skirmish for a persona, resuming it where it was left off",
 		PersistentPreRunE: p.initialize,
 		// Args: handleArgs,
 		// ValidArgsFunction: flagCompletion,
@@ -33,6 +40,8 @@ func (p *PlayCommand) CreateCommand() *cobra.Command {
 		RunE: p.execute,
 	}
 	cmd.Flags().SortFlags = false
+	cmd.Flags().BoolVar(&p.HotSeat, "hot-seat", false, "start a new skirmish for two players sharing this terminal, each with their own persona and company")
+	cmd.Flags().StringVarP(&p.Skirmish, "skirmish", "s", "hot-seat", "the name to save a hot-seat skirmish as, for the first player's persona")
 	// cmd.Flags.BoolVarP(&p.List)
 	return cmd
 }
@@ -42,68 +51,83 @@ func (p *PlayCommand) initialize(cmd *cobra.Command, args []string) error {
 }
 
 func (p *PlayCommand) execute(cmd *cobra.Command, args []string) error {
-	personaModel := persona.NewModel(p.Api)
-	personaProgram := tea.NewProgram(personaModel)
-	if err := personaProgram.Start(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v", err)
-		os.Exit(1)
+	if p.HotSeat {
+		return p.executeHotSeat()
 	}
 
-	// c := p.Api.Cache.Companies[0]
-	// fmt.Print(data.DisplayGroupTerminal(c.Groups...))
-	// groupModel := group.NewModel(p.Api)
-	// groupProgram := tea.NewProgram(groupModel, tea.WithAltScreen())
-	// if err := groupProgram.Start(); err != nil {
-	// 	fmt.Fprintf(os.Stderr, "Error: %v", err)
-	// 	os.Exit(1)
-	// }
-
-	companyModel := company.NewModel(p.Api)
-	companyProgram := tea.NewProgram(companyModel, tea.WithAltScreen())
-	if err := companyProgram.Start(); err != nil {
+	playerModel := player.NewModel(p.Api)
+	playerProgram := tea.NewProgram(playerModel)
+	if err := playerProgram.Start(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v", err)
 		os.Exit(1)
 	}
+	if playerModel.Player == nil || playerModel.Persona == nil {
+		return nil
+	}
+	userPersona := playerModel.Persona
+
+	entry, ok := userPersona.Settings.Skirmish(userPersona.Settings.ActiveSkirmish)
+	if !ok || entry.Archived {
+		fmt.Printf("%s has no active skirmish; start one with flfa skirmish new or pick one with flfa skirmish switch.\n", userPersona.Name)
+		return nil
+	}
+	saved, err := p.Api.GetActiveSkirmish(userPersona, "")
+	if err != nil {
+		fmt.Printf("Skirmish '%s' has not been set up yet; start it with flfa skirmish new.\n", entry.Name)
+		return nil
+	}
+
+	return p.play(saved, userPersona, entry.Configuration.Autosave)
+}
 
-	// if p.Api.Tympan.Configuration.ActiveUserPersona == "" {
-	// 	err := prompts.FirstPlay(p.Api)
-	// 	if err != nil {
-	// 		log.Logger.Error().Msgf("Something broke: %s", err)
-	// 	}
-	// } else {
-	// 	// keep going!
-	// 	activeUserPersona, err := p.Api.GetUserPersona(p.Api.Tympan.Configuration.ActiveUserPersona, "")
-	// 	if err != nil {
-	// 		return fmt.Errorf("could not load active user persona '%s': %s", p.Api.Tympan.Configuration.ActiveUserPersona, err)
-	// 	}
+// In a hot-seat skirmish, the players each choose their persona and company; the skirmish is saved for the first
+// player's persona and the terminal is handed between them as play passes from one company to the other.
+func (p *PlayCommand) executeHotSeat() error {
+	if len(p.Api.Cache.Players) < 2 {
+		return fmt.Errorf("a hot-seat skirmish needs a persona for each player; create them with flfa play first")
+	}
+	seatingModel := seating.NewModel(p.Api)
+	seatingProgram := tea.NewProgram(seatingModel, tea.WithAltScreen())
+	if err := seatingProgram.Start(); err != nil {
+		return err
+	}
+	if !seatingModel.Seated() {
+		return nil
+	}
 
-	// 	activeSkirmish, err := p.Api.GetActiveSkirmish(activeUserPersona, "")
-	// 	if err != nil {
-	// 		return fmt.Errorf("could not load active skirmish '%s': %s", activeUserPersona.Settings.ActiveSkirmish, err)
-	// 	}
-	// 	fmt.Printf("Congrats! You're playing a skirmish! This one:\n%+v", activeSkirmish)
-	// 	// wire up so you _actually_ play.
-	// }
+	game := skirmish.Skirmish{Players: make(map[string]string)}
+	for _, seat := range seatingModel.Seats {
+		game.Companies = append(game.Companies, seat.Company)
+		game.Players[seat.Company.Name] = seat.Player.Name
+	}
+	host := seatingModel.Seats[0].Player.Persona
+	saved, err := p.Api.CreateSkirmish(p.Skirmish, game, playerstate.SkirmishConfiguration{Autosave: true}, host, "")
+	if err != nil {
+		return err
+	}
+	return p.play(saved, host, true)
+}
 
-	// Turn off for now
-	// group, err := prompts.NewGroup(p.Api.CachedProfiles, p.Api.CachedTraits)
-	// if err != nil {
-	// 	return err
-	// }
+func (p *PlayCommand) play(saved *instance.Instance[skirmish.Skirmish], userPersona *persona.Persona[playerstate.Data, playerstate.Settings], autosave bool) error {
+	save := func(game *skirmish.Skirmish) error {
+		saved.Data = *game
+		return saved.Save(p.Api.Tympan.AFS)
+	}
+	model := playtui.NewModel(
+		p.Api,
+		saved.Name,
+		&saved.Data,
+		playtui.WithEngineOptions(p.Api.SkirmishAutosave(saved, userPersona)),
+		playtui.WithSaver(save),
+	)
+	program := tea.NewProgram(model, tea.WithAltScreen())
+	if err := program.Start(); err != nil {
+		return err
+	}
 
-	// switch viper.GetString("format") {
-	// case "json":
-	// 	fmt.Print(group.JSON())
-	// default:
-	// 	groupOutput := strings.Builder{}
-	// 	groupOutput.WriteString("| Name | Profile | Melee | Missile | Move | FS | R | T | Traits |\n")
-	// 	groupOutput.WriteString("| ---- | ------- | ----- | ------- | ---- | -- | - | - | ------ |\n")
-	// 	groupOutput.WriteString(group.MarkdownTableEntry())
-	// 	output, err := p.Dossier.Render(groupOutput.String())
-	// 	if err != nil {
-	// 		return err
-	// 	}
-	// 	fmt.Print(output)
-	// }
+	// Starting a skirmish is not an update, so autosaving only catches it once the players quit.
+	if autosave {
+		return save(model.Engine.Skirmish)
+	}
 	return nil
 }
@@ -0,0 +1,70 @@
+package replay
+
+import (
+	"fmt"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/state/skirmish"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/tui/replay"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/cobra"
+)
+
+type ReplayCommand struct {
+	Api     *flfa.Api
+	Persona string
+	Turn    int
+}
+
+type ReplayCommander interface {
+	CreateCommand() *cobra.Command
+}
+
+func (r *ReplayCommand) CreateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "replay <skirmish>",
+		Short:             "Step through a saved skirmish",
+		Long:              "Step through a saved skirmish turn by turn, seeing every roll made and every trait triggered along the way",
+		Args:              cobra.ExactArgs(1),
+		PersistentPreRunE: r.initialize,
+		RunE:              r.execute,
+	}
+
+	cmd.Flags().SortFlags = false
+	cmd.Flags().StringVarP(&r.Persona, "persona", "p", "", "the persona who played the skirmish; defaults to the active persona")
+	cmd.Flags().IntVarP(&r.Turn, "turn", "t", 0, "the turn to start the replay at")
+
+	return cmd
+}
+
+func (r *ReplayCommand) initialize(cmd *cobra.Command, args []string) error {
+	return r.Api.InitializeGameState()
+}
+
+func (r *ReplayCommand) execute(cmd *cobra.Command, args []string) error {
+	personaName := r.Persona
+	if personaName == "" {
+		personaName = r.Api.Tympan.Configuration.ActiveUserPersona
+	}
+	if personaName == "" {
+		return fmt.Errorf("no persona specified and there is no active persona; pass one with --persona")
+	}
+
+	player, err := r.Api.GetPlayer(personaName, "")
+	if err != nil {
+		return fmt.Errorf("unable to load persona '%s': %s", personaName, err)
+	}
+	saved, err := r.Api.GetSkirmish(args[0], player.Persona, "")
+	if err != nil {
+		return fmt.Errorf("unable to load skirmish '%s': %s", args[0], err)
+	}
+
+	skirmishReplay, err := skirmish.NewReplay(saved.Data, r.Api.SkirmishOptions(saved.Data.Scenario)...)
+	if err != nil {
+		return err
+	}
+
+	model := replay.NewModel(r.Api, saved.Name, skirmishReplay, replay.WithTurn(r.Turn))
+	program := tea.NewProgram(model, tea.WithAltScreen())
+	return program.Start()
+}
@@ -0,0 +1,171 @@
+package simulate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/state/player"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/state/skirmish"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/dice"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/state/persona"
+	"github.com/spf13/cobra"
+)
+
+type SimulateCommand struct {
+	Api      *flfa.Api
+	Persona  string
+	A        string
+	B        string
+	Runs     int
+	Scenario string
+	Seed     int64
+	Turns    int
+	Top      int
+}
+
+type SimulateCommander interface {
+	CreateCommand() *cobra.Command
+}
+
+func (s *SimulateCommand) CreateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "simulate",
+		Short: "Play two companies against each other many times",
+		Long: "Play two companies against each other many times with the computer controlling both, then report how often " +
+			"each won, how long the skirmishes lasted, what each group lost, and which traits triggered most",
+		Args:              cobra.NoArgs,
+		PersistentPreRunE: s.initialize,
+		RunE:              s.execute,
+	}
+	cmd.Flags().SortFlags = false
+	cmd.Flags().StringVar(&s.A, "a", "", "the first company")
+	cmd.Flags().StringVar(&s.B, "b", "", "the second company")
+	cmd.Flags().IntVar(&s.Runs, "runs", 1000, "how many skirmishes to play")
+	cmd.Flags().StringVarP(&s.Scenario, "scenario", "s", "", "the scenario to play the skirmishes in")
+	cmd.Flags().IntVar(&s.Turns, "turns", 12, "the turn after which a skirmish is a draw, unless the scenario sets its own")
+	cmd.Flags().Int64Var(&s.Seed, "seed", 0, "the seed for the first skirmish's dice, each later one using the next; random if not specified")
+	cmd.Flags().IntVar(&s.Top, "top", 10, "how many of the most triggered traits to report")
+	cmd.Flags().StringVarP(&s.Persona, "persona", "p", "", "a persona whose companies may be simulated; defaults to the active persona")
+	cmd.MarkFlagRequired("a")
+	cmd.MarkFlagRequired("b")
+
+	return cmd
+}
+
+// The seed is chosen before the modules are loaded, as the Captains of their companies are rolled for as they are
+// loaded; rolling them from the seed as well means the same seed always simulates the same companies.
+func (s *SimulateCommand) initialize(cmd *cobra.Command, args []string) error {
+	if s.Seed == 0 {
+		s.Seed = dice.NewRoller(0).Seed
+	}
+	s.Api.Seed = s.Seed
+	return s.Api.InitializeGameState()
+}
+
+// player returns the persona to look for companies with, if there is one; the companies from modules can be simulated
+// without one.
+func (s *SimulateCommand) player() (*player.Player, error) {
+	personaName := s.Persona
+	if personaName == "" {
+		personaName = s.Api.Tympan.Configuration.ActiveUserPersona
+	}
+	if personaName == "" {
+		return nil, nil
+	}
+
+	foundPlayer, err := s.Api.GetPlayer(personaName, "")
+	if err != nil {
+		if s.Persona == "" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to load persona '%s': %s", personaName, err)
+	}
+	return &foundPlayer, nil
+}
+
+func (s *SimulateCommand) execute(cmd *cobra.Command, args []string) error {
+	if s.Runs < 1 {
+		return fmt.Errorf("unable to simulate fewer than one run")
+	}
+	if s.Scenario != "" && data.GetScenarioByName(s.Scenario, s.Api.Cache.Scenarios).Name == "" {
+		return fmt.Errorf("unable to find scenario '%s' in any module", s.Scenario)
+	}
+	userPlayer, err := s.player()
+	if err != nil {
+		return err
+	}
+	var userPersona *persona.Persona[player.Data, player.Settings]
+	if userPlayer != nil {
+		userPersona = userPlayer.Persona
+	}
+
+	companies := []data.Company{}
+	for _, name := range []string{s.A, s.B} {
+		company, err := s.Api.FindCompany(name, userPersona)
+		if err != nil {
+			return err
+		}
+		companies = append(companies, company)
+	}
+	// A company simulated against itself needs a different name to tell the two apart
+	if companies[0].Name == companies[1].Name {
+		companies[1].Name = fmt.Sprintf("%s (B)", companies[1].Name)
+	}
+
+	// The scenario's turn limit, if it has one, replaces the default
+	options := append([]skirmish.Option{skirmish.WithMaximumTurns(s.Turns)}, s.Api.SkirmishOptions(s.Scenario)...)
+	matchup, err := skirmish.Simulate(companies, s.Runs, s.Seed, options...)
+	if err != nil {
+		return err
+	}
+	fmt.Print(report(matchup, s.Top))
+	return nil
+}
+
+func report(matchup *skirmish.Matchup, top int) string {
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("Simulated %d runs from seed %d", matchup.Runs, matchup.Seed))
+	if matchup.Unfinished > 0 {
+		output.WriteString(fmt.Sprintf("; %d could not be finished and are not counted", matchup.Unfinished))
+	}
+	output.WriteString("\n\n")
+	if matchup.Finished() == 0 {
+		return output.String()
+	}
+
+	output.WriteString(fmt.Sprintf("%-32s %8s\n", "Result", "Rate"))
+	for _, company := range matchup.Companies {
+		output.WriteString(fmt.Sprintf("%-32s %7.1f%%\n", company+" wins", matchup.WinRate(company)*100))
+	}
+	output.WriteString(fmt.Sprintf("%-32s %7.1f%%\n", "Draws", matchup.DrawRate()*100))
+	output.WriteString(fmt.Sprintf("\nAverage length: %.1f turns\n\n", matchup.AverageTurns()))
+
+	output.WriteString(fmt.Sprintf("%-24s %-24s %8s %8s\n", "Company", "Group", "Avg Lost", "Removed"))
+	for _, casualties := range matchup.Casualties {
+		output.WriteString(fmt.Sprintf(
+			"%-24s %-24s %8.2f %7.1f%%\n",
+			casualties.Company,
+			casualties.Group,
+			matchup.AverageLost(casualties),
+			matchup.RemovedRate(casualties)*100,
+		))
+	}
+
+	triggers := matchup.TopTriggers(top)
+	if len(triggers) == 0 {
+		output.WriteString("\nNo traits triggered.\n")
+		return output.String()
+	}
+	output.WriteString(fmt.Sprintf("\n%-32s %8s %10s\n", "Trait", "Triggers", "Per Run"))
+	for _, trigger := range triggers {
+		output.WriteString(fmt.Sprintf(
+			"%-32s %8d %10.2f\n",
+			trigger.Trait,
+			trigger.Count,
+			float64(trigger.Count)/float64(matchup.Finished()),
+		))
+	}
+	return output.String()
+}
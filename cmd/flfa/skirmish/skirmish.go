@@ -0,0 +1,230 @@
+package skirmish
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/state/player"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/state/skirmish"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/utils"
+	"github.com/spf13/cobra"
+)
+
+type SkirmishCommand struct {
+	Api       *flfa.Api
+	Persona   string
+	Scenario  string
+	Companies []string
+	Agents    []string
+	Autosave  bool
+	Seed      int64
+}
+
+type SkirmishCommander interface {
+	CreateCommand() *cobra.Command
+}
+
+func (s *SkirmishCommand) CreateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "skirmish",
+		Short:             "Manage your skirmishes",
+		Long:              "Create, list, switch between, archive, and delete the skirmishes saved for a persona",
+		PersistentPreRunE: s.initialize,
+	}
+	cmd.PersistentFlags().StringVarP(&s.Persona, "persona", "p", "", "the persona whose skirmishes to manage; defaults to the active persona")
+
+	newCmd := &cobra.Command{
+		Use:   "new <name>",
+		Short: "Start a new skirmish",
+		Long:  "Start a new skirmish between two or more companies and make it the active skirmish for the persona; any of the companies may be controlled by the computer",
+		Args:  cobra.ExactArgs(1),
+		RunE:  s.executeNew,
+	}
+	newCmd.Flags().SortFlags = false
+	newCmd.Flags().StringVarP(&s.Scenario, "scenario", "s", "", "the scenario to play the skirmish in")
+	newCmd.Flags().StringArrayVarP(&s.Companies, "company", "c", []string{}, "a company to play in the skirmish; pass at least two")
+	newCmd.Flags().StringArrayVarP(&s.Agents, "agent", "a", []string{}, "a company for the computer to control, optionally as company=agent; the only agent is heuristic")
+	newCmd.Flags().BoolVar(&s.Autosave, "autosave", true, "save the skirmish after every action")
+	newCmd.Flags().Int64Var(&s.Seed, "seed", 0, "the seed for the skirmish's dice; random if not specified")
+	cmd.AddCommand(newCmd)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the persona's skirmishes",
+		Args:  cobra.NoArgs,
+		RunE:  s.executeList,
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "switch <name>",
+		Short: "Make a skirmish the active one",
+		Long:  "Make a skirmish the active one for the persona, so flfa play resumes it; archived skirmishes are restored",
+		Args:  cobra.ExactArgs(1),
+		RunE:  s.executeSwitch,
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "archive <name>",
+		Short: "Set a skirmish aside",
+		Long:  "Set a skirmish aside so it is no longer played; it can still be replayed or switched back to",
+		Args:  cobra.ExactArgs(1),
+		RunE:  s.executeArchive,
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "delete <name>",
+		Short: "Delete a skirmish",
+		Long:  "Delete a skirmish and its save; this cannot be undone",
+		Args:  cobra.ExactArgs(1),
+		RunE:  s.executeDelete,
+	})
+
+	return cmd
+}
+
+func (s *SkirmishCommand) initialize(cmd *cobra.Command, args []string) error {
+	return s.Api.InitializeGameState()
+}
+
+func (s *SkirmishCommand) player() (*player.Player, error) {
+	personaName := s.Persona
+	if personaName == "" {
+		personaName = s.Api.Tympan.Configuration.ActiveUserPersona
+	}
+	if personaName == "" {
+		return nil, fmt.Errorf("no persona specified and there is no active persona; pass one with --persona")
+	}
+
+	foundPlayer, err := s.Api.GetPlayer(personaName, "")
+	if err != nil {
+		return nil, fmt.Errorf("unable to load persona '%s': %s", personaName, err)
+	}
+	return &foundPlayer, nil
+}
+
+func (s *SkirmishCommand) executeNew(cmd *cobra.Command, args []string) error {
+	userPlayer, err := s.player()
+	if err != nil {
+		return err
+	}
+	if len(s.Companies) < 2 {
+		return fmt.Errorf("a skirmish needs at least two companies; pass each with --company")
+	}
+	if s.Scenario != "" && data.GetScenarioByName(s.Scenario, s.Api.Cache.Scenarios).Name == "" {
+		return fmt.Errorf("unable to find scenario '%s' in any module", s.Scenario)
+	}
+
+	game := skirmish.Skirmish{Scenario: s.Scenario, Seed: s.Seed}
+	for _, name := range s.Companies {
+		company, err := s.Api.FindCompany(name, userPlayer.Persona)
+		if err != nil {
+			return err
+		}
+		game.Companies = append(game.Companies, company)
+	}
+	for _, controlled := range s.Agents {
+		name, agent, found := strings.Cut(controlled, "=")
+		if !found {
+			agent = skirmish.HeuristicAgent
+		}
+		if !utils.Contains(s.Companies, name) {
+			return fmt.Errorf("unable to hand control of '%s' to the computer: it is not one of the skirmish's companies", name)
+		}
+		if agent != skirmish.HeuristicAgent {
+			return fmt.Errorf("unable to hand control of '%s' to agent '%s': the only agent is %s", name, agent, skirmish.HeuristicAgent)
+		}
+		if game.Agents == nil {
+			game.Agents = make(map[string]string)
+		}
+		game.Agents[name] = agent
+	}
+
+	configuration := player.SkirmishConfiguration{Autosave: s.Autosave}
+	if _, err := s.Api.CreateSkirmish(args[0], game, configuration, userPlayer.Persona, ""); err != nil {
+		return err
+	}
+	fmt.Printf("Created skirmish '%s' for %s; run flfa play to start it.\n", args[0], userPlayer.Name)
+	return nil
+}
+
+func (s *SkirmishCommand) executeList(cmd *cobra.Command, args []string) error {
+	userPlayer, err := s.player()
+	if err != nil {
+		return err
+	}
+	if len(userPlayer.Settings.Skirmishes) == 0 {
+		fmt.Printf("%s has no skirmishes; start one with flfa skirmish new.\n", userPlayer.Name)
+		return nil
+	}
+
+	var table strings.Builder
+	table.WriteString(fmt.Sprintf("  %-24s %-9s %-8s %s\n", "Name", "Autosave", "Archived", "Progress"))
+	for _, entry := range userPlayer.Settings.Skirmishes {
+		marker := " "
+		if entry.Name == userPlayer.Settings.ActiveSkirmish {
+			marker = "*"
+		}
+		table.WriteString(fmt.Sprintf(
+			"%s %-24s %-9s %-8s %s\n",
+			marker,
+			entry.Name,
+			yesOrNo(entry.Configuration.Autosave),
+			yesOrNo(entry.Archived),
+			s.progress(entry.Name, userPlayer),
+		))
+	}
+	fmt.Print(table.String())
+	return nil
+}
+
+// progress describes how far along a skirmish is from its save; skirmishes which have never been saved have not
+// started.
+func (s *SkirmishCommand) progress(name string, userPlayer *player.Player) string {
+	saved, err := s.Api.GetSkirmish(name, userPlayer.Persona, "")
+	if err != nil {
+		return "not started"
+	}
+	game := saved.Data
+	switch {
+	case game.Ended && game.Winner != "":
+		return fmt.Sprintf("won by %s on turn %d", game.Winner, game.Turn)
+	case game.Ended:
+		return fmt.Sprintf("a draw on turn %d", game.Turn)
+	case len(game.Setup.Companies) == 0:
+		return "not started"
+	case game.Phase == skirmish.PhaseDeployment:
+		return "deploying"
+	default:
+		return fmt.Sprintf("turn %d", game.Turn)
+	}
+}
+
+func yesOrNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
+
+func (s *SkirmishCommand) executeSwitch(cmd *cobra.Command, args []string) error {
+	userPlayer, err := s.player()
+	if err != nil {
+		return err
+	}
+	return s.Api.SwitchSkirmish(args[0], userPlayer.Persona)
+}
+
+func (s *SkirmishCommand) executeArchive(cmd *cobra.Command, args []string) error {
+	userPlayer, err := s.player()
+	if err != nil {
+		return err
+	}
+	return s.Api.ArchiveSkirmish(args[0], userPlayer.Persona)
+}
+
+func (s *SkirmishCommand) executeDelete(cmd *cobra.Command, args []string) error {
+	userPlayer, err := s.player()
+	if err != nil {
+		return err
+	}
+	return s.Api.DeleteSkirmish(args[0], userPlayer.Persona, "")
+}
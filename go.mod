@@ -13,7 +13,6 @@ require (
 	github.com/gernest/front v0.0.0-20210301115436-8a0b0a782d0a
 	github.com/google/uuid v1.3.0
 	github.com/gosimple/slug v1.12.0
-	github.com/justinian/dice v1.0.1
 	github.com/knadh/koanf v1.4.1
 	github.com/mitchellh/mapstructure v1.4.3
 	github.com/muesli/termenv v0.11.1-0.20220212125758-44cd13922739
@@ -26,6 +25,7 @@ require (
 	go.opentelemetry.io/otel/sdk v1.5.0
 	go.opentelemetry.io/otel/trace v1.5.0
 	google.golang.org/grpc v1.45.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
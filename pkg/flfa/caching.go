@@ -4,6 +4,7 @@ import (
 	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
 	"github.com/FlagrantGarden/flfa/pkg/tympan/module"
 	tympan_scripting "github.com/FlagrantGarden/flfa/pkg/tympan/module/scripting"
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/afero"
 )
 
@@ -17,46 +18,94 @@ func (ffapi *Api) CachingFs(embedded bool) *afero.Afero {
 	return afs
 }
 
-func (ffapi *Api) CacheProfiles(modulePath string, embedded bool) {
+// ReadManifest reads the manifest of the module without caching it.
+func (ffapi *Api) ReadManifest(modulePath string, embedded bool) (module.Manifest, error) {
+	if embedded {
+		return module.GetEmbeddedManifest(modulePath, ffapi.EMFS)
+	}
+	return module.GetManifest(modulePath, ffapi.Tympan.AFS)
+}
+
+// CacheManifest reads the manifest of the module and adds it to the cache; it must be read before any of the module's
+// data, as the manifest's id is the source of every entry in it.
+func (ffapi *Api) CacheManifest(modulePath string, embedded bool) (manifest module.Manifest, err error) {
+	manifest, err = ffapi.ReadManifest(modulePath, embedded)
+	if err != nil {
+		return manifest, err
+	}
+	ffapi.Cache.Modules = append(ffapi.Cache.Modules, manifest)
+	return manifest, nil
+}
+
+func (ffapi *Api) CacheProfiles(modulePath string, source string, embedded bool) {
 	var profiles []data.Profile
 	if embedded {
-		profiles, _ = module.GetEmbeddedDataByFile[data.Profile](modulePath, "Profiles", ffapi.EMFS)
+		profiles, _ = module.GetEmbeddedDataByFile[data.Profile](modulePath, "Profiles", source, ffapi.EMFS)
 	} else {
-		profiles, _ = module.GetDataByFile[data.Profile](modulePath, "Profiles", ffapi.Tympan.AFS)
+		profiles, _ = module.GetDataByFile[data.Profile](modulePath, "Profiles", source, ffapi.Tympan.AFS)
 	}
 	ffapi.Cache.Profiles = append(ffapi.Cache.Profiles, profiles...)
 }
 
-func (ffapi *Api) CacheTraits(modulePath string, embedded bool) {
+func (ffapi *Api) CacheTraits(modulePath string, source string, embedded bool) {
 	var traits []data.Trait
 	if embedded {
-		traits, _ = module.GetEmbeddedDataByFolder[data.Trait](modulePath, "Traits", ffapi.EMFS)
+		traits, _ = module.GetEmbeddedDataByFolder[data.Trait](modulePath, "Traits", source, ffapi.EMFS)
 	} else {
-		traits, _ = module.GetDataByFolder[data.Trait](modulePath, "Traits", ffapi.Tympan.AFS)
+		traits, _ = module.GetDataByFolder[data.Trait](modulePath, "Traits", source, ffapi.Tympan.AFS)
 	}
 	ffapi.Cache.Traits = append(ffapi.Cache.Traits, traits...)
 }
 
-func (ffapi *Api) CacheSpells(modulePath string, embedded bool) {
+func (ffapi *Api) CacheSpells(modulePath string, source string, embedded bool) {
 	var spells []data.Spell
 	if embedded {
-		spells, _ = module.GetEmbeddedDataByFile[data.Spell](modulePath, "Spells", ffapi.EMFS)
+		spells, _ = module.GetEmbeddedDataByFile[data.Spell](modulePath, "Spells", source, ffapi.EMFS)
 	} else {
-		spells, _ = module.GetDataByFile[data.Spell](modulePath, "Spells", ffapi.Tympan.AFS)
+		spells, _ = module.GetDataByFile[data.Spell](modulePath, "Spells", source, ffapi.Tympan.AFS)
 	}
 	ffapi.Cache.Spells = append(ffapi.Cache.Spells, spells...)
 }
 
-func (ffapi *Api) CacheCompanies(modulePath string, embedded bool) {
+func (ffapi *Api) CachePrompts(modulePath string, source string, embedded bool) {
+	var prompts []data.Prompt
+	if embedded {
+		prompts, _ = module.GetEmbeddedDataByFile[data.Prompt](modulePath, "Prompts", source, ffapi.EMFS)
+	} else {
+		prompts, _ = module.GetDataByFile[data.Prompt](modulePath, "Prompts", source, ffapi.Tympan.AFS)
+	}
+	ffapi.Cache.Prompts = append(ffapi.Cache.Prompts, prompts...)
+}
+
+func (ffapi *Api) CacheScenarios(modulePath string, source string, embedded bool) {
+	var scenarios []data.Scenario
+	if embedded {
+		scenarios, _ = module.GetEmbeddedDataByFile[data.Scenario](modulePath, "Scenarios", source, ffapi.EMFS)
+	} else {
+		scenarios, _ = module.GetDataByFile[data.Scenario](modulePath, "Scenarios", source, ffapi.Tympan.AFS)
+	}
+	ffapi.Cache.Scenarios = append(ffapi.Cache.Scenarios, scenarios...)
+}
+
+func (ffapi *Api) CacheCompanies(modulePath string, source string, embedded bool) {
 	var companies []data.Company
 	if embedded {
-		companies, _ = module.GetEmbeddedDataByFile[data.Company](modulePath, "Companies", ffapi.EMFS)
+		companies, _ = module.GetEmbeddedDataByFile[data.Company](modulePath, "Companies", source, ffapi.EMFS)
 	} else {
-		companies, _ = module.GetDataByFile[data.Company](modulePath, "Companies", ffapi.Tympan.AFS)
+		companies, _ = module.GetDataByFile[data.Company](modulePath, "Companies", source, ffapi.Tympan.AFS)
 	}
-	for _, company := range companies {
-		company.Initialize(ffapi.Cache.Profiles, ffapi.Cache.Traits)
-		ffapi.Cache.Companies = append(ffapi.Cache.Companies, company)
+	ffapi.Cache.Companies = append(ffapi.Cache.Companies, companies...)
+}
+
+// InitializeCompanies sets up every cached company's groups from their profiles and rolls for the Captain's trait of
+// any company which does not name one. It needs the script engine for its dice, so it comes after every module is
+// cached and the engine is initialized.
+func (ffapi *Api) InitializeCompanies() {
+	for index := range ffapi.Cache.Companies {
+		company := &ffapi.Cache.Companies[index]
+		if err := company.Initialize(ffapi.Cache.Profiles, ffapi.Cache.Traits, ffapi.Roller()); err != nil {
+			log.Warn().Msgf("unable to initialize company '%s': %s", company.Name, err)
+		}
 	}
 }
 
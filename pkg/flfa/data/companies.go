@@ -3,6 +3,7 @@ package data
 import (
 	"fmt"
 
+	"github.com/FlagrantGarden/flfa/pkg/tympan/dice"
 	"github.com/rs/zerolog/log"
 )
 
@@ -18,7 +19,7 @@ func (company Company) WithSource(source string) Company {
 	return company
 }
 
-func (company *Company) Initialize(availableProfiles []Profile, availableTraits []Trait) error {
+func (company *Company) Initialize(availableProfiles []Profile, availableTraits []Trait, roller *dice.Roller) error {
 	groups := []Group{}
 	for index, groupData := range company.Groups {
 		log.Trace().Msgf("initializing Group '%s' for Company '%s'", groupData.Name, company.Name)
@@ -29,7 +30,7 @@ func (company *Company) Initialize(availableProfiles []Profile, availableTraits
 		if index == 0 {
 			log.Trace().Msgf("initializing Group '%s' as Captain", group.Name)
 			if group.Captain.Name == "" {
-				group.PromoteToCaptain(nil, FilterTraitsBySource("core", availableTraits)...)
+				group.PromoteToCaptain(nil, roller, FilterTraitsBySource("core", availableTraits)...)
 			} else {
 				group.Captain = groupData.Captain
 			}
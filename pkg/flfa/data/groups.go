@@ -2,7 +2,9 @@ package data
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/FlagrantGarden/flfa/pkg/tympan/dice"
 	"github.com/rs/zerolog/log"
 )
 
@@ -13,6 +15,7 @@ type Group struct {
 	Melee            Melee
 	Move             Move
 	Missile          Missile
+	ExtraMissiles    []Missile        `mapstructure:"extra_missiles"`
 	FightingStrength FightingStrength `mapstructure:"fighting_strength"`
 	Resolve          int
 	Toughness        int
@@ -36,6 +39,7 @@ func (group *Group) Initialize(availableProfiles []Profile) error {
 	group.Melee = profile.Melee
 	group.Move = profile.Move
 	group.Missile = profile.Missile
+	group.ExtraMissiles = profile.ExtraMissiles
 	group.FightingStrength = profile.FightingStrength
 	group.Resolve = profile.Resolve
 	group.Toughness = profile.Toughness
@@ -46,6 +50,40 @@ func (group *Group) Initialize(availableProfiles []Profile) error {
 	return nil
 }
 
+// MissileProfiles returns every usable missile profile the Group has, starting with its primary one.
+func (group *Group) MissileProfiles() (profiles []Missile) {
+	for _, missile := range append([]Missile{group.Missile}, group.ExtraMissiles...) {
+		if missile.Usable() {
+			profiles = append(profiles, missile)
+		}
+	}
+	return profiles
+}
+
+// MissileProfile returns the usable missile profile with the given name; an empty name returns the first one.
+func (group *Group) MissileProfile(name string) (Missile, error) {
+	for _, missile := range group.MissileProfiles() {
+		if name == "" || strings.EqualFold(missile.Name, name) {
+			return missile, nil
+		}
+	}
+	if name == "" {
+		return Missile{}, fmt.Errorf("%s has no missile profile", group.Name)
+	}
+	return Missile{}, fmt.Errorf("%s has no missile profile named '%s'", group.Name, name)
+}
+
+func (group *Group) missileStrings() (missiles []string) {
+	profiles := group.MissileProfiles()
+	if len(profiles) == 0 {
+		return []string{group.Missile.String()}
+	}
+	for index := range profiles {
+		missiles = append(missiles, profiles[index].String())
+	}
+	return missiles
+}
+
 func (group Group) ToSlice() (groups []Group) {
 	groups = append(groups, group)
 	return groups
@@ -55,13 +93,13 @@ func (group *Group) DemoteFromCaptain() {
 	group.Captain = Trait{}
 }
 
-func (group *Group) PromoteToCaptain(trait *Trait, availableTraits ...Trait) error {
+func (group *Group) PromoteToCaptain(trait *Trait, roller *dice.Roller, availableTraits ...Trait) error {
 	if trait != nil {
 		group.Captain = *trait
 		return nil
 	}
 
-	rolledTrait, err := RollForCaptainTrait(availableTraits)
+	rolledTrait, err := RollForCaptainTrait(roller, availableTraits)
 	if err != nil {
 		return fmt.Errorf("unable to make %s into a captain: %s", group.Name, err)
 	}
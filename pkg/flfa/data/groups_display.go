@@ -20,7 +20,7 @@ func (group *Group) ToMarkdownTableEntry() string {
 	}
 	output.WriteString(fmt.Sprintf(" %s |", group.ProfileName))
 	output.WriteString(fmt.Sprintf(" %s |", group.Melee.String()))
-	output.WriteString(fmt.Sprintf(" %s |", group.Missile.String()))
+	output.WriteString(fmt.Sprintf(" %s |", strings.Join(group.missileStrings(), ", ")))
 	output.WriteString(fmt.Sprintf(" %s |", group.Move.String()))
 	output.WriteString(fmt.Sprintf(" %s |", group.FightingStrength.String()))
 	output.WriteString(fmt.Sprintf(" %d+ |", group.Resolve))
@@ -105,7 +105,7 @@ func (group *Group) ToTerminalTableEntry(options ...pterm.Option) string {
 	cells = append(cells, settings.AppliedExtraStyles(append(lead_styles, "name")...).Render(name))
 	cells = append(cells, settings.AppliedExtraStyles(append(body_styles, "profile_name")...).Render(group.ProfileName))
 	cells = append(cells, settings.AppliedExtraStyles(append(body_styles, "melee")...).Render(group.Melee.String()))
-	cells = append(cells, settings.AppliedExtraStyles(append(body_styles, "missile")...).Render(group.Missile.String()))
+	cells = append(cells, settings.AppliedExtraStyles(append(body_styles, "missile")...).Render(strings.Join(group.missileStrings(), "\n")))
 	cells = append(cells, settings.AppliedExtraStyles(append(body_styles, "move")...).Render(group.Move.String()))
 	cells = append(cells, settings.AppliedExtraStyles(append(body_styles, "fighting_strength")...).Render(group.FightingStrength.String()))
 	cells = append(cells, settings.AppliedExtraStyles(append(body_styles, "resolve")...).Render(fmt.Sprintf("%d", group.Resolve)))
@@ -6,11 +6,22 @@ import (
 )
 
 type Missile struct {
+	Name       string
 	Activation int
 	ToHit      int `mapstructure:"to_hit"`
 	Range      int
 }
 
+// A missile profile is only usable if the Group can both activate to shoot with it and hit with it.
+func (missile Missile) Usable() bool {
+	return missile.Activation > 0 && missile.ToHit > 0
+}
+
+// A missile profile with no range can shoot at any distance.
+func (missile Missile) InRange(distance float64) bool {
+	return missile.Range <= 0 || distance <= float64(missile.Range)
+}
+
 func (missile *Missile) String() string {
 	output := strings.Builder{}
 	if missile.Activation == 0 {
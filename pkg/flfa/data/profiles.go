@@ -13,6 +13,7 @@ type Profile struct {
 	Melee            Melee
 	Move             Move
 	Missile          Missile
+	ExtraMissiles    []Missile        `mapstructure:"extra_missiles"`
 	FightingStrength FightingStrength `mapstructure:"fighting_strength"`
 	Resolve          int
 	Toughness        int
@@ -0,0 +1,67 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/FlagrantGarden/flfa/pkg/tympan/prompts/dynamic"
+)
+
+// A Prompt asks a player to make a choice for one of their Groups during play, like whether to use a trait. Trait
+// scripts ask for a Prompt by name with `core.Play.Prompt(name)`, which returns the player's answer; once the trait
+// script has finished, the Prompt's own Then scripts run with the answer as `answer`.
+type Prompt struct {
+	Source string
+	Name   string
+	Info   dynamic.Info `mapstructure:"prompt"`
+	// Choices names where the choices for a selection prompt come from during play, in addition to any in its options:
+	// "spells", "friends", or "enemies".
+	Choices string
+	// Count is how many times to ask; each answer is removed from the choices before asking again. When the Count is
+	// more than one, the answer is the list of every choice made.
+	Count int
+	// The Default is the answer used when there is nobody to ask.
+	Default any
+	Then    []string
+}
+
+// The sources for a selection prompt's choices during play
+const (
+	PromptChoicesSpells  = "spells"
+	PromptChoicesFriends = "friends"
+	PromptChoicesEnemies = "enemies"
+)
+
+func (prompt Prompt) WithSource(source string) Prompt {
+	prompt.Source = source
+	return prompt
+}
+
+// Times returns how many answers the Prompt asks for; it is always at least one.
+func (prompt Prompt) Times() int {
+	if prompt.Count < 1 {
+		return 1
+	}
+	return prompt.Count
+}
+
+func (prompt Prompt) ScriptName() string {
+	return fmt.Sprintf("Prompt: '%s'", prompt.Name)
+}
+
+func (prompt Prompt) ScriptBody() string {
+	var scriptBuilder strings.Builder
+	for _, change := range prompt.Then {
+		scriptBuilder.WriteString(fmt.Sprintf("%s\n", change))
+	}
+	return scriptBuilder.String()
+}
+
+func GetPromptByName(name string, promptList []Prompt) Prompt {
+	for _, prompt := range promptList {
+		if strings.EqualFold(prompt.Name, name) {
+			return prompt
+		}
+	}
+	return Prompt{}
+}
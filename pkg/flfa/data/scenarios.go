@@ -0,0 +1,107 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A Scenario describes how a skirmish is set up and won: the size of the battlefield and its terrain, where each side
+// deploys, how many turns are played, the objectives which can be held, and the conditions for victory.
+type Scenario struct {
+	Source            string
+	Name              string
+	Description       string
+	Width             int
+	Height            int
+	TurnLimit         int `mapstructure:"turn_limit"`
+	Terrain           []ScenarioArea
+	DeploymentZones   []DeploymentZone `mapstructure:"deployment_zones"`
+	Objectives        []Objective
+	VictoryConditions []VictoryCondition `mapstructure:"victory_conditions"`
+	Scripting         ScenarioScripting
+}
+
+// A ScenarioArea is a rectangle of terrain on the battlefield; its X and Y are its bottom left corner.
+type ScenarioArea struct {
+	Name   string
+	Type   string
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+// A DeploymentZone is a rectangle of the battlefield the attackers or defenders must deploy their Groups in.
+type DeploymentZone struct {
+	Side   string
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+func (zone DeploymentZone) Contains(x int, y int) bool {
+	return x >= zone.X && x <= zone.X+zone.Width && y >= zone.Y && y <= zone.Y+zone.Height
+}
+
+// An Objective is held by a Company at the end of a turn if it has a Group within the radius and no enemy does. Each
+// time it is held, the Company scores its points.
+type Objective struct {
+	Name   string
+	X      int
+	Y      int
+	Radius int
+	Points int
+}
+
+// A VictoryCondition is either a score which wins the skirmish as soon as a Company reaches it at the end of a turn or
+// decides the winner once the turn limit is reached, by the highest score or the most points of Groups still in play.
+type VictoryCondition struct {
+	Type  string
+	Score int
+}
+
+const (
+	VictoryByScore      = "score"
+	VictoryByMostScore  = "most_score"
+	VictoryByMostPoints = "most_points"
+	DeploymentAttackers = "attackers"
+	DeploymentDefenders = "defenders"
+)
+
+// Scoring scripts run for every Company at the end of every turn and may change its score.
+type ScenarioScripting struct {
+	Scoring []string
+}
+
+func (scenario Scenario) WithSource(source string) Scenario {
+	scenario.Source = source
+	return scenario
+}
+
+func (scenario Scenario) ScoringScriptBody() string {
+	var scriptBuilder strings.Builder
+	for _, snippet := range scenario.Scripting.Scoring {
+		scriptBuilder.WriteString(fmt.Sprintf("%s\n", snippet))
+	}
+	return scriptBuilder.String()
+}
+
+// DeploymentZonesFor returns the zones the named side, attackers or defenders, may deploy in.
+func (scenario Scenario) DeploymentZonesFor(side string) (zones []DeploymentZone) {
+	for _, zone := range scenario.DeploymentZones {
+		if strings.EqualFold(zone.Side, side) {
+			zones = append(zones, zone)
+		}
+	}
+	return zones
+}
+
+func GetScenarioByName(name string, scenarioList []Scenario) Scenario {
+	for _, scenario := range scenarioList {
+		if strings.EqualFold(scenario.Name, name) {
+			return scenario
+		}
+	}
+	return Scenario{}
+}
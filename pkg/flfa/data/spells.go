@@ -1,16 +1,33 @@
 package data
 
+import "strings"
+
 type Spell struct {
-	Source   string
-	Name     string
-	Check    int
-	Range    int
-	Target   string
-	Duration string
-	Effect   string
+	Source    string
+	Name      string
+	Check     int
+	Range     int
+	Target    string
+	Duration  string
+	Effect    string
+	Scripting SpellScripting
+}
+
+// A spell's in-play scripts are registered for the target of the spell for as long as the spell's effect lasts.
+type SpellScripting struct {
+	InPlay []TraitScriptingInPlay `mapstructure:"in_play"`
 }
 
 func (spell Spell) WithSource(source string) Spell {
 	spell.Source = source
 	return spell
 }
+
+func GetSpellByName(name string, spellList []Spell) Spell {
+	for _, spell := range spellList {
+		if strings.EqualFold(spell.Name, name) {
+			return spell
+		}
+	}
+	return Spell{}
+}
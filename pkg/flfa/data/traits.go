@@ -4,15 +4,13 @@ import (
 	// "github.com/FlagrantGarden/flfa/pkg/flfa"
 
 	"fmt"
-	"math/rand"
 	"regexp"
 	"strings"
-	"time"
 
+	"github.com/FlagrantGarden/flfa/pkg/tympan/dice"
 	"github.com/FlagrantGarden/flfa/pkg/tympan/module/scripting"
 	"github.com/FlagrantGarden/flfa/pkg/tympan/prompts/dynamic"
 	"github.com/FlagrantGarden/flfa/pkg/tympan/utils"
-	"github.com/justinian/dice"
 )
 
 type Trait struct {
@@ -24,6 +22,7 @@ type Trait struct {
 	Points    int
 	Scripting TraitScripting
 	Choices   []*TraitChoice
+	Tags      []string
 }
 
 type TraitScripting struct {
@@ -141,6 +140,39 @@ func (trait Trait) RequirementsScriptBody() string {
 	return scriptBuilder.String()
 }
 
+func (inPlay TraitScriptingInPlay) ScriptBody() string {
+	var scriptBuilder strings.Builder
+	scriptBuilder.WriteString("in_play_conditions_met := true\n")
+	for _, condition := range inPlay.When {
+		scriptBuilder.WriteString("if in_play_conditions_met == true {\n")
+		scriptBuilder.WriteString(fmt.Sprintf("  in_play_conditions_met = %s\n", condition))
+		scriptBuilder.WriteString("}\n")
+	}
+	scriptBuilder.WriteString("if in_play_conditions_met == true {\n")
+	for _, change := range inPlay.Then {
+		scriptBuilder.WriteString(fmt.Sprintf("  %s\n", change))
+	}
+	scriptBuilder.WriteString("}\n")
+	return scriptBuilder.String()
+}
+
+// Returns true if the name matches the trait's name, including when the trait has choices which change its name, like
+// [Kind]bane -> Bearbane
+func (trait Trait) MatchesName(name string) bool {
+	if trait.Name == name {
+		return true
+	}
+	if len(trait.Choices) == 0 {
+		return false
+	}
+	pattern := regexp.QuoteMeta(trait.Name)
+	for _, choice := range trait.Choices {
+		pattern = strings.ReplaceAll(pattern, regexp.QuoteMeta(fmt.Sprintf("[%s]", choice.Name)), "(.+)")
+	}
+	matched, _ := regexp.MatchString(fmt.Sprintf("^%s$", pattern), name)
+	return matched
+}
+
 func (trait Trait) TraitWithChoiceUpdatedName() *Trait {
 	for _, choice := range trait.Choices {
 		regex := regexp.MustCompile(fmt.Sprintf("\\[%s\\]", choice.Name))
@@ -205,6 +237,45 @@ func (trait Trait) AddToGroup(group *Group, engine *scripting.Engine) (updatedGr
 	return updatedGroup, nil
 }
 
+// ApplyToCaptain runs the trait's on_add scripts for a Group whose Captain has the trait. Unlike AddToGroup, the trait
+// is not added to the Group's list of traits and its points are not added to the Group's.
+func (trait Trait) ApplyToCaptain(group *Group, engine *scripting.Engine) (updatedGroup *Group, err error) {
+	errorPrefix := fmt.Sprintf("can't apply captain trait '%s' to Group '%s'", trait.Name, group.Name)
+	body := trait.OnAddScriptBody()
+	if body == "" {
+		return group, nil
+	}
+
+	name := fmt.Sprintf("ApplyTraitToCaptain: '%s'", trait.Name)
+	script := engine.GetScript(name)
+	if script == nil {
+		err := engine.AddScript(name, body)
+		if err != nil {
+			return group, fmt.Errorf("%s: %s", errorPrefix, err)
+		}
+		script = engine.GetScript(name)
+	}
+
+	if group.Addenda == nil {
+		group.Addenda = make(map[string]any)
+	}
+	tengoizedGroup, err := scripting.ConvertToTengoMap(group)
+	if err != nil {
+		return group, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	script.Add("group", tengoizedGroup)
+
+	result, err := script.Run()
+	if err != nil {
+		return group, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	output_group, err := scripting.ConvertFromTengoMap[Group](result.Get("group").Map())
+	if err != nil {
+		return group, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	return &output_group, nil
+}
+
 func (trait Trait) OnAddScriptBody() string {
 	var scriptBuilder strings.Builder
 	for _, change := range trait.Scripting.OnAdd {
@@ -281,20 +352,16 @@ func (trait Trait) OnRemoveScriptBody() string {
 	return scriptBuilder.String()
 }
 
-func RollForCaptainTrait(availableTraits []Trait) (captainsTrait Trait, err error) {
-	rand.Seed(time.Now().UnixNano())
-	result, _, err := dice.Roll("3d6")
-	if err != nil {
-		return
-	}
+func RollForCaptainTrait(roller *dice.Roller, availableTraits []Trait) (captainsTrait Trait, err error) {
+	result := dice.Sum(roller.ThreeD6("captain's trait"))
 
 	for _, trait := range FilterTraitsByType("Captain", availableTraits) {
-		if trait.Roll == result.Int() {
+		if trait.Roll == result {
 			return trait, nil
 		}
 	}
 
-	err = fmt.Errorf("No available captain's trait matched roll result %d: %+v", result.Int(), availableTraits)
+	err = fmt.Errorf("No available captain's trait matched roll result %d: %+v", result, availableTraits)
 	return
 }
 
@@ -326,3 +393,16 @@ func GetTraitByName(name string, traitList []Trait) Trait {
 	}
 	return Trait{}
 }
+
+func GetTraitMatchingName(name string, traitList []Trait) Trait {
+	trait := GetTraitByName(name, traitList)
+	if trait.Name != "" {
+		return trait
+	}
+	for _, trait := range traitList {
+		if trait.MatchesName(name) {
+			return trait
+		}
+	}
+	return Trait{}
+}
@@ -2,16 +2,21 @@ package flfa
 
 import (
 	"embed"
+	"path"
 	"path/filepath"
 
 	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
 	"github.com/FlagrantGarden/flfa/pkg/flfa/state/player"
 	"github.com/FlagrantGarden/flfa/pkg/flfa/state/skirmish"
 	"github.com/FlagrantGarden/flfa/pkg/tympan"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/dice"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/module"
 	"github.com/FlagrantGarden/flfa/pkg/tympan/module/scripting"
 	"github.com/FlagrantGarden/flfa/pkg/tympan/state/instance"
 	"github.com/FlagrantGarden/flfa/pkg/tympan/state/persona"
 	"github.com/FlagrantGarden/flfa/pkg/tympan/utils"
+	"github.com/d5/tengo/v2"
+	"github.com/rs/zerolog/log"
 )
 
 type Api struct {
@@ -19,12 +24,18 @@ type Api struct {
 	EMFS         *embed.FS
 	Cache        DataCache
 	ScriptEngine *scripting.Engine
+	// The Seed, if set before the engine is initialized, seeds the dice rolled outside of a skirmish in place of the
+	// configuration's random_seed, such as for a command which takes a seed of its own.
+	Seed int64
 }
 
 type DataCache struct {
+	Modules         []module.Manifest
 	Traits          []data.Trait
 	Profiles        []data.Profile
 	Spells          []data.Spell
+	Prompts         []data.Prompt
+	Scenarios       []data.Scenario
 	Companies       []data.Company
 	Players         []player.Player
 	ScriptModules   []scripting.Module
@@ -33,7 +44,11 @@ type DataCache struct {
 
 type Configuration struct {
 	tympan.SharedConfig `mapstructure:",squash" tympanconfig:"ignore"`
-	ActiveUserPersona   string `mapstructure:"active_user_persona"`
+	ActiveUserPersona   string   `mapstructure:"active_user_persona"`
+	DisabledModules     []string `mapstructure:"disabled_modules"`
+	// The RandomSeed seeds the dice rolled outside of a skirmish, such as for Captain's traits; if it is zero, they are
+	// seeded from the current time.
+	RandomSeed int64 `mapstructure:"random_seed"`
 }
 
 func (config *Configuration) Initialize() error {
@@ -49,20 +64,72 @@ func (ffapi *Api) InitializeEngine() {
 		for _, module := range ffapi.Cache.ScriptModules {
 			ffapi.ScriptEngine.AddApplicationModule(module)
 		}
+		ffapi.ScriptEngine.Settings.RandomSeed = ffapi.Tympan.Configuration.RandomSeed
+		if ffapi.Seed != 0 {
+			ffapi.ScriptEngine.Settings.RandomSeed = ffapi.Seed
+		}
+		ffapi.ScriptEngine.InitializeDice()
+		ffapi.ScriptEngine.AddNativeModule("modules", ffapi.ModuleAttributes())
+	}
+}
+
+// ModuleAttributes describe the cached modules to scripts, which can use them to check what is loaded: the native
+// modules module maps each module's id to its author, display name, version, urls, and configuration, so a script can
+// check, say, modules.core.version.
+func (ffapi *Api) ModuleAttributes() map[string]tengo.Object {
+	attributes := make(map[string]tengo.Object)
+	for _, manifest := range ffapi.Cache.Modules {
+		configuration, err := tengo.FromInterface(manifest.Configuration)
+		if err != nil {
+			log.Warn().Msgf("unable to make the configuration of module '%s' available to scripts: %s", manifest.Id, err)
+			configuration = &tengo.ImmutableMap{Value: map[string]tengo.Object{}}
+		}
+		attributes[manifest.Id] = &tengo.ImmutableMap{Value: map[string]tengo.Object{
+			"author":        &tengo.String{Value: manifest.Author},
+			"id":            &tengo.String{Value: manifest.Id},
+			"display":       &tengo.String{Value: manifest.Display},
+			"version":       &tengo.String{Value: manifest.Version},
+			"source_url":    &tengo.String{Value: manifest.SourceUrl},
+			"project_url":   &tengo.String{Value: manifest.ProjectUrl},
+			"configuration": configuration,
+		}}
 	}
+	return attributes
 }
 
+// Roller returns the dice rolled outside of a skirmish, such as for a Captain's trait: the script engine's dice, seeded
+// from the Api's Seed or else the configuration's random_seed. Skirmishes roll their own dice from their seed instead.
+func (ffapi *Api) Roller() *dice.Roller {
+	ffapi.InitializeEngine()
+	return ffapi.ScriptEngine.Dice
+}
+
+// CacheModuleData caches the module's manifest and then all of its data, with the manifest's id as the source of every
+// entry. A module without a manifest is still cached, with the name of its folder as the source.
 func (ffapi *Api) CacheModuleData(modulePath string, embedded bool) {
-	ffapi.CacheProfiles(modulePath, embedded)
-	ffapi.CacheTraits(modulePath, embedded)
-	ffapi.CacheSpells(modulePath, embedded)
-	ffapi.CacheCompanies(modulePath, embedded)
+	manifest, err := ffapi.CacheManifest(modulePath, embedded)
+	source := manifest.Id
+	if err != nil {
+		source = path.Base(filepath.ToSlash(modulePath))
+		log.Warn().Msgf("module at '%s' has no usable manifest; using '%s' as its id: %s", modulePath, source, err)
+	}
+	ffapi.CacheProfiles(modulePath, source, embedded)
+	ffapi.CacheTraits(modulePath, source, embedded)
+	ffapi.CacheSpells(modulePath, source, embedded)
+	ffapi.CachePrompts(modulePath, source, embedded)
+	ffapi.CacheScenarios(modulePath, source, embedded)
+	ffapi.CacheCompanies(modulePath, source, embedded)
 	ffapi.CacheScriptLibraries(modulePath, embedded)
 	ffapi.CacheScriptModules(modulePath, embedded)
 }
 
+// ModuleFolderPath is the folder modules are installed in, each in its own folder.
+func (ffapi *Api) ModuleFolderPath() string {
+	return filepath.Join(ffapi.Tympan.Configuration.FolderPaths.Cache, "modules")
+}
+
 func (ffapi *Api) InstalledModules() (installedModules []string, err error) {
-	moduleFolderPath := filepath.Join(ffapi.Tympan.Configuration.FolderPaths.Cache, "modules")
+	moduleFolderPath := ffapi.ModuleFolderPath()
 
 	moduleFolderExists, err := ffapi.Tympan.AFS.DirExists(moduleFolderPath)
 	if err != nil || !moduleFolderExists {
@@ -116,12 +183,34 @@ func (ffapi *Api) GetPlayer(name string, cachePath string) (foundPlayer player.P
 }
 
 func (ffapi *Api) GetActiveSkirmish(activeUserPersona *persona.Persona[player.Data, player.Settings], cachePath string) (*instance.Instance[skirmish.Skirmish], error) {
+	return ffapi.GetSkirmish(activeUserPersona.Settings.ActiveSkirmish, activeUserPersona, cachePath)
+}
+
+func (ffapi *Api) GetSkirmish(name string, userPersona *persona.Persona[player.Data, player.Settings], cachePath string) (*instance.Instance[skirmish.Skirmish], error) {
 	if cachePath == "" {
 		cachePath = ffapi.Tympan.Configuration.FolderPaths.Cache
 	}
 	skirmishPersona := &instance.Persona{
-		Name: activeUserPersona.Name,
-		Kind: activeUserPersona.Kind,
+		Name: userPersona.Name,
+		Kind: userPersona.Kind,
+	}
+	return instance.GetInstance[skirmish.Skirmish](name, skirmish.Kind(), skirmishPersona, cachePath, ffapi.Tympan.AFS)
+}
+
+// SkirmishOptions returns the options for a skirmish engine to use the cached module data and the script engine; if
+// the skirmish is being played in one of the cached scenarios, the engine is set up for it.
+func (ffapi *Api) SkirmishOptions(scenarioName string) []skirmish.Option {
+	ffapi.InitializeEngine()
+	options := []skirmish.Option{
+		skirmish.WithScriptEngine(ffapi.ScriptEngine),
+		skirmish.WithTraits(ffapi.Cache.Traits),
+		skirmish.WithSpells(ffapi.Cache.Spells),
+		skirmish.WithPrompts(ffapi.Cache.Prompts),
+	}
+	if scenarioName != "" {
+		if scenario := data.GetScenarioByName(scenarioName, ffapi.Cache.Scenarios); scenario.Name != "" {
+			options = append(options, skirmish.WithScenario(scenario))
+		}
 	}
-	return instance.GetInstance[skirmish.Skirmish](activeUserPersona.Settings.ActiveSkirmish, skirmish.Kind(), skirmishPersona, cachePath, ffapi.Tympan.AFS)
+	return options
 }
@@ -0,0 +1,116 @@
+package flfa
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/state/player"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/state/persona"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/utils"
+)
+
+// FindGroup returns the Group a reference names: either 'Company/Group' for a Group in one of the persona's companies
+// or those from modules, or the name of a profile for a new Group with it. Groups are returned as they would be in play,
+// with the on_add scripts of their traits and their Captain's trait applied; the persona may be nil.
+func (ffapi *Api) FindGroup(reference string, userPersona *persona.Persona[player.Data, player.Settings]) (data.Group, error) {
+	errorPrefix := fmt.Sprintf("unable to find group '%s'", reference)
+	ffapi.InitializeEngine()
+
+	companyName, groupName, found := strings.Cut(reference, "/")
+	if !found {
+		group, err := data.NewGroup(reference, reference, ffapi.Cache.Profiles)
+		if err != nil {
+			return group, fmt.Errorf("%s: %s", errorPrefix, err)
+		}
+		return group, nil
+	}
+
+	company, err := ffapi.FindCompany(companyName, userPersona)
+	if err != nil {
+		return data.Group{}, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	fromPersona := false
+	if userPersona != nil {
+		for _, personaCompany := range userPersona.Data.Companies {
+			fromPersona = fromPersona || personaCompany.Name == company.Name
+		}
+	}
+	for _, group := range company.Groups {
+		if group.Name != groupName {
+			continue
+		}
+		// The persona's groups were built in the editor, which ran the scripts as traits were added; groups from modules
+		// only name their traits.
+		if !fromPersona {
+			rebuilt, err := ffapi.rebuildGroup(group)
+			if err != nil {
+				return group, fmt.Errorf("%s: %s", errorPrefix, err)
+			}
+			group = rebuilt
+		}
+		if group.Captain.Name != "" {
+			captain := group.Captain
+			if len(captain.Scripting.OnAdd) == 0 {
+				captain.Scripting = data.GetTraitByName(captain.Name, ffapi.Cache.Traits).Scripting
+			}
+			updatedGroup, err := captain.ApplyToCaptain(&group, ffapi.ScriptEngine)
+			if err != nil {
+				return group, fmt.Errorf("%s: %s", errorPrefix, err)
+			}
+			group = *updatedGroup
+		}
+		return group, nil
+	}
+	return data.Group{}, fmt.Errorf("%s: company '%s' has no group by that name", errorPrefix, company.Name)
+}
+
+// rebuildGroup builds the Group afresh from its profile and adds each of its special traits to it. Traits which need
+// choices are only named, as there is nothing to choose with.
+func (ffapi *Api) rebuildGroup(group data.Group) (data.Group, error) {
+	rebuilt, err := data.NewGroup(group.Name, group.ProfileName, ffapi.Cache.Profiles)
+	if err != nil {
+		return group, err
+	}
+	rebuilt.Id = group.Id
+	rebuilt.Captain = group.Captain
+	for _, name := range group.Traits {
+		if utils.Contains(rebuilt.Traits, name) {
+			continue
+		}
+		trait := data.GetTraitMatchingName(name, ffapi.Cache.Traits)
+		if trait.Name == "" || len(trait.Choices) > 0 {
+			rebuilt.Traits = append(rebuilt.Traits, name)
+			rebuilt.Points += trait.Points
+			continue
+		}
+		updatedGroup, err := trait.AddToGroup(&rebuilt, ffapi.ScriptEngine)
+		if err != nil {
+			return group, err
+		}
+		rebuilt = *updatedGroup
+	}
+	return rebuilt, nil
+}
+
+// AddTraits adds the named special traits to a copy of the Group, running their on_add scripts, so the Group can be
+// compared with and without them.
+func (ffapi *Api) AddTraits(group data.Group, names ...string) (data.Group, error) {
+	ffapi.InitializeEngine()
+	group.Traits = append([]string{}, group.Traits...)
+	for _, name := range names {
+		trait := data.GetTraitByName(name, ffapi.Cache.Traits)
+		if trait.Name == "" {
+			return group, fmt.Errorf("unable to add trait '%s' to group '%s': no module defines it", name, group.Name)
+		}
+		if len(trait.Choices) > 0 {
+			return group, fmt.Errorf("unable to add trait '%s' to group '%s': it needs choices made in the editor", name, group.Name)
+		}
+		updatedGroup, err := trait.AddToGroup(&group, ffapi.ScriptEngine)
+		if err != nil {
+			return group, err
+		}
+		group = *updatedGroup
+	}
+	return group, nil
+}
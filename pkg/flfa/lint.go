@@ -0,0 +1,406 @@
+package flfa
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"strings"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/state/skirmish"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/module"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/module/lint"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/module/schema"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/module/scripting"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+// LintModule checks a module for every problem which would keep its data from loading or its scripts from running,
+// returning the Issues found in the order they appear in its files. The reference is either the id of an available
+// module or the path to a module's folder or archive.
+//
+// The module is linted in isolation: only the modules it requires are loaded alongside it, so its companies, profiles,
+// and scripts may only rely on what those modules provide.
+func (ffapi *Api) LintModule(reference string) ([]lint.Issue, error) {
+	moduleFS, cleanup, err := ffapi.moduleFS(reference)
+	if err != nil {
+		return nil, fmt.Errorf("unable to lint module '%s': %s", reference, err)
+	}
+	defer cleanup()
+
+	linter := &moduleLinter{
+		fs:      moduleFS,
+		scratch: &Api{Tympan: ffapi.Tympan, EMFS: ffapi.EMFS},
+	}
+	linter.lintManifest()
+	linter.loadRequirements(ffapi.availableModules())
+	linter.lintData()
+	linter.lintScripts()
+	lint.Sort(linter.issues)
+	return linter.issues, nil
+}
+
+// moduleFS finds the files of the module the reference names, unpacking it if it is an archive; the returned function
+// removes anything unpacked.
+func (ffapi *Api) moduleFS(reference string) (moduleFS fs.FS, cleanup func(), err error) {
+	if exists, _ := ffapi.Tympan.AFS.Exists(reference); !exists {
+		for _, location := range ffapi.availableModules() {
+			if location.Manifest.Id != reference {
+				continue
+			}
+			if location.Embedded {
+				moduleFS, err = fs.Sub(ffapi.EMFS, location.Path)
+				return moduleFS, func() {}, err
+			}
+			reference = location.Path
+			break
+		}
+	}
+	modulePath, cleanup, err := ffapi.unpackModule(reference)
+	if err != nil {
+		return nil, cleanup, err
+	}
+	return afero.NewIOFS(afero.NewBasePathFs(ffapi.Tympan.AFS.Fs, modulePath)), cleanup, nil
+}
+
+// The moduleLinter collects the issues in a module as it goes; its scratch Api holds the modules the linted module
+// requires and, once they are linted, the linted module's own data and scripts.
+type moduleLinter struct {
+	fs           fs.FS
+	manifest     module.Manifest
+	manifestRoot *yaml.Node
+	scratch      *Api
+	issues       []lint.Issue
+	profiles     []lintedEntry[data.Profile]
+	traits       []lintedEntry[data.Trait]
+	spells       []lintedEntry[data.Spell]
+	prompts      []lintedEntry[data.Prompt]
+	scenarios    []lintedEntry[data.Scenario]
+	companies    []lintedEntry[data.Company]
+	scripts      int
+}
+
+type lintedEntry[T any] struct {
+	lint.Entry[T]
+	File string
+}
+
+func (linter *moduleLinter) report(file string, node *yaml.Node, format string, arguments ...any) {
+	linter.issues = append(linter.issues, lint.At(file, node, format, arguments...))
+}
+
+func (linter *moduleLinter) lintManifest() {
+	file := module.ManifestFileName
+	contents, err := fs.ReadFile(linter.fs, file)
+	if err != nil {
+		linter.report(file, nil, "unable to read the manifest: %s", err)
+		return
+	}
+	root, issues := lint.Parse(file, contents)
+	linter.issues = append(linter.issues, issues...)
+	if len(issues) > 0 {
+		return
+	}
+	linter.manifestRoot = root
+	linter.issues = append(linter.issues, lint.Check(file, root, schema.For(reflect.TypeOf(module.ManifestFile{})))...)
+
+	manifest, err := module.ParseManifest(file, contents)
+	if err != nil {
+		linter.report(file, lint.Find(root, "module"), "%s", err)
+		return
+	}
+	if _, err := module.ParseVersion(manifest.Version); err != nil {
+		linter.report(file, nodeOr(lint.Find(root, "module", "version"), lint.Find(root, "module")), "the module's version is invalid: %s", err)
+	}
+	linter.manifest = manifest
+}
+
+// loadRequirements reports every requirement of the module which none of the available modules meets and loads the
+// modules which do, along with everything they require in turn, into the scratch Api.
+func (linter *moduleLinter) loadRequirements(available []moduleLocation) {
+	byId := make(map[string]moduleLocation)
+	manifests := make(map[string]module.Manifest)
+	for _, location := range available {
+		if location.Manifest.Id == linter.manifest.Id {
+			continue
+		}
+		byId[location.Manifest.Id] = location
+		manifests[location.Manifest.Id] = location.Manifest
+	}
+
+	for _, id := range linter.manifest.RequiredIds() {
+		requirement := module.Manifest{Id: linter.manifest.Id, Requires: map[string]string{id: linter.manifest.Requires[id]}}
+		if err := requirement.CheckRequirements(manifests); err != nil {
+			node := nodeOr(lint.Find(linter.manifestRoot, "module", "requires", id), lint.Find(linter.manifestRoot, "requires", id))
+			linter.report(module.ManifestFileName, node, "%s", err)
+		}
+	}
+
+	var required []module.Manifest
+	seen := make(map[string]bool)
+	pending := linter.manifest.RequiredIds()
+	for len(pending) > 0 {
+		id := pending[0]
+		pending = pending[1:]
+		manifest, found := manifests[id]
+		if seen[id] || !found {
+			continue
+		}
+		seen[id] = true
+		required = append(required, manifest)
+		pending = append(pending, manifest.RequiredIds()...)
+	}
+	ordered, err := module.OrderByRequirements(required)
+	if err != nil {
+		linter.report(module.ManifestFileName, nil, "unable to load the modules this one requires: %s", err)
+		ordered = required
+	}
+	for _, manifest := range ordered {
+		location := byId[manifest.Id]
+		linter.scratch.CacheModuleData(location.Path, location.Embedded)
+	}
+}
+
+// lintDataFile lints one of the module's data files, if it has it, and returns its entries with their source set.
+func lintDataFile[T module.Cachable[T]](linter *moduleLinter, file string) (entries []lintedEntry[T]) {
+	contents, err := fs.ReadFile(linter.fs, file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		linter.report(file, nil, "unable to read the file: %s", err)
+		return nil
+	}
+	decoded, issues := lint.Entries[T](file, contents)
+	linter.issues = append(linter.issues, issues...)
+	for _, entry := range decoded {
+		entry.Data = entry.Data.WithSource(linter.manifest.Id)
+		entries = append(entries, lintedEntry[T]{Entry: entry, File: file})
+	}
+	return entries
+}
+
+func (linter *moduleLinter) lintData() {
+	linter.profiles = lintDataFile[data.Profile](linter, "Profiles.yaml")
+	fs.WalkDir(linter.fs, "Traits", func(file string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || path.Ext(file) != ".yaml" {
+			return nil
+		}
+		subtype := strings.Split(path.Base(file), ".")[0]
+		for _, trait := range lintDataFile[data.Trait](linter, file) {
+			trait.Data = trait.Data.WithSubtype(subtype)
+			linter.traits = append(linter.traits, trait)
+		}
+		return nil
+	})
+	linter.spells = lintDataFile[data.Spell](linter, "Spells.yaml")
+	linter.prompts = lintDataFile[data.Prompt](linter, "Prompts.yaml")
+	linter.scenarios = lintDataFile[data.Scenario](linter, "Scenarios.yaml")
+	linter.companies = lintDataFile[data.Company](linter, "Companies.yaml")
+
+	cache := &linter.scratch.Cache
+	for _, profile := range linter.profiles {
+		cache.Profiles = append(cache.Profiles, profile.Data)
+	}
+	for _, trait := range linter.traits {
+		cache.Traits = append(cache.Traits, trait.Data)
+	}
+	for _, spell := range linter.spells {
+		cache.Spells = append(cache.Spells, spell.Data)
+	}
+	for _, prompt := range linter.prompts {
+		cache.Prompts = append(cache.Prompts, prompt.Data)
+	}
+	for _, scenario := range linter.scenarios {
+		cache.Scenarios = append(cache.Scenarios, scenario.Data)
+	}
+
+	for _, profile := range linter.profiles {
+		linter.lintTraitNames(profile.File, profile.Node, fmt.Sprintf("profile '%s'", profile.Data.Name()), profile.Data.Traits)
+	}
+	linter.lintCompanies()
+	linter.lintCaptainRolls()
+}
+
+// lintTraitNames reports every trait name in the list at the traits key of the node which no trait matches.
+func (linter *moduleLinter) lintTraitNames(file string, node *yaml.Node, owner string, names []string) {
+	for index, name := range names {
+		if data.GetTraitMatchingName(name, linter.scratch.Cache.Traits).Name == "" {
+			linter.report(file, nodeOr(lint.Find(node, "traits", index), node), "%s has trait '%s', which no module defines", owner, name)
+		}
+	}
+}
+
+func (linter *moduleLinter) lintCompanies() {
+	captains := data.FilterTraitsByType("Captain", linter.scratch.Cache.Traits)
+	for _, company := range linter.companies {
+		for index, group := range company.Data.Groups {
+			node := nodeOr(lint.Find(company.Node, "groups", index), company.Node)
+			owner := fmt.Sprintf("group '%s' of company '%s'", group.Name, company.Data.Name)
+			if group.ProfileName == "" {
+				linter.report(company.File, node, "%s has no profileName", owner)
+			} else if _, err := data.GetProfile(group.ProfileName, linter.scratch.Cache.Profiles); err != nil {
+				linter.report(company.File, nodeOr(lint.Find(node, "profileName"), node), "%s has profile '%s', which no module defines", owner, group.ProfileName)
+			}
+			linter.lintTraitNames(company.File, node, owner, group.Traits)
+			if group.Captain.Name != "" && data.GetTraitByName(group.Captain.Name, captains).Name == "" {
+				linter.report(company.File, nodeOr(lint.Find(node, "captain", "name"), node), "%s has captain's trait '%s', which no module defines", owner, group.Captain.Name)
+			}
+		}
+	}
+}
+
+// lintCaptainRolls reports every captain's trait in the module whose roll could not come up on the 3d6 rolled for it
+// or is the same as another captain's trait, whether from this module or one it requires.
+func (linter *moduleLinter) lintCaptainRolls() {
+	taken := make(map[int]data.Trait)
+	for _, trait := range data.FilterTraitsByType("Captain", linter.scratch.Cache.Traits) {
+		if trait.Source != linter.manifest.Id {
+			taken[trait.Roll] = trait
+		}
+	}
+	for _, trait := range linter.traits {
+		if !strings.EqualFold(trait.Data.Type, "Captain") {
+			continue
+		}
+		node := nodeOr(lint.Find(trait.Node, "roll"), trait.Node)
+		roll := trait.Data.Roll
+		if roll < 3 || roll > 18 {
+			linter.report(trait.File, node, "captain's trait '%s' has roll %d, but captain's traits are rolled on 3d6 and need a roll from 3 to 18", trait.Data.Name, roll)
+			continue
+		}
+		if other, found := taken[roll]; found {
+			linter.report(trait.File, node, "captain's trait '%s' has roll %d, the same as '%s' from module '%s'", trait.Data.Name, roll, other.Name, other.Source)
+			continue
+		}
+		taken[roll] = trait.Data
+	}
+}
+
+// lintScripts adds the module's script libraries to the scratch Api, compiling each, and then compiles every snippet
+// of the module's traits, spells, prompts, and scenarios with the same variables they have when they run.
+func (linter *moduleLinter) lintScripts() {
+	libraries := linter.readScripts("scripts/libraries")
+	linter.scratch.Cache.ScriptLibraries = append(linter.scratch.Cache.ScriptLibraries, libraries...)
+	scriptModule := scripting.Module{}
+	moduleFile := fmt.Sprintf("scripts/%s.tengo", linter.manifest.Id)
+	if contents, err := fs.ReadFile(linter.fs, moduleFile); err == nil {
+		scriptModule.Library = scripting.Library{Name: linter.manifest.Id, Body: string(contents)}
+		scriptModule.Submodules = linter.readScripts("scripts/submodules")
+		linter.scratch.Cache.ScriptModules = append(linter.scratch.Cache.ScriptModules, scriptModule)
+		libraries = append(libraries, scriptModule.Library)
+		libraries = append(libraries, scriptModule.Submodules...)
+	}
+	linter.scratch.InitializeEngine()
+
+	for _, library := range libraries {
+		file := moduleFile
+		if library.Name != linter.manifest.Id {
+			file = linter.scriptFile(library.Name)
+		}
+		linter.compile(file, nil, fmt.Sprintf("lint_library := import(\"%s\")", library.Name))
+	}
+
+	bus := skirmish.NewBus(&skirmish.Engine{})
+	for _, trait := range linter.traits {
+		scriptingNode := lint.Find(trait.Node, "scripting")
+		for index, requirement := range trait.Data.Scripting.Requirements {
+			body := data.Trait{Scripting: data.TraitScripting{Requirements: []string{requirement}}}.RequirementsScriptBody()
+			linter.compile(trait.File, nodeOr(lint.Find(scriptingNode, "requirements", index), trait.Node), body, "profile", "base_profile")
+		}
+		for index, change := range trait.Data.Scripting.OnAdd {
+			linter.compile(trait.File, nodeOr(lint.Find(scriptingNode, "on_add", index), trait.Node), change, "group", "choices")
+		}
+		for index, change := range trait.Data.Scripting.OnRemove {
+			linter.compile(trait.File, nodeOr(lint.Find(scriptingNode, "on_remove", index), trait.Node), change, "group", "choices")
+		}
+		linter.compileInPlay(trait.File, nodeOr(lint.Find(scriptingNode, "in_play"), trait.Node), bus, trait.Data.Scripting.InPlay)
+	}
+	for _, spell := range linter.spells {
+		linter.compileInPlay(spell.File, nodeOr(lint.Find(spell.Node, "scripting", "in_play"), spell.Node), bus, spell.Data.Scripting.InPlay)
+	}
+	for _, prompt := range linter.prompts {
+		for index, change := range prompt.Data.Then {
+			body := bus.ScriptBody(data.Prompt{Then: []string{change}}.ScriptBody())
+			linter.compile(prompt.File, nodeOr(lint.Find(prompt.Node, "then", index), prompt.Node), body, skirmish.PromptVariables()...)
+		}
+	}
+	for _, scenario := range linter.scenarios {
+		scoringNode := lint.Find(scenario.Node, "scripting", "scoring")
+		for index, snippet := range scenario.Data.Scripting.Scoring {
+			body := data.Scenario{Scripting: data.ScenarioScripting{Scoring: []string{snippet}}}.ScoringScriptBody()
+			linter.compile(scenario.File, nodeOr(lint.Find(scoringNode, index), nodeOr(scoringNode, scenario.Node)), body, skirmish.ScoringVariables()...)
+		}
+	}
+}
+
+func (linter *moduleLinter) compileInPlay(file string, node *yaml.Node, bus *skirmish.Bus, inPlay []data.TraitScriptingInPlay) {
+	variables := skirmish.ScriptVariables()
+	for index, script := range inPlay {
+		scriptNode := nodeOr(lint.Find(node, index), node)
+		for conditionIndex, condition := range script.When {
+			body := bus.ScriptBody(data.TraitScriptingInPlay{When: []string{condition}}.ScriptBody())
+			linter.compile(file, nodeOr(lint.Find(scriptNode, "when", conditionIndex), scriptNode), body, variables...)
+		}
+		for changeIndex, change := range script.Then {
+			body := bus.ScriptBody(data.TraitScriptingInPlay{Then: []string{change}}.ScriptBody())
+			linter.compile(file, nodeOr(lint.Find(scriptNode, "then", changeIndex), scriptNode), body, variables...)
+		}
+	}
+}
+
+// compile reports the script if it does not compile in the scratch Api's script engine with the variables declared.
+func (linter *moduleLinter) compile(file string, node *yaml.Node, body string, variables ...string) {
+	engine := linter.scratch.ScriptEngine
+	linter.scripts++
+	name := fmt.Sprintf("Lint: %d", linter.scripts)
+	if err := engine.AddScript(name, body); err != nil {
+		linter.report(file, node, "%s", err)
+		return
+	}
+	script := engine.GetScript(name)
+	for _, variable := range variables {
+		script.Add(variable, nil)
+	}
+	if _, err := script.Compile(); err != nil {
+		message, _, _ := strings.Cut(err.Error(), "\n")
+		linter.report(file, node, "script does not compile: %s", message)
+	}
+}
+
+// readScripts reads every tengo file in the folder of the module as a library named for the file.
+func (linter *moduleLinter) readScripts(folder string) (libraries []scripting.Library) {
+	fs.WalkDir(linter.fs, folder, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || path.Ext(file) != ".tengo" {
+			return nil
+		}
+		contents, err := fs.ReadFile(linter.fs, file)
+		if err != nil {
+			linter.report(file, nil, "unable to read the script: %s", err)
+			return nil
+		}
+		libraries = append(libraries, scripting.Library{Name: strings.TrimSuffix(path.Base(file), ".tengo"), Body: string(contents)})
+		return nil
+	})
+	return libraries
+}
+
+func (linter *moduleLinter) scriptFile(name string) string {
+	for _, folder := range []string{"scripts/libraries", "scripts/submodules"} {
+		file := fmt.Sprintf("%s/%s.tengo", folder, name)
+		if _, err := fs.Stat(linter.fs, file); err == nil {
+			return file
+		}
+	}
+	return fmt.Sprintf("scripts/%s.tengo", name)
+}
+
+func nodeOr(node *yaml.Node, fallback *yaml.Node) *yaml.Node {
+	if node != nil {
+		return node
+	}
+	return fallback
+}
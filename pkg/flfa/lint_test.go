@@ -0,0 +1,48 @@
+package flfa
+
+import (
+	"strings"
+	"testing"
+)
+
+// Prompt and scoring scripts only run partway through a skirmish, so lint must compile them with the variables they
+// run with to catch mistakes before anybody plays.
+func TestLintModuleCompilesPromptAndScoringScripts(t *testing.T) {
+	ffapi := moduleTestApi()
+	files := moduleFiles("scouts", "1.0.0", "core")
+	files["Prompts.yaml"] = `entries:
+  - name: Regroup
+    prompt:
+      type: confirmation
+      message: Regroup?
+    then:
+      - if answer { result.regrouped = true }
+      - if answr { result.regrouped = true }
+`
+	files["Scenarios.yaml"] = `entries:
+  - name: Hold the Line
+    scripting:
+      scoring:
+        - if held["Line"] { score += 1 }
+        - score += enemies_routed
+`
+	writeFiles(t, ffapi, "/downloads/scouts", files)
+
+	issues, err := ffapi.LintModule("/downloads/scouts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reported []string
+	for _, issue := range issues {
+		reported = append(reported, issue.String())
+	}
+	if len(issues) != 2 {
+		t.Fatalf("expected an issue for each script which does not compile, got %d:\n%s", len(issues), strings.Join(reported, "\n"))
+	}
+	if issues[0].File != "Prompts.yaml" || !strings.Contains(issues[0].Message, "answr") {
+		t.Errorf("expected the prompt's misspelled answer to be reported, got %s", reported[0])
+	}
+	if issues[1].File != "Scenarios.yaml" || !strings.Contains(issues[1].Message, "enemies_routed") {
+		t.Errorf("expected the scenario's unknown variable to be reported, got %s", reported[1])
+	}
+}
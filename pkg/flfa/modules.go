@@ -0,0 +1,369 @@
+package flfa
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/FlagrantGarden/flfa/pkg/tympan/module"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/utils"
+)
+
+// A ModuleListing describes a module the application can load: its manifest, where it is loaded from, and whether it
+// is enabled. Modules are enabled unless their id is in the configuration's disabled_modules.
+type ModuleListing struct {
+	Manifest module.Manifest
+	Path     string
+	Embedded bool
+	Enabled  bool
+}
+
+// ListModules returns every module the application can load, in the order they are found: the embedded core module
+// unless a core module is installed, then the installed modules.
+func (ffapi *Api) ListModules() (listings []ModuleListing) {
+	for _, location := range ffapi.availableModules() {
+		listings = append(listings, ModuleListing{
+			Manifest: location.Manifest,
+			Path:     location.Path,
+			Embedded: location.Embedded,
+			Enabled:  !utils.Contains(ffapi.Tympan.Configuration.DisabledModules, location.Manifest.Id),
+		})
+	}
+	return listings
+}
+
+// InstallModule validates the module in the folder or archive (.zip, .tar, .tar.gz, or .tgz) at the path and copies it
+// into the module folder, in a folder named for its id. It returns the module's manifest, or an error if the module
+// has no valid manifest, has any issues LintModule reports, is already installed, or requires modules which are not
+// installed.
+func (ffapi *Api) InstallModule(sourcePath string) (module.Manifest, error) {
+	return ffapi.installModule(sourcePath, false)
+}
+
+// UpdateModule replaces an installed module with the module in the folder or archive at the path, validating it just
+// like InstallModule. It returns an error if the module is not installed or if the update would leave any installed
+// module's requirements unmet.
+func (ffapi *Api) UpdateModule(sourcePath string) (module.Manifest, error) {
+	return ffapi.installModule(sourcePath, true)
+}
+
+// RemoveModule deletes the installed module with the id. It returns an error if no installed module has the id or if
+// another module requires it.
+func (ffapi *Api) RemoveModule(id string) error {
+	errorPrefix := fmt.Sprintf("unable to remove module '%s'", id)
+
+	available := ffapi.availableModules()
+	installed, found := findInstalledModule(id, available)
+	if !found {
+		return fmt.Errorf("%s: it is not installed", errorPrefix)
+	}
+
+	var remaining []module.Manifest
+	for _, location := range available {
+		if location.Manifest.Id != id {
+			remaining = append(remaining, location.Manifest)
+		}
+	}
+	// Without an installed core module, the embedded one is loaded in its place
+	if id == "core" {
+		if manifest, err := ffapi.ReadManifest("modules/core", true); err == nil {
+			remaining = append(remaining, manifest)
+		}
+	}
+	if _, err := module.OrderByRequirements(remaining); err != nil {
+		return fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+
+	if err := ffapi.Tympan.AFS.RemoveAll(installed.Path); err != nil {
+		return fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	return nil
+}
+
+func (ffapi *Api) installModule(sourcePath string, replace bool) (manifest module.Manifest, err error) {
+	errorPrefix := fmt.Sprintf("unable to install module from '%s'", sourcePath)
+	if replace {
+		errorPrefix = fmt.Sprintf("unable to update module from '%s'", sourcePath)
+	}
+
+	modulePath, cleanup, err := ffapi.unpackModule(sourcePath)
+	if err != nil {
+		return manifest, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	defer cleanup()
+
+	manifest, err = module.GetManifest(modulePath, ffapi.Tympan.AFS)
+	if err != nil {
+		return manifest, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	if err = validateModuleManifest(manifest); err != nil {
+		return manifest, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	issues, err := ffapi.LintModule(modulePath)
+	if err != nil {
+		return manifest, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	if len(issues) > 0 {
+		var lines []string
+		for _, issue := range issues {
+			lines = append(lines, issue.String())
+		}
+		return manifest, fmt.Errorf("%s: module '%s' has %d issues:\n%s", errorPrefix, manifest.Id, len(issues), strings.Join(lines, "\n"))
+	}
+
+	available := ffapi.availableModules()
+	installed, found := findInstalledModule(manifest.Id, available)
+	destination := filepath.Join(ffapi.ModuleFolderPath(), manifest.Id)
+	switch {
+	case found && !replace:
+		return manifest, fmt.Errorf("%s: module '%s' is already installed; update it instead", errorPrefix, manifest.Id)
+	case !found && replace:
+		return manifest, fmt.Errorf("%s: module '%s' is not installed; install it instead", errorPrefix, manifest.Id)
+	case found:
+		destination = installed.Path
+	default:
+		exists, err := ffapi.Tympan.AFS.Exists(destination)
+		if err != nil {
+			return manifest, fmt.Errorf("%s: %s", errorPrefix, err)
+		}
+		if exists {
+			return manifest, fmt.Errorf("%s: '%s' already exists", errorPrefix, destination)
+		}
+	}
+
+	manifests := []module.Manifest{manifest}
+	for _, location := range available {
+		if location.Manifest.Id != manifest.Id {
+			manifests = append(manifests, location.Manifest)
+		}
+	}
+	if _, err = module.OrderByRequirements(manifests); err != nil {
+		return manifest, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+
+	// Copy the module beside the module folder first so a failed copy never leaves a partial module to be loaded
+	if err = ffapi.Tympan.AFS.MkdirAll(ffapi.ModuleFolderPath(), ffapi.folderPermissions()); err != nil {
+		return manifest, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	staging, err := ffapi.Tympan.AFS.TempDir(ffapi.Tympan.Configuration.FolderPaths.Cache, "module-")
+	if err != nil {
+		return manifest, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	defer ffapi.Tympan.AFS.RemoveAll(staging)
+	staged := filepath.Join(staging, manifest.Id)
+	if err = ffapi.copyFolder(modulePath, staged); err != nil {
+		return manifest, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	if found {
+		if err = ffapi.Tympan.AFS.RemoveAll(destination); err != nil {
+			return manifest, fmt.Errorf("%s: %s", errorPrefix, err)
+		}
+	}
+	if err = ffapi.moveFolder(staged, destination); err != nil {
+		return manifest, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	return manifest, nil
+}
+
+// validateModuleManifest makes sure an installed module can be found by its id and compared by its version.
+func validateModuleManifest(manifest module.Manifest) error {
+	if manifest.Id != filepath.Base(manifest.Id) || manifest.Id == "." || manifest.Id == ".." {
+		return fmt.Errorf("module id '%s' cannot be used as a folder name", manifest.Id)
+	}
+	if _, err := module.ParseVersion(manifest.Version); err != nil {
+		return fmt.Errorf("module '%s' has an invalid version: %s", manifest.Id, err)
+	}
+	return nil
+}
+
+func findInstalledModule(id string, locations []moduleLocation) (moduleLocation, bool) {
+	for _, location := range locations {
+		if !location.Embedded && location.Manifest.Id == id {
+			return location, true
+		}
+	}
+	return moduleLocation{}, false
+}
+
+func (ffapi *Api) folderPermissions() fs.FileMode {
+	if ffapi.Tympan.Metadata.DefaultPermissions == 0 {
+		return 0755
+	}
+	return ffapi.Tympan.Metadata.DefaultPermissions
+}
+
+// unpackModule returns the path to the root folder of the module at the source path. A folder is used as-is; an
+// archive is extracted to a temporary folder, which the returned function removes. The root of an archive is either
+// the archive itself or, if the archive holds only a folder, that folder.
+func (ffapi *Api) unpackModule(sourcePath string) (modulePath string, cleanup func(), err error) {
+	cleanup = func() {}
+	info, err := ffapi.Tympan.AFS.Stat(sourcePath)
+	if err != nil {
+		return "", cleanup, err
+	}
+	if info.IsDir() {
+		return sourcePath, cleanup, nil
+	}
+
+	extractPath, err := ffapi.Tympan.AFS.TempDir("", "flfa-module-")
+	if err != nil {
+		return "", cleanup, err
+	}
+	cleanup = func() { ffapi.Tympan.AFS.RemoveAll(extractPath) }
+
+	lowerPath := strings.ToLower(sourcePath)
+	switch {
+	case strings.HasSuffix(lowerPath, ".zip"):
+		err = ffapi.extractZip(sourcePath, info.Size(), extractPath)
+	case strings.HasSuffix(lowerPath, ".tar.gz"), strings.HasSuffix(lowerPath, ".tgz"):
+		err = ffapi.extractTar(sourcePath, true, extractPath)
+	case strings.HasSuffix(lowerPath, ".tar"):
+		err = ffapi.extractTar(sourcePath, false, extractPath)
+	default:
+		err = fmt.Errorf("expected a folder or a .zip, .tar, .tar.gz, or .tgz archive")
+	}
+	if err != nil {
+		return "", cleanup, err
+	}
+
+	if exists, _ := ffapi.Tympan.AFS.Exists(filepath.Join(extractPath, module.ManifestFileName)); exists {
+		return extractPath, cleanup, nil
+	}
+	items, err := ffapi.Tympan.AFS.ReadDir(extractPath)
+	if err != nil {
+		return "", cleanup, err
+	}
+	if len(items) == 1 && items[0].IsDir() {
+		return filepath.Join(extractPath, items[0].Name()), cleanup, nil
+	}
+	return "", cleanup, fmt.Errorf("archive has no %s at its root or in a single folder", module.ManifestFileName)
+}
+
+func (ffapi *Api) extractZip(archivePath string, size int64, extractPath string) error {
+	archive, err := ffapi.Tympan.AFS.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	reader, err := zip.NewReader(archive, size)
+	if err != nil {
+		return err
+	}
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		contents, err := file.Open()
+		if err != nil {
+			return err
+		}
+		err = ffapi.extractFile(extractPath, file.Name, file.Mode(), contents)
+		contents.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ffapi *Api) extractTar(archivePath string, compressed bool, extractPath string) error {
+	archive, err := ffapi.Tympan.AFS.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	var stream io.Reader = archive
+	if compressed {
+		gzipReader, err := gzip.NewReader(archive)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		stream = gzipReader
+	}
+	reader := tar.NewReader(stream)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := ffapi.extractFile(extractPath, header.Name, header.FileInfo().Mode(), reader); err != nil {
+			return err
+		}
+	}
+}
+
+// extractFile writes one file from an archive into the extraction folder, refusing any whose name would place it
+// outside of that folder.
+func (ffapi *Api) extractFile(extractPath string, name string, mode fs.FileMode, contents io.Reader) error {
+	relativePath := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(relativePath) || relativePath == ".." || strings.HasPrefix(relativePath, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("archive entry '%s' is outside of the module", name)
+	}
+	filePath := filepath.Join(extractPath, relativePath)
+	if err := ffapi.Tympan.AFS.MkdirAll(filepath.Dir(filePath), ffapi.folderPermissions()); err != nil {
+		return err
+	}
+	file, err := ffapi.Tympan.AFS.OpenFile(filePath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode.Perm()|0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(file, contents)
+	return err
+}
+
+// moveFolder moves every file below the source path to the destination path one at a time, as not every afero file
+// system moves the contents of a folder when it is renamed.
+func (ffapi *Api) moveFolder(sourcePath string, destinationPath string) error {
+	return ffapi.Tympan.AFS.Walk(sourcePath, func(itemPath string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(sourcePath, itemPath)
+		if err != nil {
+			return err
+		}
+		targetPath := filepath.Join(destinationPath, relativePath)
+		if info.IsDir() {
+			return ffapi.Tympan.AFS.MkdirAll(targetPath, ffapi.folderPermissions())
+		}
+		return ffapi.Tympan.AFS.Rename(itemPath, targetPath)
+	})
+}
+
+// copyFolder copies every folder and file below the source path to the destination path.
+func (ffapi *Api) copyFolder(sourcePath string, destinationPath string) error {
+	return ffapi.Tympan.AFS.Walk(sourcePath, func(itemPath string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(sourcePath, itemPath)
+		if err != nil {
+			return err
+		}
+		targetPath := filepath.Join(destinationPath, relativePath)
+		if info.IsDir() {
+			return ffapi.Tympan.AFS.MkdirAll(targetPath, ffapi.folderPermissions())
+		}
+		contents, err := ffapi.Tympan.AFS.ReadFile(itemPath)
+		if err != nil {
+			return err
+		}
+		return ffapi.Tympan.AFS.WriteFile(targetPath, contents, info.Mode().Perm())
+	})
+}
@@ -0,0 +1,216 @@
+package flfa
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/FlagrantGarden/flfa/emfs"
+	"github.com/FlagrantGarden/flfa/pkg/tympan"
+	"github.com/spf13/afero"
+)
+
+// moduleTestApi returns an Api whose files are all in memory, with the embedded core module available.
+func moduleTestApi() *Api {
+	configuration := &Configuration{}
+	configuration.FolderPaths.Cache = "/cache"
+	return &Api{
+		Tympan: &tympan.Tympan[*Configuration]{AFS: &afero.Afero{Fs: afero.NewMemMapFs()}, Configuration: configuration},
+		EMFS:   &emfs.EmbeddedModulesFS,
+	}
+}
+
+// moduleFiles returns the files of a module with the id and version which requires the modules in requires.
+func moduleFiles(id string, version string, requires ...string) map[string]string {
+	manifest := fmt.Sprintf("module:\n  id: %s\n  version: \"%s\"\n", id, version)
+	if len(requires) > 0 {
+		manifest += "requires:\n"
+		for _, requirement := range requires {
+			manifest += fmt.Sprintf("  %s: \">= 0.1.0\"\n", requirement)
+		}
+	}
+	return map[string]string{
+		"Module.yaml":   manifest,
+		"Profiles.yaml": "entries:\n  - type: Scouts\n    category: Infantry\n    move:\n      activation: 5\n      distance: 8\n",
+	}
+}
+
+func writeFiles(t *testing.T, ffapi *Api, folder string, files map[string]string) {
+	t.Helper()
+	for name, contents := range files {
+		path := filepath.Join(folder, filepath.FromSlash(name))
+		if err := ffapi.Tympan.AFS.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ffapi.Tympan.AFS.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func zipFiles(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	for name, contents := range files {
+		file, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Write([]byte(contents))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func tarGzFiles(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	compressor := gzip.NewWriter(&buffer)
+	writer := tar.NewWriter(compressor)
+	for name, contents := range files {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(contents)), Typeflag: tar.TypeReg}
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		writer.Write([]byte(contents))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := compressor.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// inFolder puts the files in a folder, as archives of a module usually hold them.
+func inFolder(folder string, files map[string]string) map[string]string {
+	nested := make(map[string]string)
+	for name, contents := range files {
+		nested[folder+"/"+name] = contents
+	}
+	return nested
+}
+
+func TestInstallModule(t *testing.T) {
+	for _, source := range []struct {
+		name  string
+		path  string
+		write func(t *testing.T, ffapi *Api, path string)
+	}{
+		{"folder", "/downloads/scouts", func(t *testing.T, ffapi *Api, path string) {
+			writeFiles(t, ffapi, path, moduleFiles("scouts", "1.0.0"))
+		}},
+		{"zip", "/downloads/scouts.zip", func(t *testing.T, ffapi *Api, path string) {
+			ffapi.Tympan.AFS.WriteFile(path, zipFiles(t, inFolder("scouts-1.0.0", moduleFiles("scouts", "1.0.0"))), 0644)
+		}},
+		{"tar.gz", "/downloads/scouts.tar.gz", func(t *testing.T, ffapi *Api, path string) {
+			ffapi.Tympan.AFS.WriteFile(path, tarGzFiles(t, moduleFiles("scouts", "1.0.0")), 0644)
+		}},
+	} {
+		t.Run(source.name, func(t *testing.T) {
+			ffapi := moduleTestApi()
+			ffapi.Tympan.AFS.MkdirAll("/downloads", 0755)
+			source.write(t, ffapi, source.path)
+
+			manifest, err := ffapi.InstallModule(source.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if manifest.Id != "scouts" {
+				t.Errorf("expected to install module 'scouts', got '%s'", manifest.Id)
+			}
+			installed := filepath.Join(ffapi.ModuleFolderPath(), "scouts")
+			for _, file := range []string{"Module.yaml", "Profiles.yaml"} {
+				if exists, _ := ffapi.Tympan.AFS.Exists(filepath.Join(installed, file)); !exists {
+					t.Errorf("expected %s to be installed in '%s'", file, installed)
+				}
+			}
+
+			if _, err := ffapi.InstallModule(source.path); err == nil || !strings.Contains(err.Error(), "already installed") {
+				t.Errorf("expected installing the module again to fail as it is already installed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestInstallModuleRefusesEntriesOutsideTheModule(t *testing.T) {
+	ffapi := moduleTestApi()
+	files := moduleFiles("scouts", "1.0.0")
+	files["../escaped.yaml"] = "entries: []\n"
+	ffapi.Tympan.AFS.MkdirAll("/downloads", 0755)
+	ffapi.Tympan.AFS.WriteFile("/downloads/scouts.zip", zipFiles(t, files), 0644)
+
+	if _, err := ffapi.InstallModule("/downloads/scouts.zip"); err == nil || !strings.Contains(err.Error(), "outside of the module") {
+		t.Errorf("expected an entry with a '..' path to be refused, got %v", err)
+	}
+	if exists, _ := ffapi.Tympan.AFS.Exists(filepath.Join(ffapi.ModuleFolderPath(), "scouts")); exists {
+		t.Error("expected the module not to be installed")
+	}
+}
+
+func TestInstallModuleRefusesModulesWithLintIssues(t *testing.T) {
+	ffapi := moduleTestApi()
+	files := moduleFiles("scouts", "1.0.0")
+	files["Profiles.yaml"] = "entries:\n  - type: Scouts\n    moves: 8\n"
+	writeFiles(t, ffapi, "/downloads/scouts", files)
+
+	if _, err := ffapi.InstallModule("/downloads/scouts"); err == nil || !strings.Contains(err.Error(), "Profiles.yaml") {
+		t.Errorf("expected the module to be refused for the issues in its Profiles.yaml, got %v", err)
+	}
+	if exists, _ := ffapi.Tympan.AFS.Exists(filepath.Join(ffapi.ModuleFolderPath(), "scouts")); exists {
+		t.Error("expected the module not to be installed")
+	}
+}
+
+func TestUpdateModule(t *testing.T) {
+	ffapi := moduleTestApi()
+	writeFiles(t, ffapi, "/downloads/scouts", moduleFiles("scouts", "1.1.0"))
+	if _, err := ffapi.UpdateModule("/downloads/scouts"); err == nil || !strings.Contains(err.Error(), "not installed") {
+		t.Fatalf("expected updating a module which is not installed to fail, got %v", err)
+	}
+
+	writeFiles(t, ffapi, filepath.Join(ffapi.ModuleFolderPath(), "scouts"), moduleFiles("scouts", "1.0.0"))
+	manifest, err := ffapi.UpdateModule("/downloads/scouts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	installed, err := ffapi.ReadManifest(filepath.Join(ffapi.ModuleFolderPath(), "scouts"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Version != "1.1.0" || installed.Version != "1.1.0" {
+		t.Errorf("expected version 1.1.0 to be installed, got %s", installed.Version)
+	}
+}
+
+func TestRemoveModule(t *testing.T) {
+	ffapi := moduleTestApi()
+	writeFiles(t, ffapi, filepath.Join(ffapi.ModuleFolderPath(), "scouts"), moduleFiles("scouts", "1.0.0"))
+	writeFiles(t, ffapi, filepath.Join(ffapi.ModuleFolderPath(), "rangers"), moduleFiles("rangers", "1.0.0", "scouts"))
+
+	if err := ffapi.RemoveModule("scouts"); err == nil || !strings.Contains(err.Error(), "scouts") {
+		t.Errorf("expected removing a module another requires to fail, got %v", err)
+	}
+	if exists, _ := ffapi.Tympan.AFS.Exists(filepath.Join(ffapi.ModuleFolderPath(), "scouts")); !exists {
+		t.Fatal("expected the required module to be left installed")
+	}
+
+	if err := ffapi.RemoveModule("rangers"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ffapi.RemoveModule("scouts"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ffapi.RemoveModule("scouts"); err == nil || !strings.Contains(err.Error(), "not installed") {
+		t.Errorf("expected removing a module which is not installed to fail, got %v", err)
+	}
+}
@@ -1,9 +1,12 @@
 package flfa
 
 import (
+	"fmt"
+	"path"
 	"path/filepath"
 	"strings"
 
+	"github.com/FlagrantGarden/flfa/pkg/tympan/module"
 	"github.com/FlagrantGarden/flfa/pkg/tympan/utils"
 	"github.com/rs/zerolog/log"
 )
@@ -14,21 +17,76 @@ func (ffapi *Api) InitializeGameState() error {
 		return err
 	}
 
-	log.Trace().Msgf("Loading module data from %s", ffapi.Tympan.Configuration.FolderPaths.Cache)
+	log.Trace().Msgf("Loading module data from %s", ffapi.ModuleFolderPath())
+	var locations []moduleLocation
+	for _, location := range ffapi.availableModules() {
+		if utils.Contains(ffapi.Tympan.Configuration.DisabledModules, location.Manifest.Id) {
+			log.Trace().Msgf("Skipping disabled module %s", location.Manifest.Id)
+			continue
+		}
+		locations = append(locations, location)
+	}
+	ordered, err := orderModules(locations)
+	if err != nil {
+		return fmt.Errorf("unable to load modules: %s", err)
+	}
+	for _, location := range ordered {
+		ffapi.CacheModuleData(location.Path, location.Embedded)
+	}
+	log.Trace().Msgf("Caching personas from %s", ffapi.Tympan.Configuration.FolderPaths.Cache)
+	ffapi.CachePlayers("")
+
+	ffapi.InitializeEngine()
+	ffapi.InitializeCompanies()
+	return nil
+}
+
+type moduleLocation struct {
+	Path     string
+	Embedded bool
+	Manifest module.Manifest
+}
+
+// availableModules finds every module the application could load along with its manifest: those installed in the
+// module folder and, unless a core module is installed, the embedded core module. A module without a usable manifest
+// has the name of its folder as its id and no requirements.
+func (ffapi *Api) availableModules() (locations []moduleLocation) {
 	installedModules, err := ffapi.InstalledModules()
 	if err != nil {
-		log.Error().Msgf("error initializing game; unable to list installed modules: %s", err)
+		log.Error().Msgf("unable to list installed modules: %s", err)
 	}
 	log.Trace().Msgf("Installed modules: %s", strings.Join(installedModules, ", "))
 	if !utils.Contains(installedModules, "core") {
-		ffapi.CacheModuleData("modules/core", true)
+		locations = append(locations, moduleLocation{Path: "modules/core", Embedded: true})
 	}
 	for _, module := range installedModules {
-		ffapi.CacheModuleData(filepath.Join(ffapi.Tympan.Configuration.FolderPaths.Application, "modules", module), false)
+		locations = append(locations, moduleLocation{Path: filepath.Join(ffapi.ModuleFolderPath(), module)})
 	}
-	log.Trace().Msgf("Caching personas from %s", ffapi.Tympan.Configuration.FolderPaths.Cache)
-	ffapi.CachePlayers("")
+	for index, location := range locations {
+		location.Manifest, err = ffapi.ReadManifest(location.Path, location.Embedded)
+		if err != nil {
+			location.Manifest = module.Manifest{Id: path.Base(filepath.ToSlash(location.Path))}
+		}
+		locations[index] = location
+	}
+	return locations
+}
 
-	ffapi.InitializeEngine()
-	return nil
+// orderModules returns the modules in the order they must be cached, each after the modules it requires; it returns
+// an error if any requirement cannot be met.
+func orderModules(locations []moduleLocation) (ordered []moduleLocation, err error) {
+	var manifests []module.Manifest
+	byId := make(map[string]moduleLocation)
+	for _, location := range locations {
+		manifests = append(manifests, location.Manifest)
+		byId[location.Manifest.Id] = location
+	}
+	manifests, err = module.OrderByRequirements(manifests)
+	if err != nil {
+		return nil, err
+	}
+	for _, manifest := range manifests {
+		ordered = append(ordered, byId[manifest.Id])
+	}
+	return ordered, nil
 }
@@ -0,0 +1,54 @@
+package flfa
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/module"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/module/schema"
+)
+
+// A ModuleSchema names one of the kinds of data a module may define, the file in a module it is stored in, and the Go
+// type it is decoded into. The data files store a list of entries; the others are a single value.
+type ModuleSchema struct {
+	Name     string
+	File     string
+	DataFile bool
+	Type     reflect.Type
+}
+
+// ModuleSchemas lists every kind of data a module may define which has a schema.
+func ModuleSchemas() []ModuleSchema {
+	return []ModuleSchema{
+		{Name: "manifest", File: module.ManifestFileName, Type: reflect.TypeOf(module.ManifestFile{})},
+		{Name: "profile", File: "Profiles.yaml", DataFile: true, Type: reflect.TypeOf(data.Profile{})},
+		{Name: "trait", File: "Traits/*.yaml", DataFile: true, Type: reflect.TypeOf(data.Trait{})},
+		{Name: "scripting", File: "the scripting of a trait", Type: reflect.TypeOf(data.TraitScripting{})},
+		{Name: "spell", File: "Spells.yaml", DataFile: true, Type: reflect.TypeOf(data.Spell{})},
+		{Name: "prompt", File: "Prompts.yaml", DataFile: true, Type: reflect.TypeOf(data.Prompt{})},
+		{Name: "scenario", File: "Scenarios.yaml", DataFile: true, Type: reflect.TypeOf(data.Scenario{})},
+		{Name: "company", File: "Companies.yaml", DataFile: true, Type: reflect.TypeOf(data.Company{})},
+	}
+}
+
+// Schema generates the JSON Schema for the kind of data; for a data file, it is the schema of the whole file.
+func (moduleSchema ModuleSchema) Schema() *schema.Schema {
+	if moduleSchema.DataFile {
+		return schema.Entries(moduleSchema.Type)
+	}
+	return schema.For(moduleSchema.Type)
+}
+
+// GetModuleSchema returns the ModuleSchema with the name, matched without regard to case.
+func GetModuleSchema(name string) (ModuleSchema, error) {
+	var names []string
+	for _, moduleSchema := range ModuleSchemas() {
+		if strings.EqualFold(moduleSchema.Name, name) {
+			return moduleSchema, nil
+		}
+		names = append(names, moduleSchema.Name)
+	}
+	return ModuleSchema{}, fmt.Errorf("no schema for '%s'; must be one of: %s", name, strings.Join(names, ", "))
+}
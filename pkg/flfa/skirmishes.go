@@ -0,0 +1,158 @@
+package flfa
+
+import (
+	"fmt"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/state/player"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/state/skirmish"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/state"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/state/instance"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/state/persona"
+	"github.com/rs/zerolog/log"
+)
+
+// skirmishInstance returns the instance for the named skirmish of the persona, ready to load or save, whether or not it
+// has been saved before.
+func (ffapi *Api) skirmishInstance(name string, userPersona *persona.Persona[player.Data, player.Settings], cachePath string) (*instance.Instance[skirmish.Skirmish], error) {
+	if cachePath == "" {
+		cachePath = ffapi.Tympan.Configuration.FolderPaths.Cache
+	}
+	saved := &instance.Instance[skirmish.Skirmish]{
+		Name:    name,
+		Kind:    *skirmish.Kind(),
+		Persona: instance.Persona{Name: userPersona.Name, Kind: userPersona.Kind},
+		Handle:  &state.Handle{},
+	}
+	err := saved.Handle.Initialize(name, saved.FolderPath(cachePath), ffapi.Tympan.AFS)
+	return saved, err
+}
+
+// CreateSkirmish saves a new skirmish for the persona and makes it their active skirmish. The persona may already list
+// a skirmish by that name, like the default one every persona starts with, so long as it has not been saved yet.
+func (ffapi *Api) CreateSkirmish(name string, game skirmish.Skirmish, configuration player.SkirmishConfiguration, userPersona *persona.Persona[player.Data, player.Settings], cachePath string) (*instance.Instance[skirmish.Skirmish], error) {
+	errorPrefix := fmt.Sprintf("unable to create skirmish '%s'", name)
+	saved, err := ffapi.skirmishInstance(name, userPersona, cachePath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	exists, err := ffapi.Tympan.AFS.Exists(saved.Handle.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	if exists {
+		return nil, fmt.Errorf("%s: persona '%s' already has a skirmish by that name", errorPrefix, userPersona.Name)
+	}
+
+	saved.Data = game
+	if err := saved.Save(ffapi.Tympan.AFS); err != nil {
+		return nil, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+
+	if entry, ok := userPersona.Settings.Skirmish(name); ok {
+		entry.Archived = false
+		entry.Configuration = configuration
+	} else {
+		userPersona.Settings.Skirmishes = append(userPersona.Settings.Skirmishes, player.Skirmish{Name: name, Configuration: configuration})
+	}
+	userPersona.Settings.ActiveSkirmish = name
+	if err := userPersona.Save(ffapi.Tympan.AFS); err != nil {
+		return nil, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+
+	return saved, nil
+}
+
+// SwitchSkirmish makes the named skirmish the persona's active skirmish, restoring it if it was archived.
+func (ffapi *Api) SwitchSkirmish(name string, userPersona *persona.Persona[player.Data, player.Settings]) error {
+	entry, ok := userPersona.Settings.Skirmish(name)
+	if !ok {
+		return fmt.Errorf("unable to switch to skirmish '%s': persona '%s' has no skirmish by that name", name, userPersona.Name)
+	}
+	entry.Archived = false
+	userPersona.Settings.ActiveSkirmish = name
+	if err := userPersona.Save(ffapi.Tympan.AFS); err != nil {
+		return fmt.Errorf("unable to switch to skirmish '%s': %s", name, err)
+	}
+	return nil
+}
+
+// ArchiveSkirmish sets the named skirmish aside without deleting it, so it can still be replayed. If it was the active
+// skirmish, the persona has no active skirmish until they switch to or create another.
+func (ffapi *Api) ArchiveSkirmish(name string, userPersona *persona.Persona[player.Data, player.Settings]) error {
+	entry, ok := userPersona.Settings.Skirmish(name)
+	if !ok {
+		return fmt.Errorf("unable to archive skirmish '%s': persona '%s' has no skirmish by that name", name, userPersona.Name)
+	}
+	entry.Archived = true
+	if userPersona.Settings.ActiveSkirmish == name {
+		userPersona.Settings.ActiveSkirmish = ""
+	}
+	if err := userPersona.Save(ffapi.Tympan.AFS); err != nil {
+		return fmt.Errorf("unable to archive skirmish '%s': %s", name, err)
+	}
+	return nil
+}
+
+// DeleteSkirmish removes the named skirmish from the persona and deletes its save, if it has one.
+func (ffapi *Api) DeleteSkirmish(name string, userPersona *persona.Persona[player.Data, player.Settings], cachePath string) error {
+	errorPrefix := fmt.Sprintf("unable to delete skirmish '%s'", name)
+	if _, ok := userPersona.Settings.Skirmish(name); !ok {
+		return fmt.Errorf("%s: persona '%s' has no skirmish by that name", errorPrefix, userPersona.Name)
+	}
+	saved, err := ffapi.skirmishInstance(name, userPersona, cachePath)
+	if err != nil {
+		return fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	exists, err := ffapi.Tympan.AFS.Exists(saved.Handle.FilePath)
+	if err != nil {
+		return fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	if exists {
+		if err := ffapi.Tympan.AFS.Remove(saved.Handle.FilePath); err != nil {
+			return fmt.Errorf("%s: %s", errorPrefix, err)
+		}
+	}
+
+	userPersona.Settings.RemoveSkirmish(name)
+	if err := userPersona.Save(ffapi.Tympan.AFS); err != nil {
+		return fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	return nil
+}
+
+// SkirmishAutosave returns an option for a skirmish engine to save the skirmish after every resolved action, including
+// undoing one, if the persona has autosave turned on for it. Failing to save is logged rather than ending play.
+func (ffapi *Api) SkirmishAutosave(saved *instance.Instance[skirmish.Skirmish], userPersona *persona.Persona[player.Data, player.Settings]) skirmish.Option {
+	return skirmish.WithUpdateHook(func(game *skirmish.Skirmish, update skirmish.Update) {
+		entry, ok := userPersona.Settings.Skirmish(saved.Name)
+		if !ok || !entry.Configuration.Autosave {
+			return
+		}
+		saved.Data = *game
+		if err := saved.Save(ffapi.Tympan.AFS); err != nil {
+			log.Warn().Msgf("unable to autosave skirmish '%s': %s", saved.Name, err)
+		}
+	})
+}
+
+// FindCompany returns the named company, looking first at the persona's own companies and then at those from modules.
+// Without a persona, only the companies from modules are searched.
+func (ffapi *Api) FindCompany(name string, userPersona *persona.Persona[player.Data, player.Settings]) (data.Company, error) {
+	if userPersona != nil {
+		for _, company := range userPersona.Data.Companies {
+			if company.Name == name {
+				return company, nil
+			}
+		}
+	}
+	for _, company := range ffapi.Cache.Companies {
+		if company.Name == name {
+			return company, nil
+		}
+	}
+	if userPersona == nil {
+		return data.Company{}, fmt.Errorf("unable to find company '%s' in any module", name)
+	}
+	return data.Company{}, fmt.Errorf("unable to find company '%s' for persona '%s' or in any module", name, userPersona.Name)
+}
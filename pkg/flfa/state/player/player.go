@@ -4,6 +4,7 @@ import (
 	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
 	"github.com/FlagrantGarden/flfa/pkg/tympan/state"
 	"github.com/FlagrantGarden/flfa/pkg/tympan/state/persona"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/utils"
 )
 
 type Player struct {
@@ -16,7 +17,9 @@ type Settings struct {
 }
 
 type Skirmish struct {
-	Name          string
+	Name string
+	// Archived skirmishes are kept on disk to replay but are not played and cannot be active.
+	Archived      bool
 	Configuration SkirmishConfiguration
 }
 
@@ -24,6 +27,29 @@ type SkirmishConfiguration struct {
 	Autosave bool
 }
 
+// Skirmish returns the player's entry for the named skirmish, if they have one.
+func (playerSettings *Settings) Skirmish(name string) (*Skirmish, bool) {
+	for index := range playerSettings.Skirmishes {
+		if playerSettings.Skirmishes[index].Name == name {
+			return &playerSettings.Skirmishes[index], true
+		}
+	}
+	return nil, false
+}
+
+// RemoveSkirmish removes the player's entry for the named skirmish; if it was active, no skirmish is active afterward.
+func (playerSettings *Settings) RemoveSkirmish(name string) {
+	for index, entry := range playerSettings.Skirmishes {
+		if entry.Name == name {
+			playerSettings.Skirmishes = utils.RemoveIndex(playerSettings.Skirmishes, index)
+			break
+		}
+	}
+	if playerSettings.ActiveSkirmish == name {
+		playerSettings.ActiveSkirmish = ""
+	}
+}
+
 func (playerSettings Settings) Initialize() *Settings {
 	// Check if empty; for now, the implementation is such that UserSettings should always have an ActiveSkirmish,
 	// so just verify that it isn't nil and, if it is, create the struct and initialize it.
@@ -0,0 +1,475 @@
+package skirmish
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/d5/tengo/v2"
+	"github.com/rs/zerolog/log"
+)
+
+type Order string
+
+const (
+	OrderMove   Order = "Move"
+	OrderAttack Order = "Attack"
+	OrderShoot  Order = "Shoot"
+	OrderRally  Order = "Rally"
+	OrderCast   Order = "Cast"
+)
+
+// An ActivationOption is an order a Group may be given, the number it needs to roll on 2d6 to activate for it, and the
+// ids of the Groups it may target, if the order needs a target.
+type ActivationOption struct {
+	Order   Order
+	Target  int
+	Targets []string
+}
+
+type ActivationOptions []ActivationOption
+
+func (options ActivationOptions) Orders() (orders []string) {
+	for _, option := range options {
+		orders = append(orders, string(option.Order))
+	}
+	return orders
+}
+
+func (options ActivationOptions) Find(order Order) (ActivationOption, bool) {
+	for _, option := range options {
+		if strings.EqualFold(string(option.Order), string(order)) {
+			return option, true
+		}
+	}
+	return ActivationOption{}, false
+}
+
+func (options ActivationOptions) Without(order Order) (filtered ActivationOptions) {
+	for _, option := range options {
+		if !strings.EqualFold(string(option.Order), string(order)) {
+			filtered = append(filtered, option)
+		}
+	}
+	return filtered
+}
+
+// The orders a Group can be given based on its profile alone, before any traits are considered.
+func (engine *Engine) baseActivations(group *data.Group) (options ActivationOptions) {
+	if group.Move.Activation > 0 {
+		options = append(options, ActivationOption{Order: OrderMove, Target: group.Move.Activation})
+	}
+	if group.Melee.Activation > 0 && group.Melee.ToHitAttacking > 0 {
+		options = append(options, ActivationOption{
+			Order:   OrderAttack,
+			Target:  group.Melee.Activation,
+			Targets: engine.targetsInReach(group, engine.enemyIds(group)),
+		})
+	}
+	if missiles := group.MissileProfiles(); len(missiles) > 0 {
+		// A Group with more than one missile profile activates with whichever is easiest
+		activation := missiles[0].Activation
+		for _, missile := range missiles[1:] {
+			if missile.Activation < activation {
+				activation = missile.Activation
+			}
+		}
+		options = append(options, ActivationOption{
+			Order:   OrderShoot,
+			Target:  activation,
+			Targets: engine.targetsInRange(group, engine.enemyIds(group)),
+		})
+	}
+	if spells := engine.SpellList(group.Id); len(spells) > 0 {
+		// Casting is tested against the spell's check, so the easiest spell to cast is the number needed
+		check := spells[0].Check
+		for _, spell := range spells[1:] {
+			if spell.Check < check {
+				check = spell.Check
+			}
+		}
+		options = append(options, ActivationOption{
+			Order:   OrderCast,
+			Target:  check,
+			Targets: engine.castingTargets(group),
+		})
+	}
+	return options
+}
+
+func (engine *Engine) enemyIds(group *data.Group) (ids []string) {
+	ids = []string{}
+	company := engine.CompanyOf(group.Id)
+	for _, other := range engine.Skirmish.Companies {
+		if other.Name == company {
+			continue
+		}
+		for _, enemy := range engine.GroupsInPlay(other.Name) {
+			ids = append(ids, enemy.Id)
+		}
+	}
+	return ids
+}
+
+// ValidActivations returns the orders a Group may be given right now. Trait scripts registered for the
+// GetValidActivations event may add, remove, or limit the orders and remove Groups from the list of valid targets.
+// Orders which need a target but have none are never valid. A Shaken Group may only be ordered to Rally. An order added
+// by a trait is only valid if the Group has a trait script registered for CarryingOutOrder to carry it out.
+func (engine *Engine) ValidActivations(groupId string) (options ActivationOptions, err error) {
+	group, err := engine.Group(groupId)
+	if err != nil {
+		return options, fmt.Errorf("unable to determine valid activations: %s", err)
+	}
+
+	if engine.isShaken(groupId) {
+		return ActivationOptions{{Order: OrderRally, Target: group.Resolve}}, nil
+	}
+
+	options = engine.baseActivations(group)
+	event := &Event{
+		Name:   GetValidActivations,
+		Actor:  group,
+		Result: map[string]any{"options": options.Orders()},
+	}
+	effects, err := engine.Events.Dispatch(event)
+	if err != nil {
+		return options, fmt.Errorf("unable to determine valid activations for group '%s': %s", group.Name, err)
+	}
+
+	carriesOutOrders := engine.Events.registeredFor(CarryingOutOrder, group.Id)
+	for _, effect := range effects.Named("AddOption") {
+		name := stringFrom(effect.Arguments, 0)
+		if _, exists := options.Find(Order(name)); name == "" || exists {
+			continue
+		}
+		if !carriesOutOrders {
+			log.Trace().Msgf("group '%s' has nothing to carry out the order '%s' added by trait '%s'", group.Name, name, effect.Trait)
+			continue
+		}
+		options = append(options, ActivationOption{
+			Order:  Order(name),
+			Target: intFrom(effect.Arguments, 1, group.Move.Activation),
+		})
+	}
+
+	for _, effect := range effects.Named("Ignore") {
+		options = options.Without(Order(stringFrom(effect.Arguments, 0)))
+	}
+
+	for _, effect := range effects.Named("IgnoreForTargetList") {
+		order := orderForTargetList(stringFrom(effect.Arguments, 0))
+		ignoredId := stringFrom(effect.Arguments, 1)
+		for index := range options {
+			if strings.EqualFold(string(options[index].Order), string(order)) {
+				options[index].Targets = without(options[index].Targets, ignoredId)
+			}
+		}
+	}
+
+	// Some traits free a Group from having to take a limited order, like a Captain calming a Reckless Group
+	limits := effects.Named("LimitActivationTo")
+	if len(effects.Named("IgnoreLimit")) > 0 {
+		limits = nil
+	}
+	for _, effect := range limits {
+		limitedTo := stringFrom(effect.Arguments, 0)
+		if strings.EqualFold(limitedTo, "AttackFoe") {
+			if option, ok := options.Find(OrderAttack); ok {
+				option.Targets = engine.foeIds(group, option.Targets)
+				if len(option.Targets) > 0 {
+					options = ActivationOptions{option}
+				}
+			}
+		} else if option, ok := options.Find(Order(limitedTo)); ok && (option.Targets == nil || len(option.Targets) > 0) {
+			// Limiting a Group to an order it has no targets for would leave it with no orders at all
+			options = ActivationOptions{option}
+		}
+	}
+
+	var valid ActivationOptions
+	for _, option := range options {
+		if option.Targets != nil && len(option.Targets) == 0 {
+			continue
+		}
+		valid = append(valid, option)
+	}
+
+	return valid, nil
+}
+
+func orderForTargetList(list string) Order {
+	switch strings.ToLower(list) {
+	case "attacking", "attack":
+		return OrderAttack
+	case "shooting", "shoot":
+		return OrderShoot
+	case "casting", "cast":
+		return OrderCast
+	}
+	return Order(list)
+}
+
+func isBaseOrder(order Order) bool {
+	switch order {
+	case OrderMove, OrderAttack, OrderShoot, OrderRally, OrderCast:
+		return true
+	}
+	return false
+}
+
+func testingToActivateEvent(order Order) (EventName, bool) {
+	switch order {
+	case OrderMove:
+		return TestingToActivateMove, true
+	case OrderAttack:
+		return TestingToActivateAttack, true
+	case OrderShoot:
+		return TestingToActivateShoot, true
+	}
+	return "", false
+}
+
+// Activate rolls 2d6 for a Group to see if it activates for the given order. Trait scripts may change the number
+// needed or automatically pass the test when testing to activate and may reroll a failed test. If the test passes, the
+// Group stays active until its order is carried out and FinishActivation is called; if it fails, the active Company's
+// part of the turn ends. An order added by a trait is carried out right away by the trait scripts registered for
+// CarryingOutOrder, which finishes the activation.
+func (engine *Engine) Activate(groupId string, order Order) (result Result, err error) {
+	errorPrefix := "unable to activate"
+	done := engine.recording(Update{Type: UpdateActivate, Subtype: string(order), Actor: groupId})
+	defer func() { done(result, err) }()
+	group, err := engine.Group(groupId)
+	if err != nil {
+		return result, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	errorPrefix = fmt.Sprintf("unable to activate group '%s' to %s", group.Name, order)
+	if !engine.CanActivate(groupId) {
+		return result, fmt.Errorf("%s: it cannot activate right now", errorPrefix)
+	}
+
+	options, err := engine.ValidActivations(groupId)
+	if err != nil {
+		return result, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	option, ok := options.Find(order)
+	if !ok {
+		return result, fmt.Errorf("%s: valid orders are %s", errorPrefix, strings.Join(options.Orders(), ", "))
+	}
+
+	if option.Order == OrderRally {
+		result, err = engine.rally(group)
+		if err != nil {
+			return result, fmt.Errorf("%s: %s", errorPrefix, err)
+		}
+		return result, nil
+	}
+
+	// Casting is tested when the spell is cast, against its check
+	if option.Order == OrderCast {
+		groupState, _ := engine.GroupState(groupId)
+		groupState.Activated = true
+		engine.Skirmish.ActiveGroup = groupId
+		engine.Skirmish.ActiveOrder = option.Order
+		engine.Skirmish.ActiveTargets = option.Targets
+		result.Activation = string(Pass)
+		return result, nil
+	}
+
+	testResult := map[string]any{"order": string(option.Order), "target": option.Target}
+	autoPass := false
+	if eventName, ok := testingToActivateEvent(option.Order); ok {
+		event := &Event{Name: eventName, Actor: group, Result: testResult}
+		effects, err := engine.Events.Dispatch(event)
+		if err != nil {
+			return result, fmt.Errorf("%s: %s", errorPrefix, err)
+		}
+		testResult = event.Result
+		autoPass = len(effects.Named("AutoPass")) > 0 || testResult["auto_pass"] == true
+	}
+
+	target := intValue(testResult["target"], option.Target)
+	source := fmt.Sprintf("'%s' testing to %s", group.Name, option.Order)
+	rolls := engine.Dice.TwoD6(source)
+	passed := autoPass || sum(rolls) >= target
+	if !passed {
+		testResult["rolls"] = rolls
+		event := &Event{Name: FailedToActivate, Actor: group, Result: testResult}
+		effects, err := engine.Events.Dispatch(event)
+		if err != nil {
+			return result, fmt.Errorf("%s: %s", errorPrefix, err)
+		}
+		if reroll := effects.Named("Reroll"); len(reroll) > 0 {
+			rolls = engine.Dice.Reroll(fmt.Sprintf("%s (%s)", source, reroll[0].Trait), rolls, 6, 0, 1)
+		}
+		passed = len(effects.Named("AutoPass")) > 0 || event.Result["auto_pass"] == true || sum(rolls) >= target
+	}
+	log.Trace().Msgf("group '%s' rolled %v against %d+ to %s", group.Name, rolls, target, option.Order)
+
+	result.ActivationRolls = rolls
+	groupState, _ := engine.GroupState(groupId)
+	groupState.Activated = true
+	if passed {
+		result.Activation = string(Pass)
+		engine.notifyState(groupId, "'%s' activated to %s", group.Name, option.Order)
+		engine.Skirmish.ActiveGroup = groupId
+		engine.Skirmish.ActiveOrder = option.Order
+		engine.Skirmish.ActiveTargets = option.Targets
+		if !isBaseOrder(option.Order) {
+			event := &Event{Name: CarryingOutOrder, Actor: group, Result: map[string]any{"order": string(option.Order)}}
+			if _, err := engine.Events.Dispatch(event); err != nil {
+				return result, fmt.Errorf("%s: %s", errorPrefix, err)
+			}
+			engine.FinishActivation()
+		}
+	} else {
+		result.Activation = string(Fail)
+		engine.notifyState(groupId, "'%s' failed to activate to %s", group.Name, option.Order)
+		engine.EndTurn()
+	}
+
+	return result, nil
+}
+
+func (engine *Engine) foeIds(group *data.Group, candidates []string) (foes []string) {
+	for _, id := range candidates {
+		enemy, err := engine.Group(id)
+		if err != nil {
+			continue
+		}
+		for _, kind := range addendaList(group, "foes") {
+			if isKind(enemy, kind) {
+				foes = append(foes, id)
+				break
+			}
+		}
+	}
+	return foes
+}
+
+// A Group is of a kind if the kind matches its name, profile, or one of its traits.
+func isKind(group *data.Group, kind string) bool {
+	if strings.EqualFold(group.Name, kind) || strings.Contains(strings.ToLower(group.ProfileName), strings.ToLower(kind)) {
+		return true
+	}
+	for _, trait := range group.Traits {
+		if strings.EqualFold(trait, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+func addendaList(group *data.Group, key string) []string {
+	return stringsFrom(group.Addenda[key])
+}
+
+// Lists which have been through a script come back as []any
+func stringsFrom(value any) (values []string) {
+	switch list := value.(type) {
+	case []string:
+		values = append(values, list...)
+	case []any:
+		for _, item := range list {
+			if itemValue, ok := item.(string); ok {
+				values = append(values, itemValue)
+			}
+		}
+	}
+	return values
+}
+
+func (bus *Bus) addActivationNatives() {
+	bus.AddEffect("Activation", "AddOption")
+	bus.AddEffect("Activation", "Ignore")
+	bus.AddEffect("Activation", "IgnoreForTargetList")
+	bus.AddEffect("Activation", "AutoPass")
+	bus.AddEffect("Activation", "Reroll")
+	bus.AddEffect("Activation", "IgnoreLimit")
+	bus.AddNative("Activation", "Can", func(dispatch *Dispatch, arguments ...tengo.Object) (tengo.Object, error) {
+		order, err := stringArgument("Can", arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, option := range stringsFrom(dispatch.Event.Result["options"]) {
+			if strings.EqualFold(option, order) {
+				return tengo.TrueValue, nil
+			}
+		}
+		return tengo.FalseValue, nil
+	})
+	// The Play submodule includes aliases for the activation options some traits use
+	bus.AddEffect("Play", "LimitActivationTo")
+	bus.AddNative("Play", "AddActivationOption", bus.natives["Activation"]["AddOption"])
+	bus.AddNative("Traits", "CanAttackFoe", func(dispatch *Dispatch, arguments ...tengo.Object) (tengo.Object, error) {
+		group := dispatch.Group
+		if len(arguments) > 0 {
+			if actor, err := bus.groupArgument("CanAttackFoe", arguments, 0); err == nil {
+				group = actor
+			}
+		}
+		return tengoBool(len(bus.engine.foeIds(group, bus.engine.enemyIds(group))) > 0), nil
+	})
+
+	bus.AddShorthand("can", "core.Activation.Can")
+	bus.AddShorthand("addAction", "core.Activation.AddOption")
+	bus.AddShorthand("ignoreActivation", "core.Activation.Ignore")
+	bus.AddShorthand("ignoreForTargetList", "core.Activation.IgnoreForTargetList")
+	bus.AddShorthand("limitActivationTo", "core.Play.LimitActivationTo")
+}
+
+// Groups are passed to natives as maps or by id
+func (bus *Bus) groupArgument(name string, arguments []tengo.Object, index int) (*data.Group, error) {
+	if len(arguments) <= index {
+		return nil, tengo.ErrWrongNumArguments
+	}
+	id, ok := tengo.ToString(arguments[index])
+	if groupMap, isMap := tengo.ToInterface(arguments[index]).(map[string]any); isMap {
+		id, ok = groupMap["id"].(string)
+	}
+	if !ok {
+		return nil, tengo.ErrInvalidArgumentType{
+			Name:     fmt.Sprintf("%s argument %d", name, index+1),
+			Expected: "group or group id",
+			Found:    arguments[index].TypeName(),
+		}
+	}
+	return bus.engine.Group(id)
+}
+
+func stringFrom(arguments []any, index int) string {
+	if len(arguments) <= index {
+		return ""
+	}
+	value, _ := arguments[index].(string)
+	return value
+}
+
+func intFrom(arguments []any, index int, fallback int) int {
+	if len(arguments) <= index {
+		return fallback
+	}
+	return intValue(arguments[index], fallback)
+}
+
+// Numbers which have been through a script come back as int64 or float64
+func intValue(value any, fallback int) int {
+	switch number := value.(type) {
+	case int:
+		return number
+	case int64:
+		return int(number)
+	case float64:
+		return int(number)
+	}
+	return fallback
+}
+
+func without(ids []string, id string) (filtered []string) {
+	filtered = []string{}
+	for _, existing := range ids {
+		if existing != id {
+			filtered = append(filtered, existing)
+		}
+	}
+	return filtered
+}
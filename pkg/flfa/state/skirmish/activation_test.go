@@ -0,0 +1,68 @@
+package skirmish
+
+import (
+	"testing"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+)
+
+// An order added by a trait is only offered to a Group which has a script to carry it out, and activating for it runs
+// that script and finishes the activation.
+func TestTraitOrdersAreCarriedOut(t *testing.T) {
+	skirmish := twoCompanySkirmish().Skirmish
+	scouts := &skirmish.Companies[0].Groups[0]
+	scouts.Traits = []string{"Signaller"}
+	scouts.Move = data.Move{Activation: 2, Distance: 6}
+	raiders := &skirmish.Companies[1].Groups[0]
+	raiders.Traits = []string{"Bluffer"}
+	raiders.Move = data.Move{Activation: 2, Distance: 6}
+	signaller := data.Trait{Name: "Signaller", Scripting: data.TraitScripting{InPlay: []data.TraitScriptingInPlay{
+		{RegisterFor: []string{string(GetValidActivations)}, Then: []string{`core.Activation.AddOption("Signal")`}},
+		{
+			RegisterFor: []string{string(CarryingOutOrder)},
+			When:        []string{`result.order == "Signal"`},
+			Then:        []string{`core.Play.Notify("signalling")`},
+		},
+	}}}
+	bluffer := data.Trait{Name: "Bluffer", Scripting: data.TraitScripting{InPlay: []data.TraitScriptingInPlay{
+		{RegisterFor: []string{string(GetValidActivations)}, Then: []string{`core.Activation.AddOption("Bluff")`}},
+	}}}
+	var notified []Notification
+	engine := NewEngine(skirmish, WithSeed(1), WithScriptEngine(coreScriptEngine(t)),
+		WithTraits([]data.Trait{signaller, bluffer}),
+		WithNotifier(func(notification Notification) { notified = append(notified, notification) }))
+	engine.Events.RegisterGroups()
+
+	options, err := engine.ValidActivations(raiders.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := options.Find("Bluff"); ok {
+		t.Error("expected an order nothing carries out not to be offered")
+	}
+
+	options, err = engine.ValidActivations(scouts.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := options.Find("Signal"); !ok {
+		t.Fatalf("expected the Signal order to be offered, got %v", options.Orders())
+	}
+	result, err := engine.Activate(scouts.Id, "Signal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Activation != string(Pass) {
+		t.Fatalf("expected the activation to pass, got %s", result.Activation)
+	}
+	signalled := false
+	for _, notification := range notified {
+		signalled = signalled || notification.Message == "signalling"
+	}
+	if !signalled {
+		t.Errorf("expected the trait script to carry out the order, got notifications %+v", notified)
+	}
+	if engine.Skirmish.ActiveGroup != "" {
+		t.Errorf("expected the activation to be finished, but '%s' is still active", engine.Skirmish.ActiveGroup)
+	}
+}
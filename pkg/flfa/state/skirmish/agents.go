@@ -0,0 +1,172 @@
+package skirmish
+
+import (
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+)
+
+// An Agent controls a Company in place of a player, making every decision the player would: where to deploy its
+// Groups, which Group to activate and for what order, where to move, what to attack, shoot, or cast at, and how to
+// answer the Questions its traits ask. An Agent inspects the Engine to decide but does not give it commands; the Engine
+// gives them for it, so they are logged just like a player's. Agents must not roll the Engine's dice, or replaying the
+// log would roll differently.
+type Agent interface {
+	// Deploy chooses where to deploy one of the Company's undeployed Groups.
+	Deploy(engine *Engine, groupId string) (Location, error)
+	// Activate chooses a Group of the active Company to activate and the order to give it; choosing no Group ends the
+	// Company's part of the turn.
+	Activate(engine *Engine) (groupId string, order Order, err error)
+	// Move chooses where the active Group, which activated to Move, moves to.
+	Move(engine *Engine, groupId string) (Location, error)
+	// Target chooses which of the targets the active Group attacks or shoots at.
+	Target(engine *Engine, groupId string, order Order, targets []string) (targetId string, err error)
+	// Cast chooses which spell the active Group casts and which of the targets it casts it at.
+	Cast(engine *Engine, groupId string, targets []string) (spell string, targetId string, err error)
+	// Answer answers a Question asked by one of the Company's traits.
+	Answer(engine *Engine, question Question) (answer any, err error)
+}
+
+// Agents playing each other should finish long before this many commands; if not, they are probably stuck.
+const maximumAgentCommands = 10000
+
+// WithAgent makes an Agent available to control Companies under the given name, replacing any by the same name. The
+// Skirmish names the Agent controlling each computer-controlled Company in its Agents; the heuristic Agent is always
+// available.
+func WithAgent(name string, agent Agent) Option {
+	return func(engine *Engine) {
+		if engine.agents == nil {
+			engine.agents = make(map[string]Agent)
+		}
+		engine.agents[name] = agent
+	}
+}
+
+// ControlWith hands control of the named Company to the named Agent; an empty name returns it to a player.
+func (engine *Engine) ControlWith(companyName string, agentName string) error {
+	if _, err := engine.Company(companyName); err != nil {
+		return fmt.Errorf("unable to hand control of '%s' to agent '%s': %s", companyName, agentName, err)
+	}
+	if agentName == "" {
+		delete(engine.Skirmish.Agents, companyName)
+		return nil
+	}
+	if _, ok := engine.agents[agentName]; !ok {
+		return fmt.Errorf("unable to hand control of '%s' to agent '%s': no agent by that name", companyName, agentName)
+	}
+	if engine.Skirmish.Agents == nil {
+		engine.Skirmish.Agents = make(map[string]string)
+	}
+	engine.Skirmish.Agents[companyName] = agentName
+	return nil
+}
+
+// AgentFor returns the Agent controlling the named Company, if it is computer-controlled.
+func (engine *Engine) AgentFor(companyName string) (Agent, bool) {
+	name, ok := engine.Skirmish.Agents[companyName]
+	if !ok {
+		return nil, false
+	}
+	agent, ok := engine.agents[name]
+	if !ok {
+		log.Warn().Msgf("company '%s' is controlled by agent '%s', which is not available; a player must control it", companyName, name)
+	}
+	return agent, ok
+}
+
+func (engine *Engine) controlledByAgent(companyName string) bool {
+	_, ok := engine.AgentFor(companyName)
+	return ok
+}
+
+// Advance has the Agent controlling the active Company give its next command and reports whether it gave one; none is
+// given if the Skirmish has ended or a player controls the active Company. If a command the Agent chose cannot be
+// given, the Group's activation is finished or, if no Group is active, the Company's part of the turn ends, so the
+// Skirmish always moves on.
+func (engine *Engine) Advance() (advanced bool, err error) {
+	current := engine.Skirmish
+	if current.Ended {
+		return false, nil
+	}
+	agent, ok := engine.AgentFor(current.ActiveCompany)
+	if !ok {
+		return false, nil
+	}
+	errorPrefix := fmt.Sprintf("unable to advance the skirmish for '%s'", current.ActiveCompany)
+
+	if current.Phase == PhaseDeployment {
+		groups := engine.UndeployedGroups(current.ActiveCompany)
+		if len(groups) == 0 {
+			return false, fmt.Errorf("%s: it has no groups left to deploy", errorPrefix)
+		}
+		location, err := agent.Deploy(engine, groups[0].Id)
+		if err != nil {
+			return false, fmt.Errorf("%s: %s", errorPrefix, err)
+		}
+		if err := engine.Deploy(groups[0].Id, location); err != nil {
+			return false, fmt.Errorf("%s: %s", errorPrefix, err)
+		}
+		return true, nil
+	}
+
+	if current.ActiveGroup == "" {
+		groupId, order, err := agent.Activate(engine)
+		if err != nil {
+			return false, fmt.Errorf("%s: %s", errorPrefix, err)
+		}
+		if groupId == "" {
+			engine.EndTurn()
+			return true, nil
+		}
+		if _, err := engine.Activate(groupId, order); err != nil {
+			log.Warn().Msgf("%s: %s; ending its part of the turn", errorPrefix, err)
+			engine.EndTurn()
+		}
+		return true, nil
+	}
+
+	groupId := current.ActiveGroup
+	switch current.ActiveOrder {
+	case OrderMove:
+		var location Location
+		if location, err = agent.Move(engine, groupId); err == nil {
+			err = engine.Move(location)
+		}
+	case OrderAttack, OrderShoot:
+		var target string
+		if target, err = agent.Target(engine, groupId, current.ActiveOrder, current.ActiveTargets); err == nil {
+			if current.ActiveOrder == OrderAttack {
+				_, err = engine.Attack(target)
+			} else {
+				_, err = engine.Shoot(target, "")
+			}
+		}
+	case OrderCast:
+		var spell, target string
+		if spell, target, err = agent.Cast(engine, groupId, current.ActiveTargets); err == nil {
+			_, err = engine.Cast(spell, target)
+		}
+	default:
+		// Orders added by traits are carried out as the Group activates, so there is nothing left to do
+		engine.FinishActivation()
+	}
+	if err != nil {
+		log.Warn().Msgf("%s: %s; finishing the activation", errorPrefix, err)
+		if current.ActiveGroup == groupId {
+			engine.FinishActivation()
+		}
+	}
+	return true, nil
+}
+
+// PlayAgents has Agents give commands until the Skirmish ends or a Company controlled by a player is active. When
+// every Company is computer-controlled, it plays the whole Skirmish with nobody at the table.
+func (engine *Engine) PlayAgents() error {
+	for commands := 0; commands < maximumAgentCommands; commands++ {
+		advanced, err := engine.Advance()
+		if err != nil || !advanced {
+			return err
+		}
+	}
+	return fmt.Errorf("agents gave %d commands without the skirmish ending or a player taking over", maximumAgentCommands)
+}
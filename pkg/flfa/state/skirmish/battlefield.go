@@ -0,0 +1,461 @@
+package skirmish
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/utils"
+	"github.com/d5/tengo/v2"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Groups within this many inches of an enemy Group are in melee with it
+	MeleeRange = 1
+	// Movement is checked against the terrain every tenth of an inch along the path
+	movementStep = 0.1
+)
+
+// The Battlefield is the table a Skirmish is played on, measured in inches from its bottom left corner. A Battlefield
+// without a width or height has no edges.
+type Battlefield struct {
+	Width   int
+	Height  int
+	Terrain []Area
+}
+
+// An Area is a rectangle of terrain on the Battlefield; its location is its bottom left corner.
+type Area struct {
+	Name     string
+	Type     Terrain
+	Location Location
+	Width    int
+	Height   int
+}
+
+type Terrain string
+
+const (
+	TerrainNormal     Terrain = "normal"
+	TerrainDifficult  Terrain = "difficult"
+	TerrainImpassable Terrain = "impassable"
+	TerrainCover      Terrain = "cover"
+)
+
+func ValidTerrain() []Terrain {
+	return []Terrain{TerrainNormal, TerrainDifficult, TerrainImpassable, TerrainCover}
+}
+
+// ParseTerrain turns a terrain type as written in a trait script, like "Difficult" or "DifficultTerrain", into a
+// Terrain.
+func ParseTerrain(text string) (Terrain, error) {
+	name := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(text)), "terrain")
+	for _, terrain := range ValidTerrain() {
+		if name == string(terrain) {
+			return terrain, nil
+		}
+	}
+	var validTerrainList []string
+	for _, terrain := range ValidTerrain() {
+		validTerrainList = append(validTerrainList, string(terrain))
+	}
+	return "", fmt.Errorf("unknown terrain type '%s', should be one of: %s", text, strings.Join(validTerrainList, ", "))
+}
+
+func (area Area) Contains(x float64, y float64) bool {
+	return x >= float64(area.Location.X) && x <= float64(area.Location.X+area.Width) &&
+		y >= float64(area.Location.Y) && y <= float64(area.Location.Y+area.Height)
+}
+
+func (battlefield Battlefield) OnBattlefield(x float64, y float64) bool {
+	if battlefield.Width <= 0 || battlefield.Height <= 0 {
+		return true
+	}
+	return x >= 0 && x <= float64(battlefield.Width) && y >= 0 && y <= float64(battlefield.Height)
+}
+
+// TerrainAt returns the types of terrain at a point on the Battlefield; a point outside of every Area is normal terrain.
+func (battlefield Battlefield) TerrainAt(x float64, y float64) (terrain []Terrain) {
+	for _, area := range battlefield.Terrain {
+		if area.Contains(x, y) {
+			terrain = append(terrain, area.Type)
+		}
+	}
+	if len(terrain) == 0 {
+		terrain = append(terrain, TerrainNormal)
+	}
+	return terrain
+}
+
+func WithBattlefield(battlefield Battlefield) Option {
+	return func(engine *Engine) {
+		engine.Skirmish.Battlefield = battlefield
+	}
+}
+
+// PlaceGroup puts a Group on the Battlefield at the given location, which must be on the Battlefield and not in
+// impassable terrain.
+func (engine *Engine) PlaceGroup(groupId string, location Location) (err error) {
+	errorPrefix := "unable to place group"
+	done := engine.recording(Update{Type: UpdatePlace, Actor: groupId, EndLocation: &location})
+	defer func() { done(Result{}, err) }()
+	groupState, err := engine.GroupState(groupId)
+	if err != nil {
+		return fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	x, y := float64(location.X), float64(location.Y)
+	if !engine.Skirmish.Battlefield.OnBattlefield(x, y) {
+		return fmt.Errorf("%s: %s is off the battlefield", errorPrefix, location)
+	}
+	if !engine.placeable(location) {
+		return fmt.Errorf("%s: %s is in impassable terrain", errorPrefix, location)
+	}
+	groupState.Location = &location
+	return nil
+}
+
+// placeable reports whether a Group could be placed at the location: on the Battlefield and not in impassable terrain.
+func (engine *Engine) placeable(location Location) bool {
+	x, y := float64(location.X), float64(location.Y)
+	if !engine.Skirmish.Battlefield.OnBattlefield(x, y) {
+		return false
+	}
+	for _, terrain := range engine.Skirmish.Battlefield.TerrainAt(x, y) {
+		if terrain == TerrainImpassable {
+			return false
+		}
+	}
+	return true
+}
+
+func (location Location) String() string {
+	return fmt.Sprintf("(%d, %d)", location.X, location.Y)
+}
+
+// Within reports whether two Groups are no more than the given number of inches apart. Like Distance, it passes if
+// either Group has not been placed on the battlefield.
+func (engine *Engine) Within(firstId string, secondId string, inches float64) bool {
+	distance, known := engine.Distance(firstId, secondId)
+	return !known || distance <= inches
+}
+
+// GroupsWithin returns every other Group in play which has been placed on the battlefield within the given number of
+// inches of a Group.
+func (engine *Engine) GroupsWithin(groupId string, inches float64) (groups []*data.Group) {
+	for _, company := range engine.Skirmish.Companies {
+		for _, group := range engine.GroupsInPlay(company.Name) {
+			if group.Id == groupId {
+				continue
+			}
+			if distance, known := engine.Distance(groupId, group.Id); known && distance <= inches {
+				groups = append(groups, group)
+			}
+		}
+	}
+	return groups
+}
+
+// InTerrain reports whether a Group which has been placed on the battlefield is in the given type of terrain.
+func (engine *Engine) InTerrain(groupId string, terrain Terrain) bool {
+	groupState, err := engine.GroupState(groupId)
+	if err != nil || groupState.Location == nil {
+		return false
+	}
+	for _, found := range engine.Skirmish.Battlefield.TerrainAt(float64(groupState.Location.X), float64(groupState.Location.Y)) {
+		if found == terrain {
+			return true
+		}
+	}
+	return false
+}
+
+// InMelee reports whether a Group is within melee range of any enemy Group.
+func (engine *Engine) InMelee(groupId string) bool {
+	company := engine.CompanyOf(groupId)
+	for _, group := range engine.GroupsWithin(groupId, MeleeRange) {
+		if engine.CompanyOf(group.Id) != company {
+			return true
+		}
+	}
+	return false
+}
+
+// A Group can only Attack enemies it could Move into contact with.
+func (engine *Engine) targetsInReach(group *data.Group, candidates []string) (targets []string) {
+	targets = []string{}
+	for _, id := range candidates {
+		if engine.Within(group.Id, id, float64(group.Move.Distance+MeleeRange)) {
+			targets = append(targets, id)
+		}
+	}
+	return targets
+}
+
+// An attacking Group ends up in contact with its target, beside it on the side it approached from. If the attacker
+// could not move there the way it would for a Move, or another Group is already there, it takes the first of the other
+// sides it can; an attacker on top of its target approaches from the left. If none will do, it stays where it is.
+func (engine *Engine) moveIntoContact(attacker *data.Group, target *data.Group) {
+	attackerState, err := engine.GroupState(attacker.Id)
+	if err != nil || attackerState.Location == nil {
+		return
+	}
+	targetState, err := engine.GroupState(target.Id)
+	if err != nil || targetState.Location == nil {
+		return
+	}
+	treatments, err := engine.terrainTreatments(attacker)
+	if err != nil {
+		log.Warn().Msgf("unable to move group '%s' into contact with group '%s': %s", attacker.Name, target.Name, err)
+		return
+	}
+
+	start := *attackerState.Location
+	for _, side := range contactSides(start.X-targetState.Location.X, start.Y-targetState.Location.Y) {
+		contact := Location{X: targetState.Location.X + side.X*MeleeRange, Y: targetState.Location.Y + side.Y*MeleeRange}
+		if contact != start {
+			if !engine.placeable(contact) {
+				continue
+			}
+			cost, err := engine.movementCost(start, contact, treatments)
+			if err != nil || cost > float64(attacker.Move.Distance) {
+				continue
+			}
+		}
+		if engine.occupied(contact, attacker.Id, target.Id) {
+			continue
+		}
+		attackerState.Location = &contact
+		return
+	}
+	log.Trace().Msgf("group '%s' has no room to move into contact with group '%s' and stays at %s", attacker.Name, target.Name, start)
+}
+
+// contactSides lists the sides of a Group another Group could be in contact with it on, as the direction from it,
+// starting with the side the offset is mostly towards and ending with the opposite one. With no offset, the left side
+// comes first.
+func contactSides(deltaX int, deltaY int) []Location {
+	if deltaX == 0 && deltaY == 0 {
+		deltaX = -1
+	}
+	if abs(deltaX) >= abs(deltaY) {
+		across := sign(deltaY)
+		if across == 0 {
+			across = 1
+		}
+		return []Location{{X: sign(deltaX)}, {Y: across}, {Y: -across}, {X: -sign(deltaX)}}
+	}
+	across := sign(deltaX)
+	if across == 0 {
+		across = 1
+	}
+	return []Location{{Y: sign(deltaY)}, {X: across}, {X: -across}, {Y: -sign(deltaY)}}
+}
+
+// occupied reports whether a Group in play other than those ignored has been placed at the location.
+func (engine *Engine) occupied(location Location, ignored ...string) bool {
+	for _, groupState := range engine.Skirmish.GroupStates {
+		if !groupState.InPlay() || groupState.Location == nil || utils.Contains(ignored, groupState.Id) {
+			continue
+		}
+		if *groupState.Location == location {
+			return true
+		}
+	}
+	return false
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+func sign(value int) int {
+	switch {
+	case value < 0:
+		return -1
+	case value > 0:
+		return 1
+	}
+	return 0
+}
+
+// Move has the active Group, which must have activated to Move, move in a straight line to the destination. Trait
+// scripts registered for DeterminingMovement may change how the Group treats each type of terrain. The path may not
+// cross impassable terrain or leave the battlefield, and each inch of difficult terrain along it counts as two inches
+// of the Group's Move distance. Once moved, the activation is finished.
+func (engine *Engine) Move(destination Location) (err error) {
+	errorPrefix := "unable to move"
+	update := Update{Type: UpdateMove, Actor: engine.Skirmish.ActiveGroup, EndLocation: &destination}
+	if groupState, err := engine.GroupState(engine.Skirmish.ActiveGroup); err == nil && groupState.Location != nil {
+		start := *groupState.Location
+		update.StartLocation = &start
+	}
+	done := engine.recording(update)
+	defer func() { done(Result{}, err) }()
+	if engine.Skirmish.ActiveGroup == "" || engine.Skirmish.ActiveOrder != OrderMove {
+		return fmt.Errorf("%s: no group has activated to move", errorPrefix)
+	}
+	group, err := engine.Group(engine.Skirmish.ActiveGroup)
+	if err != nil {
+		return fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	errorPrefix = fmt.Sprintf("unable to move group '%s' to %s", group.Name, destination)
+	groupState, err := engine.GroupState(group.Id)
+	if err != nil {
+		return fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+
+	treatments, err := engine.terrainTreatments(group)
+	if err != nil {
+		return fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+
+	if groupState.Location != nil {
+		cost, err := engine.movementCost(*groupState.Location, destination, treatments)
+		if err != nil {
+			return fmt.Errorf("%s: %s", errorPrefix, err)
+		}
+		if cost > float64(group.Move.Distance) {
+			return fmt.Errorf("%s: the move costs %.1f\" but the group can only move %d\"", errorPrefix, cost, group.Move.Distance)
+		}
+		log.Trace().Msgf("group '%s' moved from %s to %s, costing %.1f\"", group.Name, groupState.Location, destination, cost)
+	}
+
+	if err := engine.PlaceGroup(group.Id, destination); err != nil {
+		return fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+
+	engine.FinishActivation()
+
+	return nil
+}
+
+// Trait scripts registered for DeterminingMovement may ask for one type of terrain to be treated as another.
+func (engine *Engine) terrainTreatments(group *data.Group) (treatments map[Terrain]Terrain, err error) {
+	treatments = make(map[Terrain]Terrain)
+	event := &Event{Name: DeterminingMovement, Actor: group}
+	effects, err := engine.Events.Dispatch(event)
+	if err != nil {
+		return treatments, err
+	}
+	for _, effect := range effects.Named("TreatTerrainAs") {
+		from, err := ParseTerrain(stringFrom(effect.Arguments, 0))
+		if err != nil {
+			log.Warn().Msgf("trait '%s' of group '%s' can not treat terrain: %s", effect.Trait, effect.Owner.Name, err)
+			continue
+		}
+		to, err := ParseTerrain(stringFrom(effect.Arguments, 1))
+		if err != nil {
+			log.Warn().Msgf("trait '%s' of group '%s' can not treat terrain: %s", effect.Trait, effect.Owner.Name, err)
+			continue
+		}
+		treatments[from] = to
+	}
+	return treatments, nil
+}
+
+// The cost of moving from one location to another is the length of the path, with difficult terrain counting double.
+func (engine *Engine) movementCost(start Location, end Location, treatments map[Terrain]Terrain) (cost float64, err error) {
+	battlefield := engine.Skirmish.Battlefield
+	deltaX, deltaY := float64(end.X-start.X), float64(end.Y-start.Y)
+	length := math.Hypot(deltaX, deltaY)
+	steps := int(math.Ceil(length / movementStep))
+	for step := 0; step < steps; step++ {
+		// Each step is checked at its midpoint
+		fraction := (float64(step) + 0.5) / float64(steps)
+		x, y := float64(start.X)+deltaX*fraction, float64(start.Y)+deltaY*fraction
+		if !battlefield.OnBattlefield(x, y) {
+			return cost, fmt.Errorf("the path leaves the battlefield")
+		}
+		multiplier := 1.0
+		for _, terrain := range battlefield.TerrainAt(x, y) {
+			if treatment, ok := treatments[terrain]; ok {
+				terrain = treatment
+			}
+			switch terrain {
+			case TerrainImpassable:
+				return cost, fmt.Errorf("the path crosses impassable terrain at (%.1f, %.1f)", x, y)
+			case TerrainDifficult:
+				multiplier = 2
+			}
+		}
+		cost += length / float64(steps) * multiplier
+	}
+	return cost, nil
+}
+
+func (bus *Bus) addBattlefieldNatives() {
+	bus.AddEffect("GroupInPlay", "TreatTerrainAs")
+	bus.AddNative("Play", "Within", func(dispatch *Dispatch, arguments ...tengo.Object) (tengo.Object, error) {
+		if len(arguments) < 2 {
+			return nil, tengo.ErrWrongNumArguments
+		}
+		inches, ok := tengo.ToFloat64(arguments[0])
+		if !ok {
+			return nil, tengo.ErrInvalidArgumentType{Name: "Within argument 1", Expected: "number", Found: arguments[0].TypeName()}
+		}
+		var others []*data.Group
+		company := bus.engine.CompanyOf(dispatch.Group.Id)
+		if of, isString := tengo.ToString(arguments[1]); isString && strings.EqualFold(of, "Captain") {
+			for _, group := range bus.engine.GroupsInPlay(company) {
+				if group.Captain.Name != "" {
+					others = append(others, group)
+				}
+			}
+		} else if isString && (strings.EqualFold(of, "Enemy") || strings.EqualFold(of, "Friend")) {
+			friendly := strings.EqualFold(of, "Friend")
+			for _, other := range bus.engine.Skirmish.Companies {
+				if (other.Name == company) != friendly {
+					continue
+				}
+				for _, group := range bus.engine.GroupsInPlay(other.Name) {
+					if group.Id != dispatch.Group.Id {
+						others = append(others, group)
+					}
+				}
+			}
+		} else {
+			group, err := bus.groupArgument("Within", arguments, 1)
+			if err != nil {
+				return nil, err
+			}
+			others = append(others, group)
+		}
+		for _, other := range others {
+			if bus.engine.Within(dispatch.Group.Id, other.Id, inches) {
+				return tengo.TrueValue, nil
+			}
+		}
+		return tengo.FalseValue, nil
+	})
+	in := func(dispatch *Dispatch, arguments []tengo.Object) (bool, error) {
+		what, err := stringArgument("In", arguments, 0)
+		if err != nil {
+			return false, err
+		}
+		if strings.EqualFold(what, "Melee") {
+			return bus.engine.InMelee(dispatch.Group.Id), nil
+		}
+		terrain, err := ParseTerrain(what)
+		if err != nil {
+			return false, err
+		}
+		return bus.engine.InTerrain(dispatch.Group.Id, terrain), nil
+	}
+	bus.AddNative("Play", "In", func(dispatch *Dispatch, arguments ...tengo.Object) (tengo.Object, error) {
+		isIn, err := in(dispatch, arguments)
+		return tengoBool(isIn), err
+	})
+	bus.AddNative("Play", "NotIn", func(dispatch *Dispatch, arguments ...tengo.Object) (tengo.Object, error) {
+		isIn, err := in(dispatch, arguments)
+		return tengoBool(!isIn), err
+	})
+
+	bus.AddShorthand("within", "core.Play.Within")
+	bus.AddShorthand("notIn", "core.Play.NotIn")
+	bus.AddShorthand("treatTerrainAs", "func(from, to) { return core.Group.TreatTerrainAs(from, to, group) }")
+}
@@ -0,0 +1,48 @@
+package skirmish
+
+import (
+	"testing"
+)
+
+func TestMoveIntoContact(t *testing.T) {
+	place := func(engine *Engine, id string, location Location) {
+		groupState, _ := engine.GroupState(id)
+		groupState.Location = &location
+	}
+	contact := func(engine *Engine) Location {
+		attacker, _ := engine.Group("alpha-scouts")
+		target, _ := engine.Group("beta-raiders")
+		attacker.Move.Distance = 6
+		engine.moveIntoContact(attacker, target)
+		groupState, _ := engine.GroupState("alpha-scouts")
+		return *groupState.Location
+	}
+
+	engine := twoCompanySkirmish()
+	place(engine, "alpha-scouts", Location{X: 10, Y: 10})
+	place(engine, "beta-raiders", Location{X: 10, Y: 10})
+	if location := contact(engine); location != (Location{X: 9, Y: 10}) {
+		t.Errorf("expected an attacker on top of its target to move to its left, got %s", location)
+	}
+
+	engine = twoCompanySkirmish()
+	engine.Skirmish.Battlefield = Battlefield{Width: 24, Height: 24, Terrain: []Area{
+		{Name: "Cliff", Type: TerrainImpassable, Location: Location{X: 11, Y: 8}, Width: 1, Height: 2},
+	}}
+	place(engine, "alpha-scouts", Location{X: 14, Y: 10})
+	place(engine, "beta-raiders", Location{X: 10, Y: 10})
+	if location := contact(engine); location != (Location{X: 10, Y: 11}) {
+		t.Errorf("expected the attacker to go around impassable terrain, got %s", location)
+	}
+
+	engine = twoCompanySkirmish()
+	engine.Skirmish.Companies[1].Groups = append(engine.Skirmish.Companies[1].Groups, engine.Skirmish.Companies[1].Groups[0])
+	engine.Skirmish.Companies[1].Groups[1].Id = "beta-archers"
+	engine.Skirmish.GroupStates = append(engine.Skirmish.GroupStates, GroupState{Id: "beta-archers", Company: "Beta", Status: InPlay})
+	place(engine, "alpha-scouts", Location{X: 14, Y: 10})
+	place(engine, "beta-raiders", Location{X: 10, Y: 10})
+	place(engine, "beta-archers", Location{X: 11, Y: 10})
+	if location := contact(engine); location != (Location{X: 10, Y: 11}) {
+		t.Errorf("expected the attacker to avoid the side another group is on, got %s", location)
+	}
+}
@@ -0,0 +1,76 @@
+package skirmish
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/module/scripting"
+	"github.com/mitchellh/mapstructure"
+	"gopkg.in/yaml.v3"
+)
+
+const coreModulePath = "../../../../emfs/modules/core"
+
+// readCore decodes the entries of one of the core module's data files the way modules are decoded when cached.
+func readCore[T any](t *testing.T, file string) (entries []T) {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(coreModulePath, file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var document map[string]any
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		t.Fatal(err)
+	}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{WeaklyTypedInput: true, Result: &entries})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := decoder.Decode(document["entries"]); err != nil {
+		t.Fatalf("unable to decode %s: %s", file, err)
+	}
+	return entries
+}
+
+func coreTraits(t *testing.T) (traits []data.Trait) {
+	t.Helper()
+	for _, subtype := range []string{"BaseProfile", "Captain", "Special"} {
+		for _, trait := range readCore[data.Trait](t, filepath.Join("Traits", subtype+".yaml")) {
+			trait.Type = subtype
+			traits = append(traits, trait.WithSource("core"))
+		}
+	}
+	return traits
+}
+
+// coreScriptEngine returns a script engine with the core module's scripts, like the one the application plays with.
+func coreScriptEngine(t *testing.T) *scripting.Engine {
+	t.Helper()
+	read := func(file string) string {
+		content, err := os.ReadFile(filepath.Join(coreModulePath, "scripts", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(content)
+	}
+	coreModule := scripting.Module{Library: scripting.Library{Name: "core", Body: read("core.tengo")}}
+	submodules, err := os.ReadDir(filepath.Join(coreModulePath, "scripts", "submodules"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, submodule := range submodules {
+		coreModule.Submodules = append(coreModule.Submodules, scripting.Library{
+			Name: strings.TrimSuffix(submodule.Name(), ".tengo"),
+			Body: read(filepath.Join("submodules", submodule.Name())),
+		})
+	}
+
+	scriptEngine := scripting.NewEngine()
+	scriptEngine.SetStandardLibraries(scriptEngine.AllowedStandardLibraries())
+	scriptEngine.AddApplicationModule(coreModule)
+	scriptEngine.InitializeDice()
+	return scriptEngine
+}
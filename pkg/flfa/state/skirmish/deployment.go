@@ -0,0 +1,192 @@
+package skirmish
+
+import (
+	"fmt"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/utils"
+	"github.com/rs/zerolog/log"
+)
+
+type Phase string
+
+const (
+	PhaseDeployment Phase = "deployment"
+	PhasePlay       Phase = "play"
+)
+
+// DetermineAttacker has every Company roll 1d6 to determine who attacks. Trait scripts registered for
+// ProcessingInitiativeResult may adjust a Company's roll by up to one either way. The Company with the highest roll
+// attacks and every other Company defends; tied Companies roll again.
+func (engine *Engine) DetermineAttacker() error {
+	contenders := []string{}
+	for _, company := range engine.Skirmish.Companies {
+		contenders = append(contenders, company.Name)
+	}
+
+	engine.Skirmish.InitiativeRolls = make(map[string]int)
+	for len(contenders) > 1 {
+		highest := 0
+		var leaders []string
+		for _, name := range contenders {
+			roll, err := engine.rollForInitiative(name)
+			if err != nil {
+				return fmt.Errorf("unable to determine attacker: %s", err)
+			}
+			engine.Skirmish.InitiativeRolls[name] = roll
+			if roll > highest {
+				highest = roll
+				leaders = []string{name}
+			} else if roll == highest {
+				leaders = append(leaders, name)
+			}
+		}
+		contenders = leaders
+	}
+
+	engine.Skirmish.Attackers = contenders
+	engine.Skirmish.Defenders = []string{}
+	for _, company := range engine.Skirmish.Companies {
+		if company.Name != contenders[0] {
+			engine.Skirmish.Defenders = append(engine.Skirmish.Defenders, company.Name)
+		}
+	}
+	log.Trace().Msgf("'%s' is attacking after rolling %v", contenders[0], engine.Skirmish.InitiativeRolls)
+	return nil
+}
+
+func (engine *Engine) rollForInitiative(companyName string) (roll int, err error) {
+	roll = engine.Dice.D6(fmt.Sprintf("'%s' rolling for initiative", companyName))
+	event := &Event{
+		Name:     ProcessingInitiativeResult,
+		Subjects: engine.GroupsInPlay(companyName),
+		Result:   map[string]any{"company": companyName, "roll": roll},
+	}
+	effects, err := engine.Events.Dispatch(event)
+	if err != nil {
+		return roll, err
+	}
+	roll = intValue(event.Result["roll"], roll)
+	for _, effect := range effects.Named("AdjustInitiativeRoll") {
+		adjustment := intFrom(effect.Arguments, 0, 0)
+		if adjustment > 1 {
+			adjustment = 1
+		} else if adjustment < -1 {
+			adjustment = -1
+		}
+		log.Trace().Msgf("trait '%s' adjusted the initiative roll of '%s' by %+d", effect.Trait, companyName, adjustment)
+		roll += adjustment
+	}
+	return roll, nil
+}
+
+// Defenders deploy first, then the Companies take turns deploying one Group at a time.
+func (engine *Engine) deploymentOrder() (order []string) {
+	for _, name := range engine.Skirmish.Initiative {
+		if !utils.Contains(engine.Skirmish.Attackers, name) {
+			order = append(order, name)
+		}
+	}
+	for _, name := range engine.Skirmish.Initiative {
+		if !utils.Contains(order, name) {
+			order = append(order, name)
+		}
+	}
+	return order
+}
+
+func (engine *Engine) startDeployment() error {
+	engine.Skirmish.Phase = PhaseDeployment
+	engine.Skirmish.ActiveCompany = ""
+	return engine.nextDeployment()
+}
+
+// UndeployedGroups returns the Groups in play of the named Company which have not yet been deployed.
+func (engine *Engine) UndeployedGroups(companyName string) (groups []*data.Group) {
+	for _, group := range engine.GroupsInPlay(companyName) {
+		if groupState, _ := engine.GroupState(group.Id); !groupState.Deployed {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// Deploy places a Group of the Company currently deploying on the battlefield, in one of its side's deployment zones if
+// the Scenario has any. Once it is placed, the next Company with Groups left to deploy does so; when every Group has
+// been deployed, the deployment phase ends.
+func (engine *Engine) Deploy(groupId string, location Location) (err error) {
+	errorPrefix := "unable to deploy"
+	done := engine.recording(Update{Type: UpdateDeploy, Actor: groupId, EndLocation: &location})
+	defer func() { done(Result{}, err) }()
+	if engine.Skirmish.Phase != PhaseDeployment {
+		return fmt.Errorf("%s: the skirmish is not in the deployment phase", errorPrefix)
+	}
+	group, err := engine.Group(groupId)
+	if err != nil {
+		return fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	errorPrefix = fmt.Sprintf("unable to deploy group '%s'", group.Name)
+	groupState, err := engine.GroupState(groupId)
+	if err != nil {
+		return fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	if groupState.Company != engine.Skirmish.ActiveCompany {
+		return fmt.Errorf("%s: '%s' is deploying", errorPrefix, engine.Skirmish.ActiveCompany)
+	}
+	if groupState.Deployed {
+		return fmt.Errorf("%s: it has already been deployed", errorPrefix)
+	}
+	if !engine.inDeploymentZone(groupState.Company, location) {
+		return fmt.Errorf("%s: %s is outside of the deployment zones for '%s'", errorPrefix, location, groupState.Company)
+	}
+	if err := engine.PlaceGroup(groupId, location); err != nil {
+		return fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	groupState.Deployed = true
+	log.Trace().Msgf("'%s' deployed group '%s' at %s", groupState.Company, group.Name, location)
+
+	return engine.nextDeployment()
+}
+
+// nextDeployment hands deployment to the next Company in deployment order with Groups left to deploy, or ends the
+// deployment phase if there are none.
+func (engine *Engine) nextDeployment() error {
+	order := engine.deploymentOrder()
+	start := utils.FindIndex(order, engine.Skirmish.ActiveCompany) + 1
+	for offset := 0; offset < len(order); offset++ {
+		name := order[(start+offset)%len(order)]
+		if len(engine.UndeployedGroups(name)) > 0 {
+			engine.Skirmish.ActiveCompany = name
+			return nil
+		}
+	}
+	return engine.endDeployment()
+}
+
+// Trait scripts registered for EndOfDeployment run once every Group has been deployed, after which the first turn
+// begins.
+func (engine *Engine) endDeployment() error {
+	errorPrefix := "unable to end deployment"
+	effects, err := engine.Events.Dispatch(&Event{Name: EndOfDeployment})
+	if err != nil {
+		return fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	for _, effect := range effects.Named("Desert") {
+		roll := engine.Dice.D6(fmt.Sprintf("'%s' rolling for desertion (%s)", effect.Group.Name, effect.Trait))
+		log.Trace().Msgf("trait '%s' has group '%s' roll %d for desertion", effect.Trait, effect.Group.Name, roll)
+		if roll == 1 {
+			engine.RemoveGroup(effect.Group.Id, Deserted)
+		}
+	}
+
+	if err := engine.begin(); err != nil {
+		return fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	return nil
+}
+
+func (bus *Bus) addDeploymentNatives() {
+	bus.AddEffect("Play", "AdjustInitiativeRoll")
+	bus.AddEffect("Play", "Desert")
+	bus.AddShorthand("Desert", "core.Play.Desert")
+}
@@ -0,0 +1,464 @@
+package skirmish
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/dice"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/module/scripting"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/utils"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// The Engine runs a Skirmish turn by turn. Each turn, every Company takes its part of the turn in initiative order,
+// activating its Groups one at a time; each Group may activate once per turn and a failed activation ends that
+// Company's part of the turn.
+type Engine struct {
+	Skirmish     *Skirmish
+	ScriptEngine *scripting.Engine
+	Traits       []data.Trait
+	Spells       []data.Spell
+	Scenario     *data.Scenario
+	Prompts      []data.Prompt
+	Events       *Bus
+	Dice         *dice.Roller
+	scripts      *ScriptCache
+	updateHook   func(skirmish *Skirmish, update Update)
+	asker        Asker
+	depth        int
+	redoing      bool
+	step         *Step
+	// The answers given to Questions during the current command, and those still to be given when replaying one
+	answers []Answer
+	given   []Answer
+	// Where to send Notifications during play, and those from the current command to keep in the log with its Update
+	notifier      func(notification Notification)
+	notifications []Notification
+	// The Group the current command was given to
+	actor string
+	// The Agents available to control Companies, by name
+	agents map[string]Agent
+}
+
+type Option func(engine *Engine)
+
+func WithScriptEngine(scriptEngine *scripting.Engine) Option {
+	return func(engine *Engine) {
+		engine.ScriptEngine = scriptEngine
+	}
+}
+
+func WithTraits(traits []data.Trait) Option {
+	return func(engine *Engine) {
+		engine.Traits = traits
+	}
+}
+
+func WithSpells(spells []data.Spell) Option {
+	return func(engine *Engine) {
+		engine.Spells = spells
+	}
+}
+
+func WithSeed(seed int64) Option {
+	return func(engine *Engine) {
+		engine.Skirmish.Seed = seed
+	}
+}
+
+func WithMaximumTurns(turns int) Option {
+	return func(engine *Engine) {
+		engine.Skirmish.MaximumTurns = turns
+	}
+}
+
+// NewEngine returns an Engine for the Skirmish. Every die the Engine and its trait scripts roll comes from the
+// Skirmish's seed; if it does not have one yet, one is chosen from the current time.
+func NewEngine(skirmish *Skirmish, options ...Option) *Engine {
+	engine := &Engine{Skirmish: skirmish, agents: map[string]Agent{HeuristicAgent: Heuristic{}}}
+	for _, option := range options {
+		option(engine)
+	}
+	if engine.scripts == nil {
+		engine.scripts = NewScriptCache()
+	}
+	engine.scripts.attach(engine)
+	engine.Skirmish.Seed = engine.Dice.Seed
+	engine.Dice.Observer = func(roll dice.Roll) {
+		engine.observeRoll(roll)
+		engine.notifyRoll(roll)
+	}
+	engine.Events = NewBus(engine)
+	return engine
+}
+
+func (engine *Engine) reseed() {
+	engine.Dice.Reseed(engine.Skirmish.Seed)
+}
+
+// Start sets up the Skirmish: every Group is put in play, the attacker is determined if the Skirmish does not already
+// name one, and the deployment phase begins. The first turn starts once every Group has been deployed.
+func (engine *Engine) Start() error {
+	errorPrefix := "unable to start skirmish"
+	// Nothing done while starting is recorded; replaying the log always starts the Skirmish again first.
+	engine.depth++
+	defer func() { engine.depth-- }()
+	engine.answers = nil
+	engine.notifications = nil
+	engine.actor = ""
+	if len(engine.Skirmish.Companies) < 2 {
+		return fmt.Errorf("%s: need at least two companies, found %d", errorPrefix, len(engine.Skirmish.Companies))
+	}
+
+	var companyNames []string
+	for _, company := range engine.Skirmish.Companies {
+		if utils.Contains(companyNames, company.Name) {
+			return fmt.Errorf("%s: more than one company is named '%s'", errorPrefix, company.Name)
+		}
+		companyNames = append(companyNames, company.Name)
+	}
+
+	engine.Skirmish.GroupStates = []GroupState{}
+	for companyIndex := range engine.Skirmish.Companies {
+		company := &engine.Skirmish.Companies[companyIndex]
+		for groupIndex := range company.Groups {
+			group := &company.Groups[groupIndex]
+			if group.Id == "" {
+				group.Id = uuid.NewString()
+			}
+			engine.Skirmish.GroupStates = append(engine.Skirmish.GroupStates, GroupState{
+				Id:      group.Id,
+				Company: company.Name,
+				Status:  InPlay,
+				Morale:  MoraleSteady,
+			})
+		}
+	}
+	// The Setup is only taken the first time a Skirmish starts; when it is rebuilt, it starts from its Setup instead.
+	firstStart := len(engine.Skirmish.Setup.Companies) == 0
+	if firstStart {
+		engine.Skirmish.Setup = Setup{
+			Companies:  copyCompanies(engine.Skirmish.Companies),
+			Attackers:  append([]string{}, engine.Skirmish.Attackers...),
+			Defenders:  append([]string{}, engine.Skirmish.Defenders...),
+			Initiative: append([]string{}, engine.Skirmish.Initiative...),
+		}
+	}
+
+	engine.Events.bindDice()
+	engine.applyCaptainTraits()
+	engine.Events.RegisterGroups()
+
+	engine.Skirmish.Turn = 0
+	engine.Skirmish.Ended = false
+	engine.Skirmish.Winner = ""
+
+	if len(engine.Skirmish.Attackers) == 0 {
+		if err := engine.DetermineAttacker(); err != nil {
+			return fmt.Errorf("%s: %s", errorPrefix, err)
+		}
+	}
+	if len(engine.Skirmish.Initiative) == 0 {
+		engine.Skirmish.Initiative = engine.initiativeOrder()
+	}
+
+	if err := engine.startDeployment(); err != nil {
+		return fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	// Any Questions asked while starting, like whether to adjust an initiative roll, are answered the same way each
+	// time the Skirmish is rebuilt.
+	if firstStart {
+		engine.Skirmish.Setup.Answers = engine.answers
+		engine.Skirmish.Setup.Notifications = engine.notifications
+	}
+
+	return nil
+}
+
+// begin starts the first turn once every Group has been deployed.
+func (engine *Engine) begin() error {
+	engine.Skirmish.Phase = PhasePlay
+	engine.Skirmish.Turn = 1
+	engine.Skirmish.ActiveCompany = engine.Skirmish.Initiative[0]
+	log.Trace().Msgf("started skirmish with initiative order: %s", engine.Skirmish.Initiative)
+
+	if _, err := engine.Events.Dispatch(&Event{Name: BeforeFirstTurn}); err != nil {
+		return err
+	}
+
+	if !engine.CheckForEnd() && len(engine.ActivatableGroups()) == 0 {
+		engine.EndTurn()
+	}
+
+	return nil
+}
+
+// A Captain's trait is not one of its Group's traits, so any on_add scripts it has are applied to the Group when the
+// skirmish starts instead.
+func (engine *Engine) applyCaptainTraits() {
+	if engine.ScriptEngine == nil {
+		return
+	}
+	for companyIndex := range engine.Skirmish.Companies {
+		company := &engine.Skirmish.Companies[companyIndex]
+		for groupIndex := range company.Groups {
+			group := &company.Groups[groupIndex]
+			if group.Captain.Name == "" {
+				continue
+			}
+			captain := group.Captain
+			if len(captain.Scripting.OnAdd) == 0 {
+				captain.Scripting = data.GetTraitByName(captain.Name, engine.Traits).Scripting
+			}
+			updatedGroup, err := captain.ApplyToCaptain(group, engine.ScriptEngine)
+			if err != nil {
+				log.Warn().Msgf("unable to apply captain trait for group '%s': %s", group.Name, err)
+				continue
+			}
+			*group = *updatedGroup
+		}
+	}
+}
+
+// Attackers always take their part of the turn before defenders; any company which is neither comes after both in the
+// order they were added to the skirmish.
+func (engine *Engine) initiativeOrder() (order []string) {
+	order = append(order, engine.Skirmish.Attackers...)
+	for _, name := range engine.Skirmish.Defenders {
+		if !utils.Contains(order, name) {
+			order = append(order, name)
+		}
+	}
+	for _, company := range engine.Skirmish.Companies {
+		if !utils.Contains(order, company.Name) {
+			order = append(order, company.Name)
+		}
+	}
+	return order
+}
+
+func (engine *Engine) Company(name string) (*data.Company, error) {
+	for index := range engine.Skirmish.Companies {
+		if engine.Skirmish.Companies[index].Name == name {
+			return &engine.Skirmish.Companies[index], nil
+		}
+	}
+	return nil, fmt.Errorf("no company named '%s' in this skirmish", name)
+}
+
+func (engine *Engine) ActiveCompany() (*data.Company, error) {
+	return engine.Company(engine.Skirmish.ActiveCompany)
+}
+
+func (engine *Engine) Group(id string) (*data.Group, error) {
+	for companyIndex := range engine.Skirmish.Companies {
+		company := &engine.Skirmish.Companies[companyIndex]
+		for groupIndex := range company.Groups {
+			if company.Groups[groupIndex].Id == id {
+				return &company.Groups[groupIndex], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no group with id '%s' in this skirmish", id)
+}
+
+func (engine *Engine) CompanyOf(groupId string) string {
+	groupState, err := engine.GroupState(groupId)
+	if err != nil {
+		return ""
+	}
+	return groupState.Company
+}
+
+func (engine *Engine) GroupState(id string) (*GroupState, error) {
+	for index := range engine.Skirmish.GroupStates {
+		if engine.Skirmish.GroupStates[index].Id == id {
+			return &engine.Skirmish.GroupStates[index], nil
+		}
+	}
+	return nil, fmt.Errorf("no state for group with id '%s' in this skirmish", id)
+}
+
+// Distance returns how far apart two Groups are in inches. If either Group has not been placed on the battlefield, the
+// distance is unknown and every distance check should pass.
+func (engine *Engine) Distance(firstId string, secondId string) (distance float64, known bool) {
+	first, err := engine.GroupState(firstId)
+	if err != nil || first.Location == nil {
+		return 0, false
+	}
+	second, err := engine.GroupState(secondId)
+	if err != nil || second.Location == nil {
+		return 0, false
+	}
+	return math.Hypot(float64(first.Location.X-second.Location.X), float64(first.Location.Y-second.Location.Y)), true
+}
+
+func (engine *Engine) GroupsInPlay(companyName string) (groups []*data.Group) {
+	company, err := engine.Company(companyName)
+	if err != nil {
+		return groups
+	}
+	for index := range company.Groups {
+		groupState, err := engine.GroupState(company.Groups[index].Id)
+		if err == nil && groupState.InPlay() {
+			groups = append(groups, &company.Groups[index])
+		}
+	}
+	return groups
+}
+
+func (engine *Engine) ActivatableGroups() (groups []*data.Group) {
+	if engine.Skirmish.Ended || engine.Skirmish.Phase != PhasePlay {
+		return groups
+	}
+	for _, group := range engine.GroupsInPlay(engine.Skirmish.ActiveCompany) {
+		groupState, _ := engine.GroupState(group.Id)
+		if !groupState.Activated {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+func (engine *Engine) CanActivate(id string) bool {
+	if engine.Skirmish.ActiveGroup != "" {
+		return false
+	}
+	for _, group := range engine.ActivatableGroups() {
+		if group.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// FinishActivation completes the order given to the activated Group; the active Company's part of the turn ends once
+// it has no more Groups to activate.
+func (engine *Engine) FinishActivation() {
+	done := engine.recording(Update{Type: UpdateFinish, Actor: engine.Skirmish.ActiveGroup})
+	defer done(Result{}, nil)
+	engine.Skirmish.ActiveGroup = ""
+	engine.Skirmish.ActiveOrder = ""
+	engine.Skirmish.ActiveTargets = nil
+	if len(engine.ActivatableGroups()) == 0 {
+		engine.EndTurn()
+	}
+}
+
+// EndTurn hands the turn to the next Company in initiative order. When every Company has had its part of the turn, the
+// turn counter advances and every Group is able to activate again. The end of the game is checked on every hand off.
+func (engine *Engine) EndTurn() {
+	if engine.Skirmish.Ended {
+		return
+	}
+	done := engine.recording(Update{Type: UpdateEndTurn})
+	defer done(Result{}, nil)
+
+	nextIndex := utils.FindIndex(engine.Skirmish.Initiative, engine.Skirmish.ActiveCompany) + 1
+	if nextIndex >= len(engine.Skirmish.Initiative) {
+		nextIndex = 0
+		engine.scoreTurn()
+		engine.Skirmish.Turn++
+		for index := range engine.Skirmish.GroupStates {
+			engine.Skirmish.GroupStates[index].Activated = false
+		}
+		// Uses are limited per turn, not per Company's part of it
+		engine.Events.ResetUses()
+	}
+	engine.Skirmish.ActiveCompany = engine.Skirmish.Initiative[nextIndex]
+	engine.Skirmish.ActiveGroup = ""
+	engine.Skirmish.ActiveOrder = ""
+	engine.Skirmish.ActiveTargets = nil
+	engine.expireSpellEffects()
+	log.Trace().Msgf("turn %d: %s is now active", engine.Skirmish.Turn, engine.Skirmish.ActiveCompany)
+	engine.notify(Notification{Kind: NotificationState, Company: engine.Skirmish.ActiveCompany, Message: fmt.Sprintf("%s is now active", engine.Skirmish.ActiveCompany)})
+
+	if engine.CheckForEnd() {
+		return
+	}
+
+	// A company with nothing left to activate forfeits its part of the turn
+	if len(engine.ActivatableGroups()) == 0 {
+		engine.EndTurn()
+	}
+}
+
+// CheckForEnd determines whether the skirmish is over, either because only one Company still has Groups in play, because
+// a Company has reached the score the Scenario needs to win, or because the maximum number of turns has been played. If
+// it is, the skirmish is marked as ended and the Winner is set. When the turn limit ends the game, the Scenario decides
+// who wins; ties are a draw.
+func (engine *Engine) CheckForEnd() bool {
+	if engine.Skirmish.Ended {
+		return true
+	}
+
+	var companiesInPlay []string
+	for _, company := range engine.Skirmish.Companies {
+		if len(engine.GroupsInPlay(company.Name)) > 0 {
+			companiesInPlay = append(companiesInPlay, company.Name)
+		}
+	}
+
+	if len(companiesInPlay) <= 1 {
+		engine.Skirmish.Ended = true
+		if len(companiesInPlay) == 1 {
+			engine.Skirmish.Winner = companiesInPlay[0]
+		}
+	} else if winner, won := engine.scoreVictory(companiesInPlay); won {
+		engine.Skirmish.Ended = true
+		engine.Skirmish.Winner = winner
+	} else if engine.Skirmish.MaximumTurns > 0 && engine.Skirmish.Turn > engine.Skirmish.MaximumTurns {
+		engine.Skirmish.Ended = true
+		engine.Skirmish.Winner = engine.turnLimitWinner(companiesInPlay)
+	}
+
+	if engine.Skirmish.Ended {
+		log.Trace().Msgf("skirmish ended on turn %d; winner: '%s'", engine.Skirmish.Turn, engine.Skirmish.Winner)
+		message := "the skirmish ended in a draw"
+		if engine.Skirmish.Winner != "" {
+			message = fmt.Sprintf("the skirmish ended; %s won", engine.Skirmish.Winner)
+		}
+		engine.notify(Notification{Kind: NotificationState, Message: message})
+	}
+
+	return engine.Skirmish.Ended
+}
+
+func (engine *Engine) leadingCompany(companyNames []string, pointsOf func(name string) int) (leader string) {
+	highestPoints := math.MinInt
+	for _, name := range companyNames {
+		points := pointsOf(name)
+		if points > highestPoints {
+			highestPoints = points
+			leader = name
+		} else if points == highestPoints {
+			leader = ""
+		}
+	}
+	return leader
+}
+
+func sum(rolls []int) (total int) {
+	for _, roll := range rolls {
+		total += roll
+	}
+	return total
+}
+
+// RemoveGroup takes a Group out of play with the given status, checking to see if that ends the skirmish.
+func (engine *Engine) RemoveGroup(id string, status GroupStatus) (err error) {
+	done := engine.recording(Update{Type: UpdateRemove, Subtype: string(status), Actor: id})
+	defer func() { done(Result{}, err) }()
+	groupState, err := engine.GroupState(id)
+	if err != nil {
+		return err
+	}
+	groupState.Status = status
+	if group, err := engine.Group(id); err == nil {
+		engine.notifyState(id, "'%s' was %s", group.Name, status)
+	}
+	engine.CheckForEnd()
+	return nil
+}
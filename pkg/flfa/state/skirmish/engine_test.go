@@ -0,0 +1,100 @@
+package skirmish
+
+import (
+	"testing"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/module/scripting"
+)
+
+// twoCompanySkirmish returns an Engine partway through the first turn, with Alpha active and Beta to go next.
+func twoCompanySkirmish() *Engine {
+	return NewEngine(&Skirmish{
+		Companies: []data.Company{
+			{Name: "Alpha", Groups: []data.Group{{Name: "Scouts", Id: "alpha-scouts"}}},
+			{Name: "Beta", Groups: []data.Group{{Name: "Raiders", Id: "beta-raiders"}}},
+		},
+		GroupStates: []GroupState{
+			{Id: "alpha-scouts", Company: "Alpha", Status: InPlay, Morale: MoraleSteady},
+			{Id: "beta-raiders", Company: "Beta", Status: InPlay, Morale: MoraleSteady},
+		},
+		Phase:         PhasePlay,
+		Turn:          1,
+		Initiative:    []string{"Alpha", "Beta"},
+		ActiveCompany: "Alpha",
+	}, WithSeed(1))
+}
+
+func TestEndTurnResetsUsesOncePerTurn(t *testing.T) {
+	engine := twoCompanySkirmish()
+	key := "Trait: Brave for 'alpha-scouts'"
+	engine.Events.uses[key] = 1
+
+	engine.EndTurn()
+	if engine.Skirmish.ActiveCompany != "Beta" || engine.Skirmish.Turn != 1 {
+		t.Fatalf("expected Beta to be active in turn 1, got %s in turn %d", engine.Skirmish.ActiveCompany, engine.Skirmish.Turn)
+	}
+	if engine.Events.uses[key] != 1 {
+		t.Errorf("expected uses to be kept when the turn passes to the next company, got %d", engine.Events.uses[key])
+	}
+
+	engine.EndTurn()
+	if engine.Skirmish.ActiveCompany != "Alpha" || engine.Skirmish.Turn != 2 {
+		t.Fatalf("expected Alpha to be active in turn 2, got %s in turn %d", engine.Skirmish.ActiveCompany, engine.Skirmish.Turn)
+	}
+	if engine.Events.uses[key] != 0 {
+		t.Errorf("expected uses to be reset when the turn advances, got %d", engine.Events.uses[key])
+	}
+}
+
+func TestNewEngineLeavesScriptEngineDiceAlone(t *testing.T) {
+	scriptEngine := scripting.NewEngine()
+	scriptEngine.Settings.RandomSeed = 3
+	scriptEngine.InitializeDice()
+	roller := scriptEngine.Dice
+	diceModule := scriptEngine.Settings.NativeModules["dice"]
+
+	skirmish := twoCompanySkirmish().Skirmish
+	engine := NewEngine(skirmish, WithSeed(1), WithScriptEngine(scriptEngine))
+	engine.Events.RegisterGroups()
+
+	if engine.ScriptEngine == scriptEngine {
+		t.Fatal("expected the skirmish to bind its natives on its own copy of the script engine")
+	}
+	if scriptEngine.Dice != roller {
+		t.Error("expected the script engine to keep its own dice after a skirmish started")
+	}
+	if len(scriptEngine.Settings.NativeModules) != 1 || scriptEngine.Settings.NativeModules["dice"]["roll"] != diceModule["roll"] {
+		t.Errorf("expected the script engine's native modules to be unchanged, got %s", scriptEngine.NativeModuleNames())
+	}
+}
+
+// The notifier may be the bubbletea loop which is asking for the valid orders, so querying must never notify.
+func TestQueriesNotifyNothing(t *testing.T) {
+	skirmish := twoCompanySkirmish().Skirmish
+	scouts := &skirmish.Companies[0].Groups[0]
+	scouts.Traits = []string{"Chatty"}
+	scouts.Move = data.Move{Activation: 5, Distance: 6}
+	chatty := data.Trait{Name: "Chatty", Scripting: data.TraitScripting{InPlay: []data.TraitScriptingInPlay{{
+		RegisterFor: []string{string(GetValidActivations)},
+		Then:        []string{`core.Play.Notify("chattering")`},
+	}}}}
+	var notified []Notification
+	engine := NewEngine(skirmish, WithSeed(1), WithScriptEngine(coreScriptEngine(t)), WithTraits([]data.Trait{chatty}),
+		WithNotifier(func(notification Notification) { notified = append(notified, notification) }))
+	engine.Events.RegisterGroups()
+
+	if _, err := engine.ValidActivations(scouts.Id); err != nil {
+		t.Fatal(err)
+	}
+	if len(notified) != 0 {
+		t.Errorf("expected no notifications while querying valid activations, got %+v", notified)
+	}
+
+	if _, err := engine.Activate(scouts.Id, OrderMove); err != nil {
+		t.Fatal(err)
+	}
+	if len(notified) == 0 {
+		t.Error("expected notifications when activating")
+	}
+}
@@ -0,0 +1,569 @@
+package skirmish
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/module/scripting"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/utils"
+	"github.com/d5/tengo/v2"
+	"github.com/rs/zerolog/log"
+)
+
+type EventName string
+
+const (
+	EndOfDeployment            EventName = "EndOfDeployment"
+	ProcessingInitiativeResult EventName = "ProccessingInitiativeResult" // sic; matches the name in the core traits
+	BeforeFirstTurn            EventName = "BeforeFirstTurn"
+	GetValidActivations        EventName = "GetValidActivations"
+	TestingToActivateMove      EventName = "TestingToActivateMove"
+	TestingToActivateAttack    EventName = "TestingToActivateAttack"
+	TestingToActivateShoot     EventName = "TestingToActivateShoot"
+	FailedToActivate           EventName = "FailedToActivate"
+	CarryingOutOrder           EventName = "CarryingOutOrder"
+	DeterminingMovement        EventName = "DeterminingMovement"
+	TargetedByAttack           EventName = "TargetedByAttack"
+	CalculatingToHit           EventName = "CalculatingToHit"
+	RolledToHit                EventName = "RolledToHit"
+	CountingInflictedHits      EventName = "CountingInflictedHits"
+	ResolvingAttack            EventName = "ResolvingAttack"
+	ResolvingShoot             EventName = "ResolvingShoot"
+	ShouldTestResolve          EventName = "ShouldTestResolve"
+	PassedResolveTest          EventName = "PassedResolveTest"
+	RallyResultFailure         EventName = "RallyResultFailure"
+	ResolveTerrifying          EventName = "ResolveTerrifying"
+	SpellCast                  EventName = "SpellCast"
+)
+
+// Who an in-play trait script applies to, relative to the group which has the trait.
+const (
+	AppliesToSelf      = "self"
+	AppliesToFollowers = "followers"
+	AppliesToCaptain   = "captain"
+	AppliesToFriends   = "friends"
+	AppliesToEnemies   = "enemies"
+	AppliesToAll       = "all"
+)
+
+// An Event is fired by the engine whenever something happens that traits may care about. The Actor is the Group doing
+// something and the Target is the Group it is being done to, if any. The Subjects are the Groups the event concerns; if
+// none are specified, the event concerns the Actor or, if there is no Actor, every Group in play. The Result holds any
+// event data the trait scripts may read or modify, like the dice rolled. The in-play scripts of any Ignored traits are
+// not run for the event.
+type Event struct {
+	Name     EventName
+	Actor    *data.Group
+	Target   *data.Group
+	Subjects []*data.Group
+	Result   map[string]any
+	Ignored  []string
+}
+
+// An Effect is something a trait script asked for that the engine must resolve after the event, like limiting the
+// orders a Group may be given.
+type Effect struct {
+	Name      string
+	Arguments []any
+	Group     *data.Group
+	Owner     *data.Group
+	Trait     string
+}
+
+type Effects []Effect
+
+func (effects Effects) Named(name string) (named Effects) {
+	for _, effect := range effects {
+		if effect.Name == name {
+			named = append(named, effect)
+		}
+	}
+	return named
+}
+
+// A Dispatch is the context for a single in-play trait script run; the Group is the one the script is running for and
+// the Owner is the one with the trait.
+type Dispatch struct {
+	Event   *Event
+	Group   *data.Group
+	Owner   *data.Group
+	Trait   string
+	Effects Effects
+	// The Prompts answered while the script ran
+	prompted []prompted
+	// The Groups already converted for scripts run for the same event, until one of them runs and may change them
+	converted map[*data.Group]map[string]any
+}
+
+// A Native is a go function made available to in-play trait scripts through one of the core script module's submodules
+// such as core.Play; it is always called with the Dispatch for the currently running script.
+type Native func(dispatch *Dispatch, arguments ...tengo.Object) (tengo.Object, error)
+
+type registration struct {
+	Owner  string
+	Trait  string
+	Index  int
+	InPlay data.TraitScriptingInPlay
+	// The spell effect the script was registered for, if it belongs to a spell rather than a trait
+	Effect string
+}
+
+func (registration registration) ScriptName() string {
+	return fmt.Sprintf("InPlay: '%s' [%d]", registration.Trait, registration.Index)
+}
+
+type shorthand struct {
+	Name       string
+	Expression string
+}
+
+// The Bus registers the in-play scripts for every trait of every Group in the skirmish against the events they name
+// and runs them whenever the engine dispatches one of those events.
+type Bus struct {
+	engine        *Engine
+	registrations map[EventName][]registration
+	natives       map[string]map[string]Native
+	shorthands    []shorthand
+	uses          map[string]int
+	current       *Dispatch
+}
+
+func NewBus(engine *Engine) *Bus {
+	bus := &Bus{
+		engine:        engine,
+		registrations: make(map[EventName][]registration),
+		natives:       make(map[string]map[string]Native),
+		uses:          make(map[string]int),
+	}
+	// Scripts compiled for an earlier Engine sharing this one's ScriptCache call the natives of the newest Bus
+	if engine.scripts != nil {
+		engine.scripts.bus = bus
+	}
+	bus.addPlayNatives()
+	bus.addPromptNatives()
+	bus.addNotifyNatives()
+	bus.addActivationNatives()
+	bus.addMeleeNatives()
+	bus.addMoraleNatives()
+	bus.addSpellNatives()
+	bus.addBattlefieldNatives()
+	bus.addDeploymentNatives()
+	return bus
+}
+
+func (bus *Bus) AddNative(module string, name string, native Native) {
+	if bus.natives[module] == nil {
+		bus.natives[module] = make(map[string]Native)
+	}
+	bus.natives[module][name] = native
+}
+
+// AddEffect makes a native which records the call and its arguments as an Effect for the engine to resolve.
+func (bus *Bus) AddEffect(module string, name string) {
+	bus.AddNative(module, name, func(dispatch *Dispatch, arguments ...tengo.Object) (tengo.Object, error) {
+		effect := Effect{
+			Name:  name,
+			Group: dispatch.Group,
+			Owner: dispatch.Owner,
+			Trait: dispatch.Trait,
+		}
+		for _, argument := range arguments {
+			effect.Arguments = append(effect.Arguments, tengo.ToInterface(argument))
+		}
+		dispatch.Effects = append(dispatch.Effects, effect)
+		return tengo.UndefinedValue, nil
+	})
+}
+
+// AddShorthand declares a variable at the top of every in-play script so trait authors can write, for example,
+// `prompt("DefiantReaction")` instead of `core.Play.Prompt("DefiantReaction")`.
+func (bus *Bus) AddShorthand(name string, expression string) {
+	bus.shorthands = append(bus.shorthands, shorthand{Name: name, Expression: expression})
+}
+
+func (bus *Bus) addPlayNatives() {
+	bus.AddNative("Play", "ActorIs", func(dispatch *Dispatch, arguments ...tengo.Object) (tengo.Object, error) {
+		id, err := stringArgument("ActorIs", arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+		return tengoBool(dispatch.Event.Actor != nil && dispatch.Event.Actor.Id == id), nil
+	})
+	bus.AddNative("Play", "TargetIs", func(dispatch *Dispatch, arguments ...tengo.Object) (tengo.Object, error) {
+		id, err := stringArgument("TargetIs", arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+		return tengoBool(dispatch.Event.Target != nil && dispatch.Event.Target.Id == id), nil
+	})
+	bus.AddNative("Play", "HasTrait", func(dispatch *Dispatch, arguments ...tengo.Object) (tengo.Object, error) {
+		name, err := stringArgument("HasTrait", arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, trait := range dispatch.Group.Traits {
+			if strings.EqualFold(trait, name) {
+				return tengo.TrueValue, nil
+			}
+		}
+		return tengoBool(strings.EqualFold(dispatch.Group.Captain.Name, name)), nil
+	})
+	bus.AddShorthand("hasTrait", "core.Play.HasTrait")
+}
+
+// The native modules are bound to the Engine's copy of the script engine once; they call the natives of whichever Bus
+// is using the copy, so scripts compiled for one Engine keep working for the next to share its ScriptCache.
+func (bus *Bus) bindNativeModules() {
+	bus.bindDice()
+	cache := bus.engine.scripts
+	if cache.nativesBound {
+		return
+	}
+	var moduleNames []string
+	for module := range bus.natives {
+		moduleNames = append(moduleNames, module)
+	}
+	sort.Strings(moduleNames)
+	for _, module := range moduleNames {
+		attributes := make(map[string]tengo.Object)
+		for name := range bus.natives[module] {
+			module, name := module, name
+			attributes[name] = &tengo.UserFunction{
+				Name: name,
+				Value: func(arguments ...tengo.Object) (tengo.Object, error) {
+					current := cache.bus
+					if current.current == nil {
+						return nil, fmt.Errorf("core.%s.%s can only be called by an in-play trait script", module, name)
+					}
+					return current.natives[module][name](current.current, arguments...)
+				},
+			}
+		}
+		bus.engine.ScriptEngine.AddNativeModule(module, attributes)
+	}
+	cache.nativesBound = true
+}
+
+// Trait scripts roll the skirmish's dice, so their rolls are as reproducible as the engine's own.
+func (bus *Bus) bindDice() {
+	cache := bus.engine.scripts
+	if bus.engine.ScriptEngine == nil || cache.diceBound {
+		return
+	}
+	bus.engine.ScriptEngine.UseDice(cache.dice, func() string {
+		current := cache.bus.current
+		if current == nil {
+			return "script"
+		}
+		return fmt.Sprintf("'%s' of '%s'", current.Trait, current.Owner.Name)
+	})
+	cache.diceBound = true
+}
+
+func (bus *Bus) prelude() string {
+	var scriptBuilder strings.Builder
+	for _, shorthand := range bus.shorthands {
+		scriptBuilder.WriteString(fmt.Sprintf("%s := %s\n", shorthand.Name, shorthand.Expression))
+	}
+	return scriptBuilder.String()
+}
+
+// RegisterGroups finds the in-play scripts for the traits of every Group in the skirmish, including their Captain's
+// trait, and for any spells still affecting them, and registers them against the events they name. Scripts which
+// cannot compile are logged and skipped so that a broken trait does not prevent the skirmish from being played.
+func (bus *Bus) RegisterGroups() {
+	if bus.engine.ScriptEngine == nil {
+		return
+	}
+	bus.bindNativeModules()
+	bus.registrations = make(map[EventName][]registration)
+	for _, company := range bus.engine.Skirmish.Companies {
+		for _, group := range company.Groups {
+			var traits []data.Trait
+			for _, name := range group.Traits {
+				trait := data.GetTraitMatchingName(name, bus.engine.Traits)
+				if trait.Name == "" {
+					log.Warn().Msgf("unable to find trait '%s' for group '%s'; its in-play scripts will not run", name, group.Name)
+					continue
+				}
+				trait.Name = name
+				traits = append(traits, trait)
+			}
+			if group.Captain.Name != "" {
+				captain := group.Captain
+				if len(captain.Scripting.InPlay) == 0 {
+					captain.Scripting = data.GetTraitByName(captain.Name, bus.engine.Traits).Scripting
+				}
+				traits = append(traits, captain)
+			}
+			for _, trait := range traits {
+				for index, inPlay := range trait.Scripting.InPlay {
+					bus.register(registration{Owner: group.Id, Trait: trait.Name, Index: index, InPlay: inPlay})
+				}
+			}
+		}
+	}
+	for _, effect := range bus.engine.Skirmish.SpellEffects {
+		bus.RegisterSpellEffect(effect)
+	}
+}
+
+// ScriptBody returns the body of an in-play or prompt script as the bus registers it, with every shorthand declared
+// before it.
+func (bus *Bus) ScriptBody(script string) string {
+	body := strings.Join([]string{bus.prelude(), script}, "\n")
+	// {{guid}} always refers to the group which has the trait
+	return strings.ReplaceAll(body, "{{guid}}", "owner.id")
+}
+
+func (bus *Bus) register(registration registration) {
+	body := bus.ScriptBody(registration.InPlay.ScriptBody())
+	if _, err := bus.engine.scripts.compile(registration.ScriptName(), body, ScriptVariables()...); err != nil {
+		log.Warn().Msgf("unable to register in-play script for trait '%s': %s", registration.Trait, err)
+		return
+	}
+
+	for _, eventName := range registration.InPlay.RegisterFor {
+		bus.registrations[EventName(eventName)] = append(bus.registrations[EventName(eventName)], registration)
+	}
+}
+
+// ScriptVariables returns the names of the variables every in-play script runs with.
+func ScriptVariables() []string {
+	return []string{"group", "owner", "actor", "target", "event", "result", "hits"}
+}
+
+// ResetUses clears the count of times each in-play script has run this turn
+func (bus *Bus) ResetUses() {
+	bus.uses = make(map[string]int)
+}
+
+// Dispatch runs every in-play script registered for the event whose conditions are met, for every Group the script
+// applies to, and returns the Effects they asked for. Any changes the scripts make to the event's result are kept. A
+// script only counts as used when it runs for a command, not when the Engine is only queried.
+func (bus *Bus) Dispatch(event *Event) (effects Effects, err error) {
+	if event.Result == nil {
+		event.Result = make(map[string]any)
+	}
+
+	subjects := event.Subjects
+	if len(subjects) == 0 {
+		if event.Actor != nil {
+			subjects = []*data.Group{event.Actor}
+		} else {
+			for _, company := range bus.engine.Skirmish.Companies {
+				subjects = append(subjects, bus.engine.GroupsInPlay(company.Name)...)
+			}
+		}
+	}
+
+	converted := make(map[*data.Group]map[string]any)
+	for _, registration := range bus.registrations[event.Name] {
+		if utils.Contains(event.Ignored, registration.Trait) {
+			continue
+		}
+		owner, err := bus.engine.Group(registration.Owner)
+		if err != nil {
+			continue
+		}
+		if ownerState, _ := bus.engine.GroupState(owner.Id); ownerState == nil || !ownerState.InPlay() {
+			continue
+		}
+		for _, subject := range subjects {
+			if !bus.appliesTo(registration.InPlay.AppliesTo, owner, subject) || bus.exhausted(registration, subject) {
+				continue
+			}
+
+			dispatch := &Dispatch{Event: event, Group: subject, Owner: owner, Trait: registration.Trait, converted: converted}
+			ran, err := bus.run(registration, dispatch)
+			if err != nil {
+				return effects, fmt.Errorf("unable to resolve '%s' for trait '%s' of group '%s': %s", event.Name, registration.Trait, owner.Name, err)
+			}
+			if ran {
+				converted = make(map[*data.Group]map[string]any)
+				log.Trace().Msgf("%s: trait '%s' of group '%s' applied to group '%s'", event.Name, registration.Trait, owner.Name, subject.Name)
+				if !bus.engine.querying() {
+					bus.use(registration, subject)
+				}
+				bus.engine.observeTrigger(Trigger{Event: event.Name, Trait: registration.Trait, Owner: owner.Name, Group: subject.Name})
+				bus.engine.notifyTrigger(dispatch)
+				effects = append(effects, dispatch.Effects...)
+			}
+		}
+	}
+
+	return effects, nil
+}
+
+func (bus *Bus) run(registration registration, dispatch *Dispatch) (ran bool, err error) {
+	compiled, ok := bus.engine.scripts.compiled[registration.ScriptName()]
+	if !ok {
+		return false, fmt.Errorf("script '%s' is not registered", registration.ScriptName())
+	}
+	// Every run gets a clone, as a script may run again from a native it calls
+	script := compiled.Clone()
+	if err := bus.addScriptVariables(script, dispatch); err != nil {
+		return false, err
+	}
+
+	bus.current = dispatch
+	defer func() { bus.current = nil }()
+	if err := script.Run(); err != nil {
+		return false, err
+	}
+
+	if updatedResult, ok := script.Get("result").Value().(map[string]any); ok {
+		dispatch.Event.Result = updatedResult
+	}
+	if err := bus.runPrompted(dispatch); err != nil {
+		return false, err
+	}
+
+	return script.Get("in_play_conditions_met").Bool(), nil
+}
+
+func (bus *Bus) addScriptVariables(script *tengo.Compiled, dispatch *Dispatch) error {
+	for variable, group := range map[string]*data.Group{
+		"group":  dispatch.Group,
+		"owner":  dispatch.Owner,
+		"actor":  dispatch.Event.Actor,
+		"target": dispatch.Event.Target,
+	} {
+		if group == nil {
+			script.Set(variable, nil)
+			continue
+		}
+		tengoizedGroup, ok := dispatch.converted[group]
+		if !ok {
+			var err error
+			if tengoizedGroup, err = scripting.ConvertToTengoMap(group); err != nil {
+				return err
+			}
+			if dispatch.converted != nil {
+				dispatch.converted[group] = tengoizedGroup
+			}
+		}
+		if err := script.Set(variable, tengoizedGroup); err != nil {
+			return err
+		}
+	}
+	script.Set("event", string(dispatch.Event.Name))
+	scriptResult, _ := tengoCompatible(dispatch.Event.Result).(map[string]any)
+	if err := script.Set("result", scriptResult); err != nil {
+		return err
+	}
+	return script.Set("hits", scriptResult["hits"])
+}
+
+func (bus *Bus) appliesTo(appliesTo string, owner *data.Group, subject *data.Group) bool {
+	ownerCompany := bus.engine.CompanyOf(owner.Id)
+	subjectCompany := bus.engine.CompanyOf(subject.Id)
+	switch strings.ToLower(appliesTo) {
+	case "", AppliesToSelf:
+		return owner.Id == subject.Id
+	case AppliesToFollowers:
+		return ownerCompany == subjectCompany && owner.Id != subject.Id
+	case AppliesToCaptain:
+		return ownerCompany == subjectCompany && subject.Captain.Name != ""
+	case AppliesToFriends:
+		return ownerCompany == subjectCompany
+	case AppliesToEnemies:
+		return ownerCompany != subjectCompany
+	case AppliesToAll:
+		return true
+	}
+	log.Warn().Msgf("unknown applies_to value '%s'; must be one of: %s", appliesTo, strings.Join([]string{
+		AppliesToSelf, AppliesToFollowers, AppliesToCaptain, AppliesToFriends, AppliesToEnemies, AppliesToAll,
+	}, ", "))
+	return false
+}
+
+func (registration registration) useKey(subject *data.Group, global bool) string {
+	if global {
+		return fmt.Sprintf("%s for '%s'", registration.ScriptName(), registration.Owner)
+	}
+	return fmt.Sprintf("%s for '%s' -> '%s'", registration.ScriptName(), registration.Owner, subject.Id)
+}
+
+// registeredFor reports whether any in-play script of the Group's traits is registered for the event.
+func (bus *Bus) registeredFor(name EventName, owner string) bool {
+	for _, registration := range bus.registrations[name] {
+		if registration.Owner == owner {
+			return true
+		}
+	}
+	return false
+}
+
+func (bus *Bus) exhausted(registration registration, subject *data.Group) bool {
+	for _, uses := range registration.InPlay.Uses {
+		if uses.PerTurn > 0 && bus.uses[registration.useKey(subject, false)] >= uses.PerTurn {
+			return true
+		}
+		if uses.GlobalPerTurn > 0 && bus.uses[registration.useKey(subject, true)] >= uses.GlobalPerTurn {
+			return true
+		}
+	}
+	return false
+}
+
+func (bus *Bus) use(registration registration, subject *data.Group) {
+	bus.uses[registration.useKey(subject, false)]++
+	bus.uses[registration.useKey(subject, true)]++
+}
+
+// Tengo can only convert untyped slices and maps, so typed values like the dice rolled need converting before an event
+// result can be handed to a script.
+func tengoCompatible(value any) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		converted := make(map[string]any, len(typed))
+		for key, item := range typed {
+			converted[key] = tengoCompatible(item)
+		}
+		return converted
+	case []any:
+		converted := make([]any, len(typed))
+		for index, item := range typed {
+			converted[index] = tengoCompatible(item)
+		}
+		return converted
+	case []int:
+		converted := make([]any, len(typed))
+		for index, item := range typed {
+			converted[index] = item
+		}
+		return converted
+	case []string:
+		converted := make([]any, len(typed))
+		for index, item := range typed {
+			converted[index] = item
+		}
+		return converted
+	}
+	return value
+}
+
+func stringArgument(name string, arguments []tengo.Object, index int) (string, error) {
+	if len(arguments) <= index {
+		return "", tengo.ErrWrongNumArguments
+	}
+	value, ok := tengo.ToString(arguments[index])
+	if !ok {
+		return "", tengo.ErrInvalidArgumentType{
+			Name:     fmt.Sprintf("%s argument %d", name, index+1),
+			Expected: "string",
+			Found:    arguments[index].TypeName(),
+		}
+	}
+	return value, nil
+}
+
+func tengoBool(value bool) tengo.Object {
+	if value {
+		return tengo.TrueValue
+	}
+	return tengo.FalseValue
+}
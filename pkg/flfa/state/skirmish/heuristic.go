@@ -0,0 +1,445 @@
+package skirmish
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/prompts/dynamic"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/utils"
+)
+
+const HeuristicAgent = "heuristic"
+
+// The Heuristic Agent weighs every order a Group could be given by how likely the Group is to activate for it and what
+// the order should achieve, judged from the profiles of the Groups involved: their to-hit values, Fighting Strength,
+// Toughness, and Resolve. It takes traits into account through the orders and targets they allow, the overrides they
+// add, and the points they are worth. It never rolls dice, so it always decides the same way in the same situation.
+type Heuristic struct{}
+
+const (
+	// Orders worth less than this are not worth risking the rest of the Company's part of the turn on.
+	heuristicThreshold = 0.05
+	// How many inches apart Groups are deployed
+	deploymentSpacing = 4
+)
+
+// Deploy spreads the Company's Groups along the front of its deployment zone, or of its half of the battlefield if the
+// Scenario has no zones for it, facing the enemy.
+func (heuristic Heuristic) Deploy(engine *Engine, groupId string) (Location, error) {
+	companyName := engine.CompanyOf(groupId)
+	battlefield := engine.Skirmish.Battlefield
+	width, height := battlefield.Width, battlefield.Height
+	if width <= 0 || height <= 0 {
+		width, height = 48, 48
+	}
+	attacking := utils.Contains(engine.Skirmish.Attackers, companyName)
+
+	zones := []data.DeploymentZone{}
+	if engine.Scenario != nil {
+		side := data.DeploymentDefenders
+		if attacking {
+			side = data.DeploymentAttackers
+		}
+		zones = engine.Scenario.DeploymentZonesFor(side)
+	}
+	if len(zones) == 0 {
+		zone := data.DeploymentZone{X: 0, Y: 0, Width: width, Height: height / 4}
+		if attacking {
+			zone.Y = height - zone.Height
+		}
+		zones = append(zones, zone)
+	}
+
+	for _, zone := range zones {
+		// The row of the zone nearest the middle of the battlefield is its front
+		rows := []int{zone.Y + zone.Height, zone.Y}
+		if zone.Y+zone.Height/2 > height/2 {
+			rows = []int{zone.Y, zone.Y + zone.Height}
+		}
+		// Groups are placed a few inches apart, working outward from the middle of the row and then filling any gaps
+		middle := zone.X + zone.Width/2
+		var columns []int
+		for offset := 0; offset <= zone.Width; offset += deploymentSpacing {
+			columns = append(columns, middle+offset, middle-offset)
+		}
+		for column := zone.X; column <= zone.X+zone.Width; column++ {
+			columns = append(columns, column)
+		}
+		for _, row := range rows {
+			for _, column := range columns {
+				location := Location{X: column, Y: row}
+				if heuristic.canDeploy(engine, companyName, location) {
+					return location, nil
+				}
+			}
+		}
+	}
+	return Location{}, fmt.Errorf("unable to find anywhere for '%s' to deploy", companyName)
+}
+
+func (heuristic Heuristic) canDeploy(engine *Engine, companyName string, location Location) bool {
+	x, y := float64(location.X), float64(location.Y)
+	if !engine.inDeploymentZone(companyName, location) || !engine.Skirmish.Battlefield.OnBattlefield(x, y) {
+		return false
+	}
+	for _, terrain := range engine.Skirmish.Battlefield.TerrainAt(x, y) {
+		if terrain == TerrainImpassable {
+			return false
+		}
+	}
+	for _, groupState := range engine.Skirmish.GroupStates {
+		if groupState.Location != nil && *groupState.Location == location {
+			return false
+		}
+	}
+	return true
+}
+
+// Activate picks the Group and order worth the most, weighing each by the chance to activate for it. Shaken Groups
+// rallying are valued like any other order, since a Group that does not rally is of little use. If nothing is worth
+// the risk of failing to activate, the Company ends its part of the turn.
+func (heuristic Heuristic) Activate(engine *Engine) (groupId string, order Order, err error) {
+	best := heuristicThreshold
+	for _, group := range engine.ActivatableGroups() {
+		options, err := engine.ValidActivations(group.Id)
+		if err != nil {
+			return "", "", err
+		}
+		for _, option := range options {
+			value := activationChance(option.Target) * heuristic.orderValue(engine, group, option, options)
+			if value > best {
+				best, groupId, order = value, group.Id, option.Order
+			}
+		}
+	}
+	return groupId, order, nil
+}
+
+func (heuristic Heuristic) orderValue(engine *Engine, group *data.Group, option ActivationOption, options ActivationOptions) float64 {
+	switch option.Order {
+	case OrderRally:
+		return groupValue(group)
+	case OrderAttack, OrderShoot:
+		_, value := heuristic.bestTarget(engine, group, option.Order, option.Targets)
+		return value
+	case OrderCast:
+		if _, target := heuristic.bestSpell(engine, group, option.Targets); target != "" {
+			return 0.5 * groupValue(group)
+		}
+		return 0
+	case OrderMove:
+		// Moving is worth little to a Group which can already fight, but much to one with nothing in reach
+		for _, other := range options {
+			if (other.Order == OrderAttack || other.Order == OrderShoot) && len(other.Targets) > 0 {
+				return 0.1
+			}
+		}
+		if _, distance := heuristic.destination(engine, group); distance > 0 {
+			return 0.5
+		}
+		return 0
+	}
+	return 0
+}
+
+// Move heads for the nearest objective the Company does not hold or, if there are none, the nearest enemy, going as
+// far as the Group can along a straight line to it.
+func (heuristic Heuristic) Move(engine *Engine, groupId string) (Location, error) {
+	group, err := engine.Group(groupId)
+	if err != nil {
+		return Location{}, err
+	}
+	groupState, err := engine.GroupState(groupId)
+	if err != nil || groupState.Location == nil {
+		return Location{}, fmt.Errorf("unable to move group '%s': it has not been placed", group.Name)
+	}
+	goal, distance := heuristic.destination(engine, group)
+	if distance <= 0 {
+		return *groupState.Location, nil
+	}
+	treatments, err := engine.terrainTreatments(group)
+	if err != nil {
+		return Location{}, err
+	}
+
+	start := *groupState.Location
+	reach := math.Min(float64(group.Move.Distance), distance)
+	for ; reach > 0; reach-- {
+		fraction := reach / distance
+		end := Location{
+			X: start.X + int(math.Round(float64(goal.X-start.X)*fraction)),
+			Y: start.Y + int(math.Round(float64(goal.Y-start.Y)*fraction)),
+		}
+		cost, err := engine.movementCost(start, end, treatments)
+		if err == nil && cost <= float64(group.Move.Distance) {
+			return end, nil
+		}
+	}
+	return start, nil
+}
+
+// destination returns where a Group wants to be and how far it is from there, stopping just short of enemies so it
+// does not end up on top of them.
+func (heuristic Heuristic) destination(engine *Engine, group *data.Group) (goal Location, distance float64) {
+	groupState, err := engine.GroupState(group.Id)
+	if err != nil || groupState.Location == nil {
+		return goal, 0
+	}
+	start := *groupState.Location
+	distance = math.Inf(1)
+	consider := func(location Location, keepAway float64) {
+		length := math.Hypot(float64(location.X-start.X), float64(location.Y-start.Y)) - keepAway
+		if length > 0 && length < distance {
+			fraction := length / (length + keepAway)
+			goal = Location{
+				X: start.X + int(math.Round(float64(location.X-start.X)*fraction)),
+				Y: start.Y + int(math.Round(float64(location.Y-start.Y)*fraction)),
+			}
+			distance = length
+		}
+	}
+
+	companyName := engine.CompanyOf(group.Id)
+	if engine.Scenario != nil {
+		held := engine.ObjectivesHeld(companyName)
+		for _, objective := range engine.Scenario.Objectives {
+			if !containsObjective(held, objective) {
+				consider(Location{X: objective.X, Y: objective.Y}, 0)
+			}
+		}
+	}
+	if math.IsInf(distance, 1) {
+		for _, enemyId := range engine.enemyIds(group) {
+			if enemyState, err := engine.GroupState(enemyId); err == nil && enemyState.Location != nil {
+				consider(*enemyState.Location, MeleeRange)
+			}
+		}
+	}
+	if math.IsInf(distance, 1) {
+		return start, 0
+	}
+	return goal, distance
+}
+
+func containsObjective(objectives []data.Objective, objective data.Objective) bool {
+	for _, candidate := range objectives {
+		if candidate.Name == objective.Name && candidate.X == objective.X && candidate.Y == objective.Y {
+			return true
+		}
+	}
+	return false
+}
+
+// Target picks the target the Group expects to do the most damage to for the least in return.
+func (heuristic Heuristic) Target(engine *Engine, groupId string, order Order, targets []string) (string, error) {
+	group, err := engine.Group(groupId)
+	if err != nil {
+		return "", err
+	}
+	target, _ := heuristic.bestTarget(engine, group, order, targets)
+	if target == "" {
+		return "", fmt.Errorf("group '%s' has nothing to %s", group.Name, strings.ToLower(string(order)))
+	}
+	return target, nil
+}
+
+// bestTarget values attacking or shooting each target by the points of Fighting Strength it is expected to lose, and
+// the chance it becomes Shaken, less what the Group is expected to lose to it in return.
+func (heuristic Heuristic) bestTarget(engine *Engine, group *data.Group, order Order, targets []string) (best string, bestValue float64) {
+	bestValue = math.Inf(-1)
+	for _, targetId := range targets {
+		target, err := engine.Group(targetId)
+		if err != nil {
+			continue
+		}
+		toHit := group.Melee.ToHitAttacking
+		if order == OrderShoot {
+			missile, err := engine.missileFor(group, target, "")
+			if err != nil {
+				continue
+			}
+			toHit = missile.ToHit
+		}
+		inflicted := expectedLosses(group.FightingStrength.Current, toHit, target)
+		value := inflicted * fightingStrengthValue(target)
+		if inflicted > 0 {
+			value += resolveFailureChance(engine, target) * groupValue(target) / 2
+		}
+		if order == OrderAttack {
+			received := expectedLosses(target.FightingStrength.Current, target.Melee.ToHitDefending, group)
+			value -= received * fightingStrengthValue(group)
+		}
+		if value > bestValue {
+			best, bestValue = targetId, value
+		}
+	}
+	if best == "" {
+		return "", 0
+	}
+	return best, bestValue
+}
+
+// Cast picks the easiest spell to cast which can reach one of the targets.
+func (heuristic Heuristic) Cast(engine *Engine, groupId string, targets []string) (string, string, error) {
+	group, err := engine.Group(groupId)
+	if err != nil {
+		return "", "", err
+	}
+	spell, target := heuristic.bestSpell(engine, group, targets)
+	if target == "" {
+		return "", "", fmt.Errorf("group '%s' has no spell it can cast at any of its targets", group.Name)
+	}
+	return spell.Name, target, nil
+}
+
+// Spells which can target enemies are cast at the most valuable enemy; the rest are cast on the friendly Group which
+// has lost the most Fighting Strength.
+func (heuristic Heuristic) bestSpell(engine *Engine, caster *data.Group, targets []string) (best data.Spell, target string) {
+	casterCompany := engine.CompanyOf(caster.Id)
+	for _, spell := range engine.SpellList(caster.Id) {
+		if target != "" && spell.Check >= best.Check {
+			continue
+		}
+		bestValue := math.Inf(-1)
+		spellTarget := ""
+		for _, id := range engine.spellTargets(caster, spell) {
+			candidate, err := engine.Group(id)
+			if err != nil || !utils.Contains(targets, id) {
+				continue
+			}
+			value := float64(candidate.FightingStrength.Maximum - candidate.FightingStrength.Current)
+			if engine.CompanyOf(id) != casterCompany {
+				value = 100 + groupValue(candidate)
+			}
+			if value > bestValue {
+				bestValue, spellTarget = value, id
+			}
+		}
+		if spellTarget != "" {
+			best, target = spell, spellTarget
+		}
+	}
+	return best, target
+}
+
+// Answer accepts every confirmation, since traits ask before doing something that helps their Group, and picks the most
+// valuable enemy or most battered friend when asked to choose a Group. Otherwise, it gives the Prompt's default answer.
+func (heuristic Heuristic) Answer(engine *Engine, question Question) (any, error) {
+	var prompt data.Prompt
+	for _, candidate := range engine.Prompts {
+		if candidate.Name == question.Prompt {
+			prompt = candidate
+		}
+	}
+
+	var choices []any
+	for _, option := range question.Info.Options {
+		if option.EnumType() != dynamic.SelectionChoiceSimple {
+			continue
+		}
+		if values, ok := option.Value.([]any); ok {
+			choices = append(choices, values...)
+		}
+	}
+
+	switch question.Info.EnumType() {
+	case dynamic.Confirmation:
+		return true, nil
+	case dynamic.Selection:
+		friendly := strings.EqualFold(prompt.Choices, data.PromptChoicesFriends)
+		enemies := strings.EqualFold(prompt.Choices, data.PromptChoicesEnemies)
+		if friendly || enemies {
+			var best any
+			bestValue := math.Inf(-1)
+			for _, choice := range choices {
+				group := heuristic.groupNamed(engine, fmt.Sprint(choice), question.Company, friendly)
+				if group == nil {
+					continue
+				}
+				value := groupValue(group)
+				if friendly {
+					value = float64(group.FightingStrength.Maximum - group.FightingStrength.Current)
+				}
+				if value > bestValue {
+					best, bestValue = choice, value
+				}
+			}
+			if best != nil {
+				return best, nil
+			}
+		}
+	}
+	return defaultAnswer(prompt, question.Asked, choices), nil
+}
+
+func (heuristic Heuristic) groupNamed(engine *Engine, name string, companyName string, friendly bool) *data.Group {
+	for _, company := range engine.Skirmish.Companies {
+		if (company.Name == companyName) != friendly {
+			continue
+		}
+		for _, group := range engine.GroupsInPlay(company.Name) {
+			if group.Name == name {
+				return group
+			}
+		}
+	}
+	return nil
+}
+
+// activationChance is the chance of rolling at least the target on 2d6.
+func activationChance(target int) float64 {
+	ways := 0
+	for first := 1; first <= 6; first++ {
+		for second := 1; second <= 6; second++ {
+			if first+second >= target {
+				ways++
+			}
+		}
+	}
+	return float64(ways) / 36
+}
+
+// hitChance is the chance of a die hitting; a six always hits and a one always misses.
+func hitChance(toHit int) float64 {
+	faces := 0
+	for face := 2; face <= 6; face++ {
+		if face == 6 || face >= toHit {
+			faces++
+		}
+	}
+	return float64(faces) / 6
+}
+
+// expectedLosses is how much Fighting Strength the target is expected to lose to the dice, accounting for its
+// Toughness and any override rounding its received hits up.
+func expectedLosses(dice int, toHit int, target *data.Group) float64 {
+	toughness := float64(target.Toughness)
+	if toughness < 1 {
+		toughness = 1
+	}
+	hits := float64(dice) * hitChance(toHit)
+	losses := hits / toughness
+	if rounding, ok := override(target, "RoundReceivedHits"); ok && strings.EqualFold(fmt.Sprint(rounding), "Up") {
+		losses = math.Ceil(hits / toughness)
+	}
+	return math.Min(losses, float64(target.FightingStrength.Current))
+}
+
+// resolveFailureChance is the chance the target fails a Resolve test, with its Captain's bonus.
+func resolveFailureChance(engine *Engine, target *data.Group) float64 {
+	return 1 - activationChance(target.Resolve-engine.captainResolveBonus(target))
+}
+
+// A Group is worth its points, which include its traits, or at least one point for each of its Fighting Strength.
+func groupValue(group *data.Group) float64 {
+	return math.Max(float64(group.Points), float64(group.FightingStrength.Maximum))
+}
+
+func fightingStrengthValue(group *data.Group) float64 {
+	if group.FightingStrength.Maximum <= 0 {
+		return groupValue(group)
+	}
+	return groupValue(group) / float64(group.FightingStrength.Maximum)
+}
@@ -0,0 +1,379 @@
+package skirmish
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/dice"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/utils"
+	"github.com/d5/tengo/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// A Strike is one side's roll to hit in a fight: the dice rolled, the number each die needed to hit, the number of
+// hits scored after any traits were applied, and the Fighting Strength the opposing Group lost to those hits.
+type Strike struct {
+	Dice   int
+	ToHit  int
+	Rolls  []int
+	Hits   int
+	Losses int
+}
+
+// Attack resolves the melee for the active Group, which must have activated to Attack, against the target Group. Both
+// Groups roll a die for each point of their current Fighting Strength; the attacker needs to roll its to-hit when
+// attacking and the target its to-hit when defending. Every full multiple of a Group's Toughness in hits it receives
+// reduces its Fighting Strength by one and a Group reduced to nothing is destroyed. Once resolved, the activation is
+// finished.
+func (engine *Engine) Attack(targetId string) (result Result, err error) {
+	errorPrefix := "unable to attack"
+	done := engine.recording(Update{Type: UpdateAttack, Actor: engine.Skirmish.ActiveGroup, Target: targetId})
+	defer func() { done(result, err) }()
+	if engine.Skirmish.ActiveGroup == "" || engine.Skirmish.ActiveOrder != OrderAttack {
+		return result, fmt.Errorf("%s: no group has activated to attack", errorPrefix)
+	}
+	attacker, err := engine.Group(engine.Skirmish.ActiveGroup)
+	if err != nil {
+		return result, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	target, err := engine.Group(targetId)
+	if err != nil {
+		return result, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	errorPrefix = fmt.Sprintf("unable to resolve attack by group '%s' on group '%s'", attacker.Name, target.Name)
+	if targetState, _ := engine.GroupState(targetId); targetState == nil || !targetState.InPlay() {
+		return result, fmt.Errorf("%s: the target is not in play", errorPrefix)
+	}
+	if engine.CompanyOf(targetId) == engine.CompanyOf(attacker.Id) {
+		return result, fmt.Errorf("%s: groups cannot attack their own company", errorPrefix)
+	}
+	if !utils.Contains(engine.Skirmish.ActiveTargets, targetId) {
+		return result, fmt.Errorf("%s: the target is not a valid target for this activation", errorPrefix)
+	}
+
+	targeted := &Event{
+		Name:     TargetedByAttack,
+		Actor:    attacker,
+		Target:   target,
+		Subjects: []*data.Group{target},
+		Result:   map[string]any{"both_attacking": false},
+	}
+	if _, err := engine.Events.Dispatch(targeted); err != nil {
+		return result, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	if targeted.Result["shoot_first"] == true {
+		if err := engine.reactionShot(target, attacker); err != nil {
+			return result, fmt.Errorf("%s: %s", errorPrefix, err)
+		}
+		if attackerState, _ := engine.GroupState(attacker.Id); attackerState == nil || !attackerState.InPlay() {
+			engine.FinishActivation()
+			return result, nil
+		}
+	}
+	engine.moveIntoContact(attacker, target)
+
+	inflicted, err := engine.strike(attacker, target, attacker.Melee.ToHitAttacking, ResolvingAttack)
+	if err != nil {
+		return result, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+
+	// Normally the target only defends, but some traits let it meet the attacker as an attacker itself
+	defendingToHit := target.Melee.ToHitDefending
+	if targeted.Result["both_attacking"] == true {
+		defendingToHit = target.Melee.ToHitAttacking
+	}
+	received, err := engine.strike(target, attacker, defendingToHit, "")
+	if err != nil {
+		return result, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+
+	// Losses are applied simultaneously, after both sides have rolled
+	inflicted.Losses = engine.inflictLosses(target, inflicted.Hits)
+	received.Losses = engine.inflictLosses(attacker, received.Hits)
+	log.Trace().Msgf(
+		"group '%s' attacked group '%s': inflicted %d hits (%d losses), received %d hits (%d losses)",
+		attacker.Name, target.Name, inflicted.Hits, inflicted.Losses, received.Hits, received.Losses,
+	)
+
+	result.TargetResolve, err = engine.resolveLosses(target, attacker, inflicted.Losses)
+	if err != nil {
+		return result, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	result.ActorResolve, err = engine.resolveLosses(attacker, target, received.Losses)
+	if err != nil {
+		return result, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+
+	result.InflictRolls = inflicted.Rolls
+	result.InflictHits = inflicted.Hits
+	result.InflictLosses = inflicted.Losses
+	result.ReceiveRolls = received.Rolls
+	result.ReceiveHits = received.Hits
+	result.ReceiveLosses = received.Losses
+
+	engine.FinishActivation()
+
+	return result, nil
+}
+
+// reactionShot has a Group Attacked by an enemy shoot at it before the melee, if any of its missile profiles reach. Moving
+// away first gains the Group nothing, as the attacker still moves into contact.
+func (engine *Engine) reactionShot(shooter *data.Group, target *data.Group) error {
+	missile, err := engine.missileFor(shooter, target, "")
+	if err != nil {
+		log.Trace().Msgf("group '%s' cannot shoot at group '%s' before the melee: %s", shooter.Name, target.Name, err)
+		return nil
+	}
+	inflicted, err := engine.strike(shooter, target, missile.ToHit, ResolvingShoot)
+	if err != nil {
+		return err
+	}
+	inflicted.Losses = engine.inflictLosses(target, inflicted.Hits)
+	log.Trace().Msgf(
+		"group '%s' shot at group '%s' with %s before the melee: inflicted %d hits (%d losses)",
+		shooter.Name, target.Name, missile.String(), inflicted.Hits, inflicted.Losses,
+	)
+	_, err = engine.resolveLosses(target, shooter, inflicted.Losses)
+	return err
+}
+
+// The strike is resolved in steps so traits can modify it: the resolving event (if any) may change the number of dice
+// rolled, CalculatingToHit may change the dice or the number needed to hit, RolledToHit may reroll dice, and
+// CountingInflictedHits may change how many hits the rolls scored.
+func (engine *Engine) strike(actor *data.Group, target *data.Group, toHit int, resolving EventName) (strike Strike, err error) {
+	strike.Dice = actor.FightingStrength.Current
+	strike.ToHit = toHit
+
+	if resolving != "" {
+		event := &Event{Name: resolving, Actor: actor, Target: target, Result: strike.eventResult()}
+		effects, err := engine.Events.Dispatch(event)
+		if err != nil {
+			return strike, err
+		}
+		strike.applyDieCountEffects(effects)
+	}
+
+	calculating := &Event{Name: CalculatingToHit, Actor: actor, Target: target, Result: strike.eventResult()}
+	effects, err := engine.Events.Dispatch(calculating)
+	if err != nil {
+		return strike, err
+	}
+	strike.Dice = intValue(calculating.Result["dice"], strike.Dice)
+	strike.ToHit = intValue(calculating.Result["to_hit"], strike.ToHit)
+	strike.applyDieCountEffects(effects)
+
+	if strike.Dice < 0 {
+		strike.Dice = 0
+	}
+	strike.Rolls = engine.Dice.Roll(fmt.Sprintf("'%s' rolling to hit '%s'", actor.Name, target.Name), strike.Dice, 6)
+	strike.Hits = len(strike.hitRolls())
+
+	rolled := &Event{Name: RolledToHit, Actor: actor, Target: target, Result: strike.eventResult()}
+	effects, err = engine.Events.Dispatch(rolled)
+	if err != nil {
+		return strike, err
+	}
+	for _, effect := range effects.Named("Reroll") {
+		source := fmt.Sprintf("'%s' rerolling to hit '%s' (%s)", actor.Name, target.Name, effect.Trait)
+		strike.Rolls = engine.reroll(source, strike.Rolls, strike.ToHit, stringFrom(effect.Arguments, 0), intFrom(effect.Arguments, 1, 0))
+	}
+
+	strike.Hits = len(strike.hitRolls())
+	counting := &Event{Name: CountingInflictedHits, Actor: actor, Target: target, Result: strike.eventResult()}
+	effects, err = engine.Events.Dispatch(counting)
+	if err != nil {
+		return strike, err
+	}
+	for _, effect := range effects.Named("ImproveHitCount") {
+		strike.Hits += intFrom(effect.Arguments, 0, 0)
+	}
+	for _, effect := range effects.Named("DegradeHitCount") {
+		strike.Hits -= intFrom(effect.Arguments, 0, 0)
+	}
+	if strike.Hits < 0 {
+		strike.Hits = 0
+	}
+
+	return strike, nil
+}
+
+func (strike Strike) eventResult() map[string]any {
+	eventResult := map[string]any{
+		"dice":   strike.Dice,
+		"to_hit": strike.ToHit,
+	}
+	if strike.Rolls != nil {
+		eventResult["rolls"] = strike.Rolls
+		eventResult["hits"] = strike.hitRolls()
+		eventResult["hit_count"] = strike.Hits
+	}
+	return eventResult
+}
+
+func (strike *Strike) applyDieCountEffects(effects Effects) {
+	for _, effect := range effects.Named("DegradeDieCount") {
+		if isToHitDice(stringFrom(effect.Arguments, 0)) {
+			strike.Dice -= intFrom(effect.Arguments, 1, 1)
+		}
+	}
+	for _, effect := range effects.Named("ImproveDieCount") {
+		if isToHitDice(stringFrom(effect.Arguments, 0)) {
+			strike.Dice += intFrom(effect.Arguments, 1, 1)
+		}
+	}
+}
+
+func isToHitDice(kind string) bool {
+	return kind == "" || strings.EqualFold(kind, "ToHit")
+}
+
+// A six always hits and a one always misses
+func (strike Strike) hitRolls() (hits []int) {
+	hits = []int{}
+	for _, roll := range strike.Rolls {
+		if roll != 1 && (roll == 6 || roll >= strike.ToHit) {
+			hits = append(hits, roll)
+		}
+	}
+	return hits
+}
+
+// Dice can be rerolled selectively: "misses" rerolls every die which missed, "ones" every 1, "highest" the single
+// highest missing die, and "all" every die. A limit above zero rerolls no more than that many of the selected dice.
+func (engine *Engine) reroll(source string, rolls []int, toHit int, selection string, limit int) []int {
+	misses := func(roll int) bool {
+		return roll == 1 || (roll != 6 && roll < toHit)
+	}
+	var indexes []int
+	switch strings.ToLower(selection) {
+	case "misses", "":
+		for index, roll := range rolls {
+			if misses(roll) {
+				indexes = append(indexes, index)
+			}
+		}
+	case "ones":
+		for index, roll := range rolls {
+			if roll == 1 {
+				indexes = append(indexes, index)
+			}
+		}
+	case dice.SelectHighest:
+		indexes, _ = dice.Select(rolls, dice.SelectHighest, misses)
+	case dice.SelectAll:
+		indexes, _ = dice.Select(rolls, dice.SelectAll, nil)
+	default:
+		log.Warn().Msgf("unknown reroll selection '%s'; must be one of: misses, ones, highest, all", selection)
+	}
+	if limit > 0 && len(indexes) > limit {
+		indexes = indexes[:limit]
+	}
+	return engine.Dice.Reroll(source, rolls, 6, indexes...)
+}
+
+// inflictLosses reduces the Fighting Strength of a Group by the losses the hits it received inflict and destroys it if it
+// has none left.
+func (engine *Engine) inflictLosses(group *data.Group, hits int) (losses int) {
+	return engine.loseStrength(group, lossesFrom(hits, group))
+}
+
+// loseStrength takes Fighting Strength from the Group directly, regardless of its Toughness, and removes it from play
+// as destroyed if it has none left.
+func (engine *Engine) loseStrength(group *data.Group, losses int) int {
+	if losses > group.FightingStrength.Current {
+		losses = group.FightingStrength.Current
+	}
+	group.FightingStrength.Current -= losses
+	if losses > 0 {
+		engine.notifyState(group.Id, "'%s' lost %d FS", group.Name, losses)
+	}
+	if group.FightingStrength.Current <= 0 {
+		log.Trace().Msgf("group '%s' has been destroyed", group.Name)
+		engine.RemoveGroup(group.Id, Destroyed)
+	}
+	return losses
+}
+
+// Overrides are stored in a Group's addenda by the trait scripts that add them
+func override(group *data.Group, key string) (value any, ok bool) {
+	switch overrides := group.Addenda["override"].(type) {
+	case map[string]any:
+		value, ok = overrides[key]
+	case map[any]any:
+		value, ok = overrides[key]
+	}
+	return value, ok
+}
+
+// Lists of numbers which have been through a script come back as []any
+func intsFrom(value any) (values []int) {
+	switch list := value.(type) {
+	case []int:
+		values = append(values, list...)
+	case []any:
+		for _, item := range list {
+			values = append(values, intValue(item, 0))
+		}
+	}
+	return values
+}
+
+func (bus *Bus) addMeleeNatives() {
+	bus.AddEffect("Hits", "DegradeDieCount")
+	bus.AddEffect("Hits", "ImproveDieCount")
+	bus.AddEffect("Hits", "DegradeHitCount")
+	bus.AddEffect("Hits", "ImproveHitCount")
+	bus.AddEffect("Hits", "Reroll")
+	// MultiplyHitsOf returns the extra hits scored when every hitting die showing the face counts as that many hits
+	bus.AddNative("Hits", "MultiplyHitsOf", func(dispatch *Dispatch, arguments ...tengo.Object) (tengo.Object, error) {
+		if len(arguments) < 3 {
+			return nil, tengo.ErrWrongNumArguments
+		}
+		face := intValue(tengo.ToInterface(arguments[1]), 0)
+		multiplier := intValue(tengo.ToInterface(arguments[2]), 1)
+		extra := 0
+		for _, hit := range intsFrom(tengo.ToInterface(arguments[0])) {
+			if hit == face {
+				extra += multiplier - 1
+			}
+		}
+		return &tengo.Int{Value: int64(extra)}, nil
+	})
+	bus.AddNative("Hits", "RolledAny", func(dispatch *Dispatch, arguments ...tengo.Object) (tengo.Object, error) {
+		if len(arguments) < 1 {
+			return nil, tengo.ErrWrongNumArguments
+		}
+		face := intValue(tengo.ToInterface(arguments[0]), 0)
+		for _, roll := range intsFrom(dispatch.Event.Result["rolls"]) {
+			if roll == face {
+				return tengo.TrueValue, nil
+			}
+		}
+		return tengo.FalseValue, nil
+	})
+	bus.AddNative("Play", "MissedAny", func(dispatch *Dispatch, arguments ...tengo.Object) (tengo.Object, error) {
+		rolls := intsFrom(dispatch.Event.Result["rolls"])
+		return tengoBool(len(intsFrom(dispatch.Event.Result["hits"])) < len(rolls)), nil
+	})
+	// IsBaneOf checks whether the first Group is of a kind the second Group is the bane of
+	bus.AddNative("Traits", "IsBaneOf", func(dispatch *Dispatch, arguments ...tengo.Object) (tengo.Object, error) {
+		victim, err := bus.groupArgument("IsBaneOf", arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+		bane, err := bus.groupArgument("IsBaneOf", arguments, 1)
+		if err != nil {
+			return nil, err
+		}
+		for _, kind := range addendaList(bane, "bane_of") {
+			if isKind(victim, kind) {
+				return tengo.TrueValue, nil
+			}
+		}
+		return tengo.FalseValue, nil
+	})
+
+	bus.AddShorthand("degradeDieCount", "core.Hits.DegradeDieCount")
+	bus.AddShorthand("improveDieCount", "core.Hits.ImproveDieCount")
+}
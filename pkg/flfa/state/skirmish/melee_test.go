@@ -0,0 +1,15 @@
+package skirmish
+
+import "testing"
+
+func TestRerollLimit(t *testing.T) {
+	engine := twoCompanySkirmish()
+	rolls := []int{1, 2, 3, 5, 6}
+
+	rerolled := engine.reroll("test", rolls, 4, "misses", 2)
+	for index, roll := range rerolled[2:] {
+		if roll != rolls[index+2] {
+			t.Errorf("expected only the first two misses to be rerolled, but die %d changed from %d to %d", index+2, rolls[index+2], roll)
+		}
+	}
+}
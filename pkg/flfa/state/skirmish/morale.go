@@ -0,0 +1,276 @@
+package skirmish
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/d5/tengo/v2"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Unless overridden, Groups testing Resolve within range of their Captain's Group add this bonus to their roll
+	DefaultCaptainResolveBonus = 1
+	CaptainResolveRange        = 12
+)
+
+// TestResolve has a Group test its Resolve, usually because of the cause Group, which may be empty. The Group rolls
+// 2d6, adding its Captain's bonus and subtracting one if it has lost half or more of its Fighting Strength, and passes
+// if the total is at least its Resolve. A steady Group which fails becomes Shaken; a Shaken Group which fails routs and
+// is removed from play.
+func (engine *Engine) TestResolve(groupId string, causeId string) (testResult TestResult, err error) {
+	errorPrefix := "unable to test resolve"
+	done := engine.recording(Update{Type: UpdateTestResolve, Actor: groupId, Target: causeId})
+	defer func() { done(Result{ActorResolve: string(testResult)}, err) }()
+	group, err := engine.Group(groupId)
+	if err != nil {
+		return testResult, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	var cause *data.Group
+	if causeId != "" {
+		cause, err = engine.Group(causeId)
+		if err != nil {
+			return testResult, fmt.Errorf("%s: %s", errorPrefix, err)
+		}
+	}
+
+	testResult, _, err = engine.testResolve(group, cause)
+	if err != nil {
+		return testResult, fmt.Errorf("%s for group '%s': %s", errorPrefix, group.Name, err)
+	}
+	if testResult == Fail {
+		engine.worsenMorale(group)
+	}
+	return testResult, nil
+}
+
+// Trait scripts registered for ShouldTestResolve may skip the test, which counts as passing it. Trait scripts
+// registered for PassedResolveTest may reroll the dice of a passed test, after which the test is checked again; fear
+// traits of the cause are ignored if the testing Group is unaffected by fear.
+func (engine *Engine) testResolve(group *data.Group, cause *data.Group) (testResult TestResult, rolls []int, err error) {
+	should := &Event{
+		Name:     ShouldTestResolve,
+		Actor:    cause,
+		Target:   group,
+		Subjects: []*data.Group{group},
+		Result:   map[string]any{"test": true},
+	}
+	effects, err := engine.Events.Dispatch(should)
+	if err != nil {
+		return testResult, rolls, err
+	}
+	if len(effects.Named("SkipResolveTest")) > 0 || should.Result["test"] == false {
+		log.Trace().Msgf("group '%s' skipped testing resolve", group.Name)
+		return Pass, rolls, nil
+	}
+
+	target := group.Resolve
+	modifier := engine.captainResolveBonus(group)
+	if group.FightingStrength.Current*2 <= group.FightingStrength.Maximum {
+		modifier--
+	}
+	rolls = engine.Dice.TwoD6(fmt.Sprintf("'%s' testing resolve", group.Name))
+	passed := sum(rolls)+modifier >= target
+
+	if passed {
+		ignored, err := engine.ignoredFearTraits(group, cause)
+		if err != nil {
+			return testResult, rolls, err
+		}
+		event := &Event{
+			Name:     PassedResolveTest,
+			Actor:    cause,
+			Target:   group,
+			Subjects: []*data.Group{group},
+			Result:   map[string]any{"rolls": rolls, "target": target, "modifier": modifier},
+			Ignored:  ignored,
+		}
+		if _, err := engine.Events.Dispatch(event); err != nil {
+			return testResult, rolls, err
+		}
+		if rerolled := intsFrom(event.Result["rolls"]); len(rerolled) == len(rolls) {
+			rolls = rerolled
+		}
+		passed = sum(rolls)+modifier >= target
+	}
+	log.Trace().Msgf("group '%s' rolled %v%+d against %d+ to test resolve", group.Name, rolls, modifier, target)
+
+	if passed {
+		return Pass, rolls, nil
+	}
+	return Fail, rolls, nil
+}
+
+// The bonus comes from the Captain's Group of the testing Group's Company, if it is in play and, when their positions
+// are known, within range. Either the testing Group or the Captain's Group may override the bonus.
+func (engine *Engine) captainResolveBonus(group *data.Group) int {
+	if bonus, ok := override(group, "CaptainResolveBonus"); ok {
+		return intValue(bonus, 0)
+	}
+	for _, captain := range engine.GroupsInPlay(engine.CompanyOf(group.Id)) {
+		if captain.Captain.Name == "" {
+			continue
+		}
+		if bonus, ok := override(captain, "CaptainResolveBonus"); ok {
+			return intValue(bonus, 0)
+		}
+		if distance, known := engine.Distance(group.Id, captain.Id); known && distance > CaptainResolveRange {
+			return 0
+		}
+		return DefaultCaptainResolveBonus
+	}
+	return 0
+}
+
+// ignoredFearTraits returns the fear traits of the cause which do not affect the testing Group, either because it
+// ignores fear effects entirely or because a trait script registered for ResolveTerrifying says to ignore them.
+func (engine *Engine) ignoredFearTraits(group *data.Group, cause *data.Group) (ignored []string, err error) {
+	if cause == nil {
+		return ignored, nil
+	}
+	var fearTraits []string
+	for _, name := range append([]string{cause.Captain.Name}, cause.Traits...) {
+		if name == "" {
+			continue
+		}
+		for _, tag := range data.GetTraitMatchingName(name, engine.Traits).Tags {
+			if strings.EqualFold(tag, "fear") {
+				fearTraits = append(fearTraits, name)
+				break
+			}
+		}
+	}
+	if len(fearTraits) == 0 {
+		return ignored, nil
+	}
+
+	for _, tag := range addendaList(group, "ignore_effect_tags") {
+		if strings.EqualFold(tag, "fear") {
+			return fearTraits, nil
+		}
+	}
+
+	event := &Event{Name: ResolveTerrifying, Actor: cause, Target: group, Subjects: []*data.Group{group}}
+	effects, err := engine.Events.Dispatch(event)
+	if err != nil {
+		return ignored, err
+	}
+	for _, effect := range effects.Named("IgnoreIf") {
+		if len(effect.Arguments) > 0 && effect.Arguments[0] == true {
+			return fearTraits, nil
+		}
+	}
+	return ignored, nil
+}
+
+func (engine *Engine) worsenMorale(group *data.Group) {
+	groupState, err := engine.GroupState(group.Id)
+	if err != nil {
+		return
+	}
+	if groupState.Shaken() {
+		log.Trace().Msgf("group '%s' has routed", group.Name)
+		groupState.Morale = MoraleRouted
+		engine.RemoveGroup(group.Id, Routed)
+		return
+	}
+	log.Trace().Msgf("group '%s' is shaken", group.Name)
+	groupState.Morale = MoraleShaken
+	engine.notifyState(group.Id, "'%s' is shaken", group.Name)
+}
+
+// resolveLosses has a Group which lost Fighting Strength but is still in play test its Resolve, returning the result
+// of the test or an empty string if it did not need to test.
+func (engine *Engine) resolveLosses(group *data.Group, cause *data.Group, losses int) (string, error) {
+	if losses == 0 {
+		return "", nil
+	}
+	if groupState, _ := engine.GroupState(group.Id); groupState == nil || !groupState.InPlay() {
+		return "", nil
+	}
+	testResult, err := engine.TestResolve(group.Id, cause.Id)
+	return string(testResult), err
+}
+
+// A Shaken Group can only be ordered to Rally, which is a Resolve test instead of an activation test. If it passes, the
+// Group is steady again and its activation is finished. If it fails, trait scripts registered for RallyResultFailure
+// may let it pass anyway; otherwise it stays Shaken and the active Company's part of the turn ends.
+func (engine *Engine) rally(group *data.Group) (result Result, err error) {
+	testResult, rolls, err := engine.testResolve(group, nil)
+	if err != nil {
+		return result, err
+	}
+
+	if testResult == Fail {
+		event := &Event{Name: RallyResultFailure, Actor: group, Result: map[string]any{"rolls": rolls, "target": group.Resolve}}
+		effects, err := engine.Events.Dispatch(event)
+		if err != nil {
+			return result, err
+		}
+		for _, effect := range effects.Named("PassRally") {
+			losses := intFrom(effect.Arguments, 0, 0)
+			log.Trace().Msgf("trait '%s' lets group '%s' pass its rally by losing %d FS", effect.Trait, group.Name, losses)
+			engine.loseStrength(group, losses)
+			testResult = Pass
+			break
+		}
+		if event.Result["passed"] == true {
+			testResult = Pass
+		}
+	}
+
+	result.ActivationRolls = rolls
+	result.ActorResolve = string(testResult)
+	groupState, _ := engine.GroupState(group.Id)
+	groupState.Activated = true
+	if testResult == Pass && groupState.InPlay() {
+		log.Trace().Msgf("group '%s' rallied", group.Name)
+		groupState.Morale = MoraleSteady
+		engine.notifyState(group.Id, "'%s' rallied", group.Name)
+		result.Activation = string(Pass)
+		engine.FinishActivation()
+	} else {
+		result.Activation = string(Fail)
+		engine.EndTurn()
+	}
+
+	return result, nil
+}
+
+func (bus *Bus) addMoraleNatives() {
+	bus.AddEffect("Play", "SkipResolveTest")
+	bus.AddEffect("Play", "PassRally")
+	bus.AddEffect("Traits", "IgnoreIf")
+	shaken := func(dispatch *Dispatch, arguments []tengo.Object) (bool, error) {
+		group := dispatch.Group
+		if len(arguments) > 0 {
+			argumentGroup, err := bus.groupArgument("Shaken", arguments, 0)
+			if err != nil {
+				return false, err
+			}
+			group = argumentGroup
+		}
+		groupState, err := bus.engine.GroupState(group.Id)
+		if err != nil {
+			return false, err
+		}
+		return groupState.Shaken(), nil
+	}
+	bus.AddNative("Play", "IsShaken", func(dispatch *Dispatch, arguments ...tengo.Object) (tengo.Object, error) {
+		isShaken, err := shaken(dispatch, arguments)
+		return tengoBool(isShaken), err
+	})
+	bus.AddNative("Play", "NotShaken", func(dispatch *Dispatch, arguments ...tengo.Object) (tengo.Object, error) {
+		isShaken, err := shaken(dispatch, arguments)
+		return tengoBool(!isShaken), err
+	})
+
+	// notShaken is a value rather than a function so it reads naturally as a when condition
+	bus.AddShorthand("notShaken", "core.Play.NotShaken()")
+}
+
+func (engine *Engine) isShaken(groupId string) bool {
+	groupState, err := engine.GroupState(groupId)
+	return err == nil && groupState.Shaken()
+}
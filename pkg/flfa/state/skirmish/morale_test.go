@@ -0,0 +1,35 @@
+package skirmish
+
+import (
+	"testing"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+)
+
+// Losing Fighting Strength to pass a rally is a cost paid directly, not hits reduced by the Group's Toughness.
+func TestPassRallyLosesStrengthRegardlessOfToughness(t *testing.T) {
+	skirmish := twoCompanySkirmish().Skirmish
+	skirmish.GroupStates[0].Morale = MoraleShaken
+	scouts := &skirmish.Companies[0].Groups[0]
+	scouts.Traits = []string{"Ruthless"}
+	scouts.Toughness = 2
+	scouts.Resolve = 13
+	scouts.FightingStrength = data.FightingStrength{Current: 6, Maximum: 6}
+	ruthless := data.Trait{Name: "Ruthless", Scripting: data.TraitScripting{InPlay: []data.TraitScriptingInPlay{{
+		RegisterFor: []string{string(RallyResultFailure)},
+		Then:        []string{`core.Play.PassRally(1)`},
+	}}}}
+	engine := NewEngine(skirmish, WithSeed(1), WithScriptEngine(coreScriptEngine(t)), WithTraits([]data.Trait{ruthless}))
+	engine.Events.RegisterGroups()
+
+	result, err := engine.Activate(scouts.Id, OrderRally)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Activation != string(Pass) {
+		t.Errorf("expected the rally to pass, got %s", result.Activation)
+	}
+	if scouts.FightingStrength.Current != 5 {
+		t.Errorf("expected the group to lose exactly 1 FS, got %d left", scouts.FightingStrength.Current)
+	}
+}
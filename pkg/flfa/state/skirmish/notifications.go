@@ -0,0 +1,113 @@
+package skirmish
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/FlagrantGarden/flfa/pkg/tympan/dice"
+	"github.com/d5/tengo/v2"
+)
+
+// A Notification tells the players something happened during play: a trait triggered, dice were rolled, a Group's
+// state changed, or a trait's script called core.Play.Notify. Every Notification is tagged with the turn it happened
+// on and, when it concerns one, the Group and its Company.
+type Notification struct {
+	Turn    int
+	Kind    NotificationKind
+	Company string
+	Group   string
+	Trait   string
+	Message string
+}
+
+type NotificationKind string
+
+const (
+	NotificationTrait   NotificationKind = "trait"
+	NotificationRoll    NotificationKind = "roll"
+	NotificationState   NotificationKind = "state"
+	NotificationMessage NotificationKind = "message"
+)
+
+// WithNotifier sets a function for the Engine to call with every Notification as it happens during play. It is not
+// called while the Skirmish is being rebuilt, so only new Notifications are sent; every Notification is also kept in
+// the log with the Update it happened during.
+func WithNotifier(notifier func(notification Notification)) Option {
+	return func(engine *Engine) {
+		engine.notifier = notifier
+	}
+}
+
+// Nothing is notified while the Engine is only queried, like a trait triggering when the players look at the orders a
+// Group may be given; it has not happened yet and there is no Update to keep it with.
+func (engine *Engine) notify(notification Notification) {
+	if engine.querying() {
+		return
+	}
+	notification.Turn = engine.Skirmish.Turn
+	if notification.Group != "" && notification.Company == "" {
+		notification.Company = engine.CompanyOf(notification.Group)
+	}
+	engine.notifications = append(engine.notifications, notification)
+	if engine.notifier != nil && !engine.redoing {
+		engine.notifier(notification)
+	}
+}
+
+// notifyState tells the players the state of a Group changed, like it becoming Shaken.
+func (engine *Engine) notifyState(groupId string, format string, arguments ...any) {
+	engine.notify(Notification{Kind: NotificationState, Group: groupId, Message: fmt.Sprintf(format, arguments...)})
+}
+
+// Feed returns every Notification in the log in the order they happened, starting with those from setting up the
+// Skirmish.
+func (engine *Engine) Feed() (feed []Notification) {
+	feed = append(feed, engine.Skirmish.Setup.Notifications...)
+	for _, update := range engine.Skirmish.Updates {
+		feed = append(feed, update.Notifications...)
+	}
+	return feed
+}
+
+// Rolls are tagged with the Group whose trait rolled them, or the Group the command was given to if the engine rolled
+// them itself.
+func (engine *Engine) notifyRoll(roll dice.Roll) {
+	notification := Notification{Kind: NotificationRoll, Group: engine.actor}
+	if engine.Events != nil && engine.Events.current != nil {
+		current := engine.Events.current
+		notification.Group = current.Group.Id
+		notification.Trait = current.Trait
+	}
+	if len(roll.Rerolled) > 0 {
+		notification.Message = fmt.Sprintf("%s: rerolled %v into %v", roll.Source, roll.Previous, roll.Dice)
+	} else {
+		notification.Message = fmt.Sprintf("%s: rolled %dd%d, %v", roll.Source, len(roll.Dice), roll.Size, roll.Dice)
+	}
+	engine.notify(notification)
+}
+
+func (engine *Engine) notifyTrigger(dispatch *Dispatch) {
+	message := fmt.Sprintf("'%s' of '%s' triggered on %s", dispatch.Trait, dispatch.Owner.Name, dispatch.Event.Name)
+	if dispatch.Group.Id != dispatch.Owner.Id {
+		message = fmt.Sprintf("%s for '%s'", message, dispatch.Group.Name)
+	}
+	engine.notify(Notification{Kind: NotificationTrait, Group: dispatch.Group.Id, Trait: dispatch.Trait, Message: message})
+}
+
+func (bus *Bus) addNotifyNatives() {
+	bus.AddNative("Play", "Notify", func(dispatch *Dispatch, arguments ...tengo.Object) (tengo.Object, error) {
+		var parts []string
+		for _, argument := range arguments {
+			if text, ok := tengo.ToString(argument); ok {
+				parts = append(parts, text)
+			}
+		}
+		bus.engine.notify(Notification{
+			Kind:    NotificationMessage,
+			Group:   dispatch.Group.Id,
+			Trait:   dispatch.Trait,
+			Message: strings.Join(parts, " "),
+		})
+		return tengo.UndefinedValue, nil
+	})
+}
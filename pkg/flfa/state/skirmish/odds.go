@@ -0,0 +1,231 @@
+package skirmish
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+)
+
+// A Distribution is the exact chance of each whole-numbered outcome, such as the number of hits scored, indexed by the
+// outcome; the chances sum to one.
+type Distribution []float64
+
+// Expected is the average outcome.
+func (distribution Distribution) Expected() (expected float64) {
+	for outcome, chance := range distribution {
+		expected += float64(outcome) * chance
+	}
+	return expected
+}
+
+// AtLeast is the chance of the outcome being the given number or more.
+func (distribution Distribution) AtLeast(outcome int) (chance float64) {
+	for index := outcome; index < len(distribution); index++ {
+		if index >= 0 {
+			chance += distribution[index]
+		}
+	}
+	return chance
+}
+
+func (distribution Distribution) String() string {
+	outcomes := make([]string, 0, len(distribution))
+	for outcome, chance := range distribution {
+		outcomes = append(outcomes, fmt.Sprintf("%d: %.1f%%", outcome, chance*100))
+	}
+	return strings.Join(outcomes, "  ")
+}
+
+// StrikeOdds are the chances for one side's roll to hit in a fight: how many hits it scores and how much Fighting
+// Strength the opposing Group loses to them.
+type StrikeOdds struct {
+	Dice   int
+	ToHit  int
+	Hits   Distribution
+	Losses Distribution
+}
+
+// ResolveOdds are the chances of the losses from a fight forcing a Group to test its Resolve, and of it failing the
+// test; a Group destroyed by its losses does not test.
+type ResolveOdds struct {
+	Group  string
+	Tested float64
+	Failed float64
+}
+
+// MeleeOdds are the chances for one Group attacking another: activating to Attack, the hits and losses on both sides,
+// and the Resolve tests the losses force.
+type MeleeOdds struct {
+	Activation      float64
+	Inflicted       StrikeOdds
+	Received        StrikeOdds
+	TargetResolve   ResolveOdds
+	AttackerResolve ResolveOdds
+}
+
+// ShootingOdds are the chances for one Group shooting at another with one of its missile profiles; the target does not
+// strike back.
+type ShootingOdds struct {
+	Missile       data.Missile
+	Activation    float64
+	Inflicted     StrikeOdds
+	TargetResolve ResolveOdds
+}
+
+// Odds are the chances for one Group fighting another, in melee and with each of its missile profiles.
+type Odds struct {
+	Attacker string
+	Target   string
+	Melee    MeleeOdds
+	Shooting []ShootingOdds
+}
+
+// CalculateOdds works out the exact chances for the attacker fighting the target, each at their current Fighting
+// Strength. The chances come from the Groups' profiles, so any traits whose on_add scripts changed those or added
+// overrides are included, but traits which act during play, such as rerolls, are not. Both Groups are assumed to be
+// within range of their Captain when testing Resolve.
+func CalculateOdds(attacker data.Group, target data.Group) (odds Odds) {
+	odds.Attacker = attacker.Name
+	odds.Target = target.Name
+
+	if attacker.Melee.Activation > 0 {
+		odds.Melee.Activation = activationChance(attacker.Melee.Activation)
+	}
+	odds.Melee.Inflicted = strikeOdds(attacker.FightingStrength.Current, attacker.Melee.ToHitAttacking, &target)
+	odds.Melee.Received = strikeOdds(target.FightingStrength.Current, target.Melee.ToHitDefending, &attacker)
+	odds.Melee.TargetResolve = resolveOdds(&target, odds.Melee.Inflicted.Losses)
+	odds.Melee.AttackerResolve = resolveOdds(&attacker, odds.Melee.Received.Losses)
+
+	for _, missile := range attacker.MissileProfiles() {
+		shooting := ShootingOdds{
+			Missile:    missile,
+			Activation: activationChance(missile.Activation),
+			Inflicted:  strikeOdds(attacker.FightingStrength.Current, missile.ToHit, &target),
+		}
+		shooting.TargetResolve = resolveOdds(&target, shooting.Inflicted.Losses)
+		odds.Shooting = append(odds.Shooting, shooting)
+	}
+
+	return odds
+}
+
+// strikeOdds gives the chances of each number of hits from rolling the dice, which is binomial, and of the losses
+// those hits inflict on the target for its Toughness.
+func strikeOdds(dice int, toHit int, target *data.Group) (strike StrikeOdds) {
+	if dice < 0 {
+		dice = 0
+	}
+	strike.Dice = dice
+	strike.ToHit = toHit
+
+	chance := 0.0
+	if toHit > 0 {
+		chance = hitChance(toHit)
+	}
+	strike.Hits = make(Distribution, dice+1)
+	for hits := 0; hits <= dice; hits++ {
+		strike.Hits[hits] = binomial(dice, hits) * math.Pow(chance, float64(hits)) * math.Pow(1-chance, float64(dice-hits))
+	}
+
+	strike.Losses = make(Distribution, lossesFrom(dice, target)+1)
+	for hits, chance := range strike.Hits {
+		strike.Losses[lossesFrom(hits, target)] += chance
+	}
+	return strike
+}
+
+// lossesFrom is the Fighting Strength a Group loses to the hits: one for every full multiple of its Toughness, or every
+// partial multiple if its hits are overridden to round up, but never more than it has.
+func lossesFrom(hits int, group *data.Group) (losses int) {
+	toughness := group.Toughness
+	if toughness < 1 {
+		toughness = 1
+	}
+	losses = hits / toughness
+	if rounding, ok := override(group, "RoundReceivedHits"); ok && strings.EqualFold(fmt.Sprint(rounding), "Up") && hits%toughness > 0 {
+		losses++
+	}
+	if losses > group.FightingStrength.Current {
+		losses = group.FightingStrength.Current
+	}
+	return losses
+}
+
+// resolveOdds gives the chances of the losses forcing the Group to test its Resolve and of it failing, with the same
+// modifiers as testResolve: its Captain's bonus, and a penalty once it has lost half or more of its Fighting Strength.
+func resolveOdds(group *data.Group, losses Distribution) (resolve ResolveOdds) {
+	resolve.Group = group.Name
+	bonus := DefaultCaptainResolveBonus
+	if overridden, ok := override(group, "CaptainResolveBonus"); ok {
+		bonus = intValue(overridden, 0)
+	}
+	for lost, chance := range losses {
+		remaining := group.FightingStrength.Current - lost
+		if lost == 0 || remaining <= 0 {
+			continue
+		}
+		modifier := bonus
+		if remaining*2 <= group.FightingStrength.Maximum {
+			modifier--
+		}
+		resolve.Tested += chance
+		resolve.Failed += chance * (1 - activationChance(group.Resolve-modifier))
+	}
+	return resolve
+}
+
+func binomial(n int, k int) float64 {
+	result := 1.0
+	for index := 1; index <= k; index++ {
+		result *= float64(n-k+index) / float64(index)
+	}
+	return result
+}
+
+func (odds Odds) String() string {
+	var output strings.Builder
+
+	output.WriteString(fmt.Sprintf("%s attacking %s\n", odds.Attacker, odds.Target))
+	if odds.Melee.Activation == 0 {
+		output.WriteString(fmt.Sprintf("  %s cannot activate to attack\n", odds.Attacker))
+	} else {
+		output.WriteString(fmt.Sprintf("  Activating:        %.1f%%\n", odds.Melee.Activation*100))
+	}
+	output.WriteString(odds.Melee.Inflicted.describe("Hits inflicted", "Losses inflicted"))
+	output.WriteString(odds.Melee.Received.describe("Hits received", "Losses received"))
+	output.WriteString(odds.Melee.TargetResolve.describe())
+	output.WriteString(odds.Melee.AttackerResolve.describe())
+
+	for _, shooting := range odds.Shooting {
+		name := shooting.Missile.Name
+		if name == "" {
+			name = "missiles"
+		}
+		output.WriteString(fmt.Sprintf("\n%s shooting %s with %s (%s)\n", odds.Attacker, odds.Target, name, shooting.Missile.String()))
+		output.WriteString(fmt.Sprintf("  Activating:        %.1f%%\n", shooting.Activation*100))
+		output.WriteString(shooting.Inflicted.describe("Hits inflicted", "Losses inflicted"))
+		output.WriteString(shooting.TargetResolve.describe())
+	}
+
+	return output.String()
+}
+
+func (strike StrikeOdds) describe(hits string, losses string) string {
+	if strike.ToHit <= 0 {
+		return fmt.Sprintf("  %-18s none; cannot hit\n", hits+":")
+	}
+	return fmt.Sprintf(
+		"  %-18s %.2f expected from %d dice at %d+\n    %s\n  %-18s %.2f expected\n    %s\n",
+		hits+":", strike.Hits.Expected(), strike.Dice, strike.ToHit, strike.Hits,
+		losses+":", strike.Losses.Expected(), strike.Losses,
+	)
+}
+
+func (resolve ResolveOdds) describe() string {
+	return fmt.Sprintf(
+		"  %s tests resolve: %.1f%%, failing %.1f%%\n",
+		resolve.Group, resolve.Tested*100, resolve.Failed*100,
+	)
+}
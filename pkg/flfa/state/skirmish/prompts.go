@@ -0,0 +1,288 @@
+package skirmish
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/prompts/dynamic"
+	"github.com/d5/tengo/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// A Question is a Prompt put to the player controlling a Company while one of its traits' scripts is running. The
+// script waits for the answer before it carries on. When a Prompt asks for more than one answer, each is a separate
+// Question, counting Asked from one up to Times.
+type Question struct {
+	Prompt  string
+	Info    dynamic.Info
+	Company string
+	// The Group the trait's script is running for, which the answer applies to
+	Group string
+	Owner string
+	Trait string
+	Event EventName
+	// The Result is the event's result when the Question was asked, like the dice rolled.
+	Result map[string]any
+	Asked  int
+	Times  int
+}
+
+// An Answer is recorded in the log for every Question asked while giving a command, so that replaying the command
+// gives the same answers without asking again.
+type Answer struct {
+	Prompt string
+	Group  string
+	Value  any
+}
+
+// An Asker puts a Question to the player and waits for their answer: true or false for a confirmation, the value of the
+// chosen choice for a selection, or a string for text input.
+type Asker func(question Question) (answer any, err error)
+
+// WithAsker sets the function the Engine uses to ask the players Questions during play. Without one, every Question
+// gets the Prompt's default answer.
+func WithAsker(asker Asker) Option {
+	return func(engine *Engine) {
+		engine.asker = asker
+	}
+}
+
+func WithPrompts(prompts []data.Prompt) Option {
+	return func(engine *Engine) {
+		engine.Prompts = prompts
+	}
+}
+
+// A prompted records a Prompt answered while a trait script ran; the Prompt's own scripts run once the trait script has
+// finished.
+type prompted struct {
+	Prompt data.Prompt
+	Answer any
+}
+
+func (bus *Bus) addPromptNatives() {
+	bus.AddNative("Play", "Prompt", func(dispatch *Dispatch, arguments ...tengo.Object) (tengo.Object, error) {
+		name, err := stringArgument("Prompt", arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+		answer, err := bus.engine.prompt(dispatch, name)
+		if err != nil {
+			return nil, err
+		}
+		if answer == nil {
+			return tengo.UndefinedValue, nil
+		}
+		return tengo.FromInterface(answer)
+	})
+	bus.AddShorthand("prompt", "core.Play.Prompt")
+}
+
+// prompt asks the named Prompt for the Group the dispatch is running for and returns the answer.
+func (engine *Engine) prompt(dispatch *Dispatch, name string) (answer any, err error) {
+	prompt := data.GetPromptByName(name, engine.Prompts)
+	if prompt.Name == "" {
+		log.Warn().Msgf("trait '%s' of group '%s' asked for prompt '%s', but no module defines it", dispatch.Trait, dispatch.Owner.Name, name)
+		return nil, nil
+	}
+
+	question := Question{
+		Prompt:  prompt.Name,
+		Company: engine.CompanyOf(dispatch.Owner.Id),
+		Group:   dispatch.Group.Id,
+		Owner:   dispatch.Owner.Id,
+		Trait:   dispatch.Trait,
+		Event:   dispatch.Event.Name,
+		Result:  dispatch.Event.Result,
+		Times:   prompt.Times(),
+	}
+	choices := engine.promptChoices(prompt, dispatch.Group)
+
+	var answers []any
+	for asked := 1; asked <= question.Times; asked++ {
+		question.Asked = asked
+		question.Info = promptInfo(prompt.Info, choices)
+		if prompt.Info.EnumType() == dynamic.Selection && len(choices) == 0 {
+			break
+		}
+		value := defaultAnswer(prompt, asked, choices)
+		// Nobody is asked while the Engine is only queried, as there is no command to log the answer for
+		if !engine.querying() {
+			value, err = engine.answer(question, value)
+			if err != nil {
+				return nil, fmt.Errorf("unable to prompt for '%s': %s", prompt.Name, err)
+			}
+		}
+		answers = append(answers, value)
+		choices = withoutChoice(choices, value)
+	}
+
+	if question.Times == 1 && len(answers) == 1 {
+		answer = answers[0]
+	} else {
+		answer = answers
+	}
+	log.Trace().Msgf("trait '%s' of group '%s' prompted for '%s': %v", dispatch.Trait, dispatch.Owner.Name, prompt.Name, answer)
+	dispatch.prompted = append(dispatch.prompted, prompted{Prompt: prompt, Answer: answer})
+	return answer, nil
+}
+
+// answer returns the answer the log gives for the Question when a command is being replayed, or else asks the Agent
+// controlling the Company or the player. If there is nobody to ask, the fallback is the answer.
+func (engine *Engine) answer(question Question, fallback any) (value any, err error) {
+	switch {
+	case len(engine.given) > 0:
+		given := engine.given[0]
+		engine.given = engine.given[1:]
+		value = given.Value
+		if given.Prompt != question.Prompt || given.Group != question.Group {
+			log.Warn().Msgf("expected to replay an answer for prompt '%s' but found one for '%s'; using the default answer", question.Prompt, given.Prompt)
+			value = fallback
+		}
+	case !engine.redoing && engine.controlledByAgent(question.Company):
+		agent, _ := engine.AgentFor(question.Company)
+		value, err = agent.Answer(engine, question)
+		if err != nil {
+			return nil, err
+		}
+	case engine.asker != nil && !engine.redoing:
+		value, err = engine.asker(question)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		value = fallback
+	}
+
+	engine.answers = append(engine.answers, Answer{Prompt: question.Prompt, Group: question.Group, Value: value})
+	return value, nil
+}
+
+// A selection Prompt's choices are those in its options followed by any it names from play: every spell, or the names
+// of the Group's friends or enemies still in play.
+func (engine *Engine) promptChoices(prompt data.Prompt, group *data.Group) (choices []any) {
+	for _, option := range prompt.Info.Options {
+		if option.EnumType() != dynamic.SelectionChoiceSimple {
+			continue
+		}
+		if values, ok := option.Value.([]any); ok {
+			choices = append(choices, values...)
+		} else {
+			choices = append(choices, option.Value)
+		}
+	}
+
+	company := engine.CompanyOf(group.Id)
+	switch strings.ToLower(prompt.Choices) {
+	case "":
+	case data.PromptChoicesSpells:
+		for _, spell := range engine.Spells {
+			choices = append(choices, spell.Name)
+		}
+	case data.PromptChoicesFriends, data.PromptChoicesEnemies:
+		friendly := strings.EqualFold(prompt.Choices, data.PromptChoicesFriends)
+		for _, other := range engine.Skirmish.Companies {
+			if (other.Name == company) != friendly {
+				continue
+			}
+			for _, candidate := range engine.GroupsInPlay(other.Name) {
+				if candidate.Id != group.Id {
+					choices = append(choices, candidate.Name)
+				}
+			}
+		}
+	default:
+		log.Warn().Msgf("unknown choices '%s' for prompt '%s'; must be one of: %s, %s, %s", prompt.Choices, prompt.Name, data.PromptChoicesSpells, data.PromptChoicesFriends, data.PromptChoicesEnemies)
+	}
+	return choices
+}
+
+// promptInfo returns the Prompt's Info with the remaining choices in place of its simple choices.
+func promptInfo(info dynamic.Info, choices []any) dynamic.Info {
+	if info.EnumType() != dynamic.Selection {
+		return info
+	}
+	options := []dynamic.PromptOption{}
+	for _, option := range info.Options {
+		if option.EnumType() != dynamic.SelectionChoiceSimple {
+			options = append(options, option)
+		}
+	}
+	options = append(options, dynamic.PromptOption{Type: "selection_choices_simple", Value: choices})
+	info.Options = options
+	return info
+}
+
+// If the Prompt does not specify a default answer, confirmations are declined, selections take the first choice, and
+// text is left empty. A default list gives the default for each time the Prompt asks in turn.
+func defaultAnswer(prompt data.Prompt, asked int, choices []any) any {
+	if prompt.Default != nil {
+		if defaults, ok := prompt.Default.([]any); ok {
+			if asked <= len(defaults) {
+				return defaults[asked-1]
+			}
+		} else {
+			return prompt.Default
+		}
+	}
+	switch prompt.Info.EnumType() {
+	case dynamic.Confirmation:
+		return false
+	case dynamic.Selection:
+		if len(choices) > 0 {
+			return choices[0]
+		}
+	case dynamic.TextInput:
+		return ""
+	}
+	return nil
+}
+
+func withoutChoice(choices []any, chosen any) (remaining []any) {
+	removed := false
+	for _, choice := range choices {
+		if !removed && fmt.Sprint(choice) == fmt.Sprint(chosen) {
+			removed = true
+			continue
+		}
+		remaining = append(remaining, choice)
+	}
+	return remaining
+}
+
+// PromptVariables returns the names of the variables a Prompt's scripts run with: those of the in-play script which
+// asked for it and the answer.
+func PromptVariables() []string {
+	return append(ScriptVariables(), "answer")
+}
+
+// runPrompted runs the scripts of every Prompt answered while the dispatch's trait script ran, with the answer as
+// `answer`. They may change the event's result and ask for effects just like the trait script.
+func (bus *Bus) runPrompted(dispatch *Dispatch) error {
+	for _, prompted := range dispatch.prompted {
+		if len(prompted.Prompt.Then) == 0 {
+			continue
+		}
+		body := bus.ScriptBody(prompted.Prompt.ScriptBody())
+		compiled, err := bus.engine.scripts.compile(prompted.Prompt.ScriptName(), body, PromptVariables()...)
+		if err != nil {
+			return fmt.Errorf("unable to add script for prompt '%s': %s", prompted.Prompt.Name, err)
+		}
+		script := compiled.Clone()
+		if err := bus.addScriptVariables(script, dispatch); err != nil {
+			return err
+		}
+		if err := script.Set("answer", prompted.Answer); err != nil {
+			return err
+		}
+		if err := script.Run(); err != nil {
+			return fmt.Errorf("unable to resolve prompt '%s': %s", prompted.Prompt.Name, err)
+		}
+		if updatedResult, ok := script.Get("result").Value().(map[string]any); ok {
+			dispatch.Event.Result = updatedResult
+		}
+	}
+	dispatch.prompted = nil
+	return nil
+}
@@ -0,0 +1,94 @@
+package skirmish
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/prompts/dynamic"
+	"gopkg.in/yaml.v3"
+)
+
+// Every Prompt a core trait or spell asks for must be defined by the core module, or it is silently skipped in play.
+func TestCorePromptsAreDefined(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join(coreModulePath, "Prompts.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var prompts struct {
+		Entries []struct{ Name string }
+	}
+	if err := yaml.Unmarshal(content, &prompts); err != nil {
+		t.Fatal(err)
+	}
+	defined := make(map[string]bool)
+	for _, prompt := range prompts.Entries {
+		defined[prompt.Name] = true
+	}
+
+	asked := regexp.MustCompile(`(?i)\bprompt\("([^"]+)"\)`)
+	err = filepath.WalkDir(coreModulePath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || filepath.Ext(path) != ".yaml" {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, match := range asked.FindAllStringSubmatch(string(content), -1) {
+			if !defined[match[1]] {
+				t.Errorf("%s asks for prompt '%s', which Prompts.yaml does not define", filepath.Base(path), match[1])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Asking which orders a Group may be given is only a query, so a prompt its traits ask for gets the default answer
+// without asking anybody or using the trait up; the Group's Activate command asks it and logs the answer.
+func TestPromptsAreOnlyAskedForCommands(t *testing.T) {
+	skirmish := twoCompanySkirmish().Skirmish
+	scouts := &skirmish.Companies[0].Groups[0]
+	scouts.Traits = []string{"Hesitant"}
+	scouts.Move = data.Move{Activation: 5, Distance: 6}
+	hesitant := data.Trait{Name: "Hesitant", Scripting: data.TraitScripting{InPlay: []data.TraitScriptingInPlay{{
+		RegisterFor: []string{string(GetValidActivations)},
+		Uses:        []data.TraitUses{{PerTurn: 1}},
+		Then:        []string{`prompt("Hesitate")`},
+	}}}}
+	hesitate := data.Prompt{Name: "Hesitate", Info: dynamic.Info{Type: "confirmation", Message: "Hesitate?"}}
+	asked := 0
+	asker := func(question Question) (any, error) {
+		asked++
+		return false, nil
+	}
+	engine := NewEngine(skirmish, WithSeed(1), WithScriptEngine(coreScriptEngine(t)), WithTraits([]data.Trait{hesitant}),
+		WithPrompts([]data.Prompt{hesitate}), WithAsker(asker))
+	engine.Events.RegisterGroups()
+
+	for query := 0; query < 2; query++ {
+		if _, err := engine.ValidActivations(scouts.Id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if asked != 0 || len(engine.answers) != 0 {
+		t.Errorf("expected no questions while querying valid activations, got %d asked and %d answers", asked, len(engine.answers))
+	}
+
+	if _, err := engine.Activate(scouts.Id, OrderMove); err != nil {
+		t.Fatal(err)
+	}
+	if asked != 1 {
+		t.Errorf("expected the prompt to be asked once when activating, got %d", asked)
+	}
+	updates := engine.Skirmish.Updates
+	if len(updates) != 1 || len(updates[0].Answers) != 1 || updates[0].Answers[0].Prompt != "Hesitate" {
+		t.Errorf("expected the answer to be logged with the activation, got %+v", updates)
+	}
+}
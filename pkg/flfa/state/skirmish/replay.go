@@ -0,0 +1,214 @@
+package skirmish
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/FlagrantGarden/flfa/pkg/tympan/dice"
+)
+
+// A Step is a single Update from a Skirmish's log as it was replayed, along with every die rolled and every trait
+// which triggered while it was applied. If replaying the Update did not produce the Result saved in the log, the Step
+// has diverged; this only happens when the module data the Skirmish was played with has since changed.
+type Step struct {
+	Number   int
+	Update   Update
+	Rolls    []dice.Roll
+	Triggers []Trigger
+	Diverged bool
+}
+
+// A Trigger is an in-play trait script which ran for an event, applying to the named Group.
+type Trigger struct {
+	Event EventName
+	Trait string
+	Owner string
+	Group string
+}
+
+// A Replay steps through a saved Skirmish one Update at a time. Because every roll comes from the Skirmish's seed,
+// replaying its log from its Setup always produces the same Skirmish it was saved as.
+type Replay struct {
+	Engine *Engine
+	// The Opening holds the rolls and triggers from starting the Skirmish, before any Update was applied.
+	Opening  Step
+	Steps    []Step
+	Position int
+}
+
+// NewReplay replays every Update of the saved Skirmish, recording the Steps, and then returns to the start. The
+// options should give the engine the same traits, spells, and scripts the Skirmish was played with.
+func NewReplay(saved Skirmish, options ...Option) (*Replay, error) {
+	errorPrefix := "unable to replay skirmish"
+	if saved.Seed == 0 {
+		return nil, fmt.Errorf("%s: it has no seed to replay from", errorPrefix)
+	}
+	if len(saved.Setup.Companies) == 0 {
+		return nil, fmt.Errorf("%s: it has not been started", errorPrefix)
+	}
+
+	skirmish := &Skirmish{Seed: saved.Seed}
+	engine := NewEngine(skirmish, options...)
+	// What was saved always wins over the options, so the Skirmish is played out on the same battlefield
+	skirmish.Scenario = saved.Scenario
+	skirmish.Battlefield = saved.Battlefield
+	skirmish.MaximumTurns = saved.MaximumTurns
+	skirmish.Setup = saved.Setup
+
+	replay := &Replay{Engine: engine}
+	engine.step = &replay.Opening
+	err := engine.rebuildFrom(nil)
+	engine.step = nil
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+
+	for index, update := range saved.Updates {
+		step := Step{Number: index + 1}
+		engine.step = &step
+		err := engine.Apply(update)
+		engine.step = nil
+		if err != nil {
+			return nil, fmt.Errorf("%s: unable to replay update %d (%s): %s", errorPrefix, step.Number, update.Type, err)
+		}
+		step.Update = engine.Skirmish.Updates[len(engine.Skirmish.Updates)-1]
+		step.Diverged = fmt.Sprintf("%v", step.Update.Result) != fmt.Sprintf("%v", update.Result)
+		replay.Steps = append(replay.Steps, step)
+	}
+	replay.Position = len(replay.Steps)
+
+	if err := replay.GoTo(0); err != nil {
+		return nil, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	return replay, nil
+}
+
+func (replay *Replay) Skirmish() *Skirmish {
+	return replay.Engine.Skirmish
+}
+
+// Current returns the Step most recently applied, if any.
+func (replay *Replay) Current() *Step {
+	if replay.Position == 0 {
+		return nil
+	}
+	return &replay.Steps[replay.Position-1]
+}
+
+func (replay *Replay) AtStart() bool {
+	return replay.Position == 0
+}
+
+func (replay *Replay) AtEnd() bool {
+	return replay.Position == len(replay.Steps)
+}
+
+// Forward applies the next Step.
+func (replay *Replay) Forward() error {
+	if replay.AtEnd() {
+		return fmt.Errorf("unable to step forward: the replay is at the end of the skirmish")
+	}
+	if err := replay.Engine.Apply(replay.Steps[replay.Position].Update); err != nil {
+		return fmt.Errorf("unable to step forward: %s", err)
+	}
+	replay.Position++
+	return nil
+}
+
+// Back returns to the state before the current Step was applied.
+func (replay *Replay) Back() error {
+	if replay.AtStart() {
+		return fmt.Errorf("unable to step back: the replay is at the start of the skirmish")
+	}
+	return replay.GoTo(replay.Position - 1)
+}
+
+// GoTo rebuilds the Skirmish as it was once the given number of Steps had been applied.
+func (replay *Replay) GoTo(position int) error {
+	if position < 0 || position > len(replay.Steps) {
+		return fmt.Errorf("unable to go to step %d: the replay only has %d steps", position, len(replay.Steps))
+	}
+	var updates []Update
+	for _, step := range replay.Steps[:position] {
+		updates = append(updates, step.Update)
+	}
+	if err := replay.Engine.rebuildFrom(updates); err != nil {
+		return fmt.Errorf("unable to go to step %d: %s", position, err)
+	}
+	replay.Position = position
+	return nil
+}
+
+// JumpToTurn goes to the start of the given turn, before any of its Steps were applied.
+func (replay *Replay) JumpToTurn(turn int) error {
+	for index, step := range replay.Steps {
+		if step.Update.Turn >= turn {
+			return replay.GoTo(index)
+		}
+	}
+	lastTurn := 0
+	if len(replay.Steps) > 0 {
+		lastTurn = replay.Steps[len(replay.Steps)-1].Update.Turn
+	}
+	return fmt.Errorf("unable to jump to turn %d: the skirmish ended on turn %d", turn, lastTurn)
+}
+
+// Describe returns a line of text saying what an Update did, naming the Groups involved.
+func (engine *Engine) Describe(update Update) string {
+	name := func(id string) string {
+		if group, err := engine.Group(id); err == nil {
+			return fmt.Sprintf("'%s'", group.Name)
+		}
+		return "an unknown group"
+	}
+
+	var description string
+	switch update.Type {
+	case UpdateDeploy:
+		description = fmt.Sprintf("deployed %s at %s", name(update.Actor), update.EndLocation)
+	case UpdatePlace:
+		description = fmt.Sprintf("placed %s at %s", name(update.Actor), update.EndLocation)
+	case UpdateActivate:
+		description = fmt.Sprintf("activated %s to %s: %s", name(update.Actor), strings.ToLower(update.Subtype), update.Result.Activation)
+	case UpdateMove:
+		description = fmt.Sprintf("moved %s from %s to %s", name(update.Actor), update.StartLocation, update.EndLocation)
+	case UpdateAttack:
+		description = fmt.Sprintf("%s attacked %s", name(update.Actor), name(update.Target))
+	case UpdateShoot:
+		description = fmt.Sprintf("%s shot at %s", name(update.Actor), name(update.Target))
+	case UpdateCast:
+		description = fmt.Sprintf("%s cast %s on %s", name(update.Actor), update.Subtype, name(update.Target))
+	case UpdateFinish:
+		description = "finished the activation"
+	case UpdateEndTurn:
+		description = "ended their part of the turn"
+	case UpdateTestResolve:
+		description = fmt.Sprintf("%s tested resolve: %s", name(update.Actor), update.Result.ActorResolve)
+	case UpdateAddToSpellList:
+		description = fmt.Sprintf("%s learned %s", name(update.Actor), update.Subtype)
+	case UpdateEndSpells:
+		description = fmt.Sprintf("ended the spells affecting %s", name(update.Actor))
+	case UpdateRemove:
+		description = fmt.Sprintf("removed %s as %s", name(update.Actor), update.Subtype)
+	default:
+		description = string(update.Type)
+	}
+
+	if update.Company == "" {
+		return description
+	}
+	return fmt.Sprintf("%s %s", update.Company, description)
+}
+
+// observeRoll and observeTrigger record what happens while a Replay is applying a Step.
+func (engine *Engine) observeRoll(roll dice.Roll) {
+	if engine.step != nil {
+		engine.step.Rolls = append(engine.step.Rolls, roll)
+	}
+}
+
+func (engine *Engine) observeTrigger(trigger Trigger) {
+	if engine.step != nil {
+		engine.step.Triggers = append(engine.step.Triggers, trigger)
+	}
+}
@@ -0,0 +1,219 @@
+package skirmish
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// WithScenario sets up the Skirmish for a Scenario: its battlefield and terrain, its turn limit, and how it is won.
+func WithScenario(scenario data.Scenario) Option {
+	return func(engine *Engine) {
+		engine.Scenario = &scenario
+		engine.Skirmish.Scenario = scenario.Name
+		if scenario.TurnLimit > 0 {
+			engine.Skirmish.MaximumTurns = scenario.TurnLimit
+		}
+		battlefield := Battlefield{Width: scenario.Width, Height: scenario.Height}
+		for _, area := range scenario.Terrain {
+			terrain, err := ParseTerrain(area.Type)
+			if err != nil {
+				log.Warn().Msgf("unable to add terrain '%s' for scenario '%s': %s", area.Name, scenario.Name, err)
+				continue
+			}
+			battlefield.Terrain = append(battlefield.Terrain, Area{
+				Name:     area.Name,
+				Type:     terrain,
+				Location: Location{X: area.X, Y: area.Y},
+				Width:    area.Width,
+				Height:   area.Height,
+			})
+		}
+		engine.Skirmish.Battlefield = battlefield
+	}
+}
+
+// A Company may only deploy in its side's deployment zones, if the Scenario has any for that side.
+func (engine *Engine) inDeploymentZone(companyName string, location Location) bool {
+	if engine.Scenario == nil {
+		return true
+	}
+	side := data.DeploymentDefenders
+	if utils.Contains(engine.Skirmish.Attackers, companyName) {
+		side = data.DeploymentAttackers
+	}
+	zones := engine.Scenario.DeploymentZonesFor(side)
+	if len(zones) == 0 {
+		return true
+	}
+	for _, zone := range zones {
+		if zone.Contains(location.X, location.Y) {
+			return true
+		}
+	}
+	return false
+}
+
+// ObjectivesHeld returns the Scenario's objectives the named Company holds: it has a Group in play within each of
+// their radiuses and no enemy Group is.
+func (engine *Engine) ObjectivesHeld(companyName string) (held []data.Objective) {
+	if engine.Scenario == nil {
+		return held
+	}
+	for _, objective := range engine.Scenario.Objectives {
+		holders := []string{}
+		for _, company := range engine.Skirmish.Companies {
+			for _, group := range engine.GroupsInPlay(company.Name) {
+				groupState, _ := engine.GroupState(group.Id)
+				if groupState.Location == nil {
+					continue
+				}
+				distance := math.Hypot(float64(groupState.Location.X-objective.X), float64(groupState.Location.Y-objective.Y))
+				if distance <= float64(objective.Radius) && !utils.Contains(holders, company.Name) {
+					holders = append(holders, company.Name)
+				}
+			}
+		}
+		if len(holders) == 1 && holders[0] == companyName {
+			held = append(held, objective)
+		}
+	}
+	return held
+}
+
+// At the end of every turn, each Company scores the points of every objective it holds and then the Scenario's
+// scoring scripts run for it.
+func (engine *Engine) scoreTurn() {
+	if engine.Scenario == nil {
+		return
+	}
+	if engine.Skirmish.Scores == nil {
+		engine.Skirmish.Scores = make(map[string]int)
+	}
+	for _, company := range engine.Skirmish.Companies {
+		held := engine.ObjectivesHeld(company.Name)
+		for _, objective := range held {
+			engine.Skirmish.Scores[company.Name] += objective.Points
+		}
+		if len(engine.Scenario.Scripting.Scoring) > 0 {
+			score, err := engine.runScoringScript(company.Name, held)
+			if err != nil {
+				log.Warn().Msgf("unable to score turn %d for '%s': %s", engine.Skirmish.Turn, company.Name, err)
+				continue
+			}
+			engine.Skirmish.Scores[company.Name] = score
+		}
+	}
+	log.Trace().Msgf("scores at the end of turn %d: %v", engine.Skirmish.Turn, engine.Skirmish.Scores)
+}
+
+// ScoringVariables returns the names of the variables a Scenario's scoring scripts run with.
+func ScoringVariables() []string {
+	return []string{
+		"company", "attacking", "turn", "score", "held",
+		"groups_in_play", "points_in_play", "groups_out_of_play", "enemies_in_play", "enemies_out_of_play",
+	}
+}
+
+// Scoring scripts may read the Company's name, whether it is attacking, the turn, the objectives it holds, and how
+// many Groups and points it and its enemies have in play or have lost; they change the Company's score by setting
+// score.
+func (engine *Engine) runScoringScript(companyName string, held []data.Objective) (score int, err error) {
+	score = engine.Skirmish.Scores[companyName]
+	if engine.ScriptEngine == nil {
+		return score, fmt.Errorf("no script engine to run the scoring scripts with")
+	}
+
+	// Scenarios from different modules may share a name
+	name := fmt.Sprintf("ScenarioScoring: '%s' from '%s'", engine.Scenario.Name, engine.Scenario.Source)
+	compiled, err := engine.scripts.compile(name, engine.Scenario.ScoringScriptBody(), ScoringVariables()...)
+	if err != nil {
+		return score, err
+	}
+	script := compiled.Clone()
+
+	heldNames := make(map[string]any)
+	for _, objective := range held {
+		heldNames[objective.Name] = true
+	}
+	variables := map[string]any{
+		"company":             companyName,
+		"attacking":           utils.Contains(engine.Skirmish.Attackers, companyName),
+		"turn":                engine.Skirmish.Turn,
+		"score":               score,
+		"held":                heldNames,
+		"groups_in_play":      0,
+		"points_in_play":      0,
+		"groups_out_of_play":  0,
+		"enemies_in_play":     0,
+		"enemies_out_of_play": 0,
+	}
+	for _, groupState := range engine.Skirmish.GroupStates {
+		group, err := engine.Group(groupState.Id)
+		if err != nil {
+			continue
+		}
+		switch {
+		case groupState.Company == companyName && groupState.InPlay():
+			variables["groups_in_play"] = variables["groups_in_play"].(int) + 1
+			variables["points_in_play"] = variables["points_in_play"].(int) + group.Points
+		case groupState.Company == companyName:
+			variables["groups_out_of_play"] = variables["groups_out_of_play"].(int) + 1
+		case groupState.InPlay():
+			variables["enemies_in_play"] = variables["enemies_in_play"].(int) + 1
+		default:
+			variables["enemies_out_of_play"] = variables["enemies_out_of_play"].(int) + 1
+		}
+	}
+	for variable, value := range variables {
+		if err := script.Set(variable, value); err != nil {
+			return score, err
+		}
+	}
+
+	if err := script.Run(); err != nil {
+		return score, err
+	}
+	return script.Get("score").Int(), nil
+}
+
+// A Company wins outright once its score reaches that of a score victory condition at the end of a turn, unless
+// another Company has reached the same score.
+func (engine *Engine) scoreVictory(companyNames []string) (winner string, won bool) {
+	if engine.Scenario == nil || engine.Skirmish.Scores == nil {
+		return "", false
+	}
+	for _, condition := range engine.Scenario.VictoryConditions {
+		if condition.Type != data.VictoryByScore || condition.Score <= 0 {
+			continue
+		}
+		leader := engine.leadingCompany(companyNames, func(name string) int { return engine.Skirmish.Scores[name] })
+		if leader != "" && engine.Skirmish.Scores[leader] >= condition.Score {
+			return leader, true
+		}
+	}
+	return "", false
+}
+
+// When the turn limit ends the skirmish, the Scenario decides whether the highest score or the most points still in
+// play wins; without a Scenario, the most points still in play wins.
+func (engine *Engine) turnLimitWinner(companyNames []string) string {
+	if engine.Scenario != nil {
+		for _, condition := range engine.Scenario.VictoryConditions {
+			if condition.Type == data.VictoryByMostScore {
+				return engine.leadingCompany(companyNames, func(name string) int { return engine.Skirmish.Scores[name] })
+			}
+		}
+	}
+	return engine.leadingCompany(companyNames, engine.pointsInPlay)
+}
+
+func (engine *Engine) pointsInPlay(companyName string) (points int) {
+	for _, group := range engine.GroupsInPlay(companyName) {
+		points += group.Points
+	}
+	return points
+}
@@ -0,0 +1,27 @@
+package skirmish
+
+import (
+	"testing"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+)
+
+// A scoring script is compiled once and run for every Company at the end of every turn.
+func TestScoringScriptsRunEveryTurn(t *testing.T) {
+	skirmish := twoCompanySkirmish().Skirmish
+	skirmish.Attackers = []string{"Alpha"}
+	scenario := data.Scenario{Name: "Raid", Source: "core", Scripting: data.ScenarioScripting{Scoring: []string{
+		`if attacking { score += turn }`,
+	}}}
+	engine := NewEngine(skirmish, WithSeed(1), WithScriptEngine(coreScriptEngine(t)), WithScenario(scenario))
+
+	engine.scoreTurn()
+	engine.Skirmish.Turn++
+	engine.scoreTurn()
+	if scores := engine.Skirmish.Scores; scores["Alpha"] != 3 || scores["Beta"] != 0 {
+		t.Errorf("expected the attacker to score each turn's number, got %v", scores)
+	}
+	if len(engine.scripts.compiled) != 1 {
+		t.Errorf("expected the scoring script to be compiled once, got %d compiled scripts", len(engine.scripts.compiled))
+	}
+}
@@ -0,0 +1,80 @@
+package skirmish
+
+import (
+	"github.com/FlagrantGarden/flfa/pkg/tympan/dice"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/module/scripting"
+	"github.com/d5/tengo/v2"
+)
+
+// A ScriptCache keeps the in-play and prompt scripts an Engine has compiled so each is compiled once instead of every
+// time it runs. Engines played one after another, like the runs of a simulation, can share a ScriptCache so the scripts
+// are only compiled once between them. The scripts' natives and dice always belong to the Engine most recently given
+// the ScriptCache, so it must never be shared by Engines playing at the same time. An Engine given none keeps its own.
+type ScriptCache struct {
+	source       *scripting.Engine
+	scriptEngine *scripting.Engine
+	dice         *dice.Roller
+	bus          *Bus
+	nativesBound bool
+	diceBound    bool
+	compiled     map[string]*tengo.Compiled
+}
+
+func NewScriptCache() *ScriptCache {
+	return &ScriptCache{compiled: make(map[string]*tengo.Compiled)}
+}
+
+// WithScriptCache has the Engine share compiled scripts and dice with every other Engine given the same ScriptCache.
+func WithScriptCache(cache *ScriptCache) Option {
+	return func(engine *Engine) {
+		engine.scripts = cache
+	}
+}
+
+// attach gives the Engine the cache's dice, reseeded from the Skirmish's seed, and the cache's copy of the Engine's
+// script engine. The natives and the dice are bound to the copy, so anything else using the original engine never
+// rolls the Skirmish's dice.
+func (cache *ScriptCache) attach(engine *Engine) {
+	if cache.dice == nil {
+		cache.dice = dice.NewRoller(engine.Skirmish.Seed)
+	} else {
+		cache.dice.Reseed(engine.Skirmish.Seed)
+	}
+	engine.Dice = cache.dice
+
+	if engine.ScriptEngine == nil {
+		return
+	}
+	if engine.ScriptEngine != cache.source {
+		cache.source = engine.ScriptEngine
+		cache.scriptEngine = engine.ScriptEngine.Copy()
+		cache.compiled = make(map[string]*tengo.Compiled)
+		cache.nativesBound = false
+		cache.diceBound = false
+	}
+	engine.ScriptEngine = cache.scriptEngine
+}
+
+// compile returns the named script, compiling it from the body the first time, with every variable declared so that
+// each run can set them on its own clone of the script.
+func (cache *ScriptCache) compile(name string, body string, variables ...string) (*tengo.Compiled, error) {
+	if compiled, ok := cache.compiled[name]; ok {
+		return compiled, nil
+	}
+	script := cache.scriptEngine.GetScript(name)
+	if script == nil {
+		if err := cache.scriptEngine.AddScript(name, body); err != nil {
+			return nil, err
+		}
+		script = cache.scriptEngine.GetScript(name)
+	}
+	for _, variable := range variables {
+		script.Add(variable, nil)
+	}
+	compiled, err := script.Compile()
+	if err != nil {
+		return nil, err
+	}
+	cache.compiled[name] = compiled
+	return compiled, nil
+}
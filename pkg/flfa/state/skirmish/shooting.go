@@ -0,0 +1,103 @@
+package skirmish
+
+import (
+	"fmt"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// Shoot resolves shooting for the active Group, which must have activated to Shoot, against the target Group using the
+// named missile profile; if no profile is named, the first one with the target in range is used. The shooting Group
+// rolls a die for each point of its current Fighting Strength, needing the to-hit of the missile profile, and the target
+// loses Fighting Strength for its hits just as it would in melee. The target does not strike back. Once resolved, the
+// activation is finished.
+func (engine *Engine) Shoot(targetId string, profile string) (result Result, err error) {
+	errorPrefix := "unable to shoot"
+	done := engine.recording(Update{Type: UpdateShoot, Subtype: profile, Actor: engine.Skirmish.ActiveGroup, Target: targetId})
+	defer func() { done(result, err) }()
+	if engine.Skirmish.ActiveGroup == "" || engine.Skirmish.ActiveOrder != OrderShoot {
+		return result, fmt.Errorf("%s: no group has activated to shoot", errorPrefix)
+	}
+	shooter, err := engine.Group(engine.Skirmish.ActiveGroup)
+	if err != nil {
+		return result, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	target, err := engine.Group(targetId)
+	if err != nil {
+		return result, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	errorPrefix = fmt.Sprintf("unable to resolve shooting by group '%s' at group '%s'", shooter.Name, target.Name)
+	if targetState, _ := engine.GroupState(targetId); targetState == nil || !targetState.InPlay() {
+		return result, fmt.Errorf("%s: the target is not in play", errorPrefix)
+	}
+	if !utils.Contains(engine.Skirmish.ActiveTargets, targetId) {
+		return result, fmt.Errorf("%s: the target is not a valid target for this activation", errorPrefix)
+	}
+
+	missile, err := engine.missileFor(shooter, target, profile)
+	if err != nil {
+		return result, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+
+	inflicted, err := engine.strike(shooter, target, missile.ToHit, ResolvingShoot)
+	if err != nil {
+		return result, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	inflicted.Losses = engine.inflictLosses(target, inflicted.Hits)
+	log.Trace().Msgf(
+		"group '%s' shot at group '%s' with %s: inflicted %d hits (%d losses)",
+		shooter.Name, target.Name, missile.String(), inflicted.Hits, inflicted.Losses,
+	)
+
+	result.TargetResolve, err = engine.resolveLosses(target, shooter, inflicted.Losses)
+	if err != nil {
+		return result, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+
+	result.InflictRolls = inflicted.Rolls
+	result.InflictHits = inflicted.Hits
+	result.InflictLosses = inflicted.Losses
+
+	engine.FinishActivation()
+
+	return result, nil
+}
+
+// missileFor picks the missile profile a Group shoots at its target with, making sure the target is in range.
+func (engine *Engine) missileFor(shooter *data.Group, target *data.Group, profile string) (missile data.Missile, err error) {
+	distance, known := engine.Distance(shooter.Id, target.Id)
+	if profile != "" {
+		missile, err = shooter.MissileProfile(profile)
+		if err != nil {
+			return missile, err
+		}
+		if known && !missile.InRange(distance) {
+			return missile, fmt.Errorf("the target is %.1f\" away but the range of '%s' is %d\"", distance, profile, missile.Range)
+		}
+		return missile, nil
+	}
+
+	for _, missile := range shooter.MissileProfiles() {
+		if !known || missile.InRange(distance) {
+			return missile, nil
+		}
+	}
+	return missile, fmt.Errorf("the target is %.1f\" away, out of range of every missile profile", distance)
+}
+
+// targetsInRange filters the candidate targets to those within range of at least one of the Group's missile profiles.
+func (engine *Engine) targetsInRange(group *data.Group, candidates []string) (targets []string) {
+	targets = []string{}
+	for _, id := range candidates {
+		distance, known := engine.Distance(group.Id, id)
+		for _, missile := range group.MissileProfiles() {
+			if !known || missile.InRange(distance) {
+				targets = append(targets, id)
+				break
+			}
+		}
+	}
+	return targets
+}
@@ -0,0 +1,226 @@
+package skirmish
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/dice"
+	"github.com/rs/zerolog/log"
+)
+
+// A Matchup sums up many Skirmishes between the same Companies, each played to the end by the heuristic Agent with
+// its own seed: how often each Company won, how long the Skirmishes lasted, what each Group lost, and which traits
+// triggered.
+type Matchup struct {
+	Companies []string
+	Runs      int
+	// The first seed; every run after the first uses the next seed along.
+	Seed  int64
+	Wins  map[string]int
+	Draws int
+	// Runs which the Agents could not finish are not counted toward any result.
+	Unfinished int
+	Turns      int
+	Casualties []*Casualties
+	Triggers   map[string]int
+}
+
+// The Casualties of a Group are the Fighting Strength it lost over every run and how often it left play, by how.
+type Casualties struct {
+	Company string
+	Group   string
+	Lost    int
+	Removed map[GroupStatus]int
+}
+
+type TriggerCount struct {
+	Trait string
+	Count int
+}
+
+// Simulate plays the Companies against each other the given number of times without anyone at the table. If the seed
+// is zero, one is chosen from the current time. The options are given to the Engine for every run, so should set up
+// the traits, spells, scripts, and scenario; the Companies are copied for each run and never changed. The runs are
+// played in parallel, each worker compiling the scripts once for every run it plays; as each run rolls its own dice from
+// the seed plus its index, the Matchup is the same however the runs are shared out.
+func Simulate(companies []data.Company, runs int, seed int64, options ...Option) (*Matchup, error) {
+	if seed == 0 {
+		seed = dice.NewRoller(0).Seed
+	}
+	matchup := &Matchup{
+		Runs:     runs,
+		Seed:     seed,
+		Wins:     make(map[string]int),
+		Triggers: make(map[string]int),
+	}
+	for _, company := range companies {
+		matchup.Companies = append(matchup.Companies, company.Name)
+		for _, group := range company.Groups {
+			matchup.Casualties = append(matchup.Casualties, &Casualties{
+				Company: company.Name,
+				Group:   group.Name,
+				Removed: make(map[GroupStatus]int),
+			})
+		}
+	}
+
+	workers := runtime.GOMAXPROCS(0)
+	if workers > runs {
+		workers = runs
+	}
+	queue := make(chan int, runs)
+	for run := 0; run < runs; run++ {
+		queue <- run
+	}
+	close(queue)
+
+	var (
+		waiting sync.WaitGroup
+		playing sync.Mutex
+		failed  = runs
+		err     error
+	)
+	for worker := 0; worker < workers; worker++ {
+		waiting.Add(1)
+		go func() {
+			defer waiting.Done()
+			scripts := NewScriptCache()
+			for run := range queue {
+				engine, finished, runErr := simulateRun(companies, seed+int64(run), append(append([]Option{}, options...), WithScriptCache(scripts)))
+				playing.Lock()
+				switch {
+				case runErr != nil:
+					if run < failed {
+						failed = run
+						err = fmt.Errorf("unable to simulate run %d: %s", run+1, runErr)
+					}
+				case !finished:
+					matchup.Unfinished++
+				default:
+					matchup.record(engine)
+				}
+				playing.Unlock()
+			}
+		}()
+	}
+	waiting.Wait()
+
+	return matchup, err
+}
+
+// simulateRun plays one run of a simulation with the given seed, returning whether the Skirmish was played to its end.
+func simulateRun(companies []data.Company, seed int64, options []Option) (engine *Engine, finished bool, err error) {
+	game := &Skirmish{Companies: copyCompanies(companies), Agents: make(map[string]string)}
+	for _, company := range companies {
+		game.Agents[company.Name] = HeuristicAgent
+	}
+	engine = NewEngine(game, append(options, WithSeed(seed))...)
+	if err := engine.Start(); err != nil {
+		return engine, false, err
+	}
+	if err := engine.PlayAgents(); err != nil || !game.Ended {
+		log.Warn().Msgf("unable to finish simulating the run with seed %d: %v", seed, err)
+		return engine, false, nil
+	}
+	return engine, true, nil
+}
+
+func (matchup *Matchup) record(engine *Engine) {
+	game := engine.Skirmish
+	if game.Winner == "" {
+		matchup.Draws++
+	} else {
+		matchup.Wins[game.Winner]++
+	}
+	// A Skirmish ended by its turn limit ends as the turn after the last one starts
+	turns := game.Turn
+	if game.MaximumTurns > 0 && turns > game.MaximumTurns {
+		turns = game.MaximumTurns
+	}
+	matchup.Turns += turns
+
+	// Groups may share names, so their Casualties are kept in the order the Groups are in
+	index := 0
+	for _, company := range game.Companies {
+		for _, group := range company.Groups {
+			casualties := matchup.Casualties[index]
+			index++
+			casualties.Lost += group.FightingStrength.Maximum - group.FightingStrength.Current
+			if groupState, err := engine.GroupState(group.Id); err == nil && !groupState.InPlay() {
+				casualties.Removed[groupState.Status]++
+			}
+		}
+	}
+
+	for _, notification := range engine.Feed() {
+		if notification.Kind == NotificationTrait {
+			matchup.Triggers[notification.Trait]++
+		}
+	}
+}
+
+// Finished is how many runs were played to the end.
+func (matchup *Matchup) Finished() int {
+	return matchup.Runs - matchup.Unfinished
+}
+
+func (matchup *Matchup) WinRate(company string) float64 {
+	if matchup.Finished() == 0 {
+		return 0
+	}
+	return float64(matchup.Wins[company]) / float64(matchup.Finished())
+}
+
+func (matchup *Matchup) DrawRate() float64 {
+	if matchup.Finished() == 0 {
+		return 0
+	}
+	return float64(matchup.Draws) / float64(matchup.Finished())
+}
+
+func (matchup *Matchup) AverageTurns() float64 {
+	if matchup.Finished() == 0 {
+		return 0
+	}
+	return float64(matchup.Turns) / float64(matchup.Finished())
+}
+
+// AverageLost is the Fighting Strength the Group lost per run.
+func (matchup *Matchup) AverageLost(casualties *Casualties) float64 {
+	if matchup.Finished() == 0 {
+		return 0
+	}
+	return float64(casualties.Lost) / float64(matchup.Finished())
+}
+
+// RemovedRate is how often the Group left play, however it did.
+func (matchup *Matchup) RemovedRate(casualties *Casualties) float64 {
+	if matchup.Finished() == 0 {
+		return 0
+	}
+	removed := 0
+	for _, count := range casualties.Removed {
+		removed += count
+	}
+	return float64(removed) / float64(matchup.Finished())
+}
+
+// TopTriggers returns the traits which triggered most often, most first; ties are in alphabetical order.
+func (matchup *Matchup) TopTriggers(count int) (top []TriggerCount) {
+	for trait, triggered := range matchup.Triggers {
+		top = append(top, TriggerCount{Trait: trait, Count: triggered})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Trait < top[j].Trait
+	})
+	if count > 0 && len(top) > count {
+		top = top[:count]
+	}
+	return top
+}
@@ -0,0 +1,52 @@
+package skirmish
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/dice"
+)
+
+// coreMatchup simulates the first two core companies, with their Captains rolled from the seed like the simulate
+// command does.
+func coreMatchup(t *testing.T, runs int, seed int64) *Matchup {
+	t.Helper()
+	traits := coreTraits(t)
+	profiles := readCore[data.Profile](t, "Profiles.yaml")
+	companies := readCore[data.Company](t, "Companies.yaml")[:2]
+	roller := dice.NewRoller(seed)
+	for index := range companies {
+		if err := companies[index].Initialize(profiles, traits, roller); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	matchup, err := Simulate(companies, runs, seed,
+		WithMaximumTurns(12),
+		WithScriptEngine(coreScriptEngine(t)),
+		WithTraits(traits),
+		WithSpells(readCore[data.Spell](t, "Spells.yaml")),
+		WithPrompts(readCore[data.Prompt](t, "Prompts.yaml")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matchup
+}
+
+// The runs are shared out between as many workers as there are processors, so the same seed simulates the same
+// results however many there are.
+func TestSimulateIsReproducibleFromItsSeed(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	first := coreMatchup(t, 8, 7)
+	runtime.GOMAXPROCS(1)
+	second := coreMatchup(t, 8, 7)
+	if first.Finished() == 0 {
+		t.Fatalf("expected some runs to finish, but all %d were unfinished", first.Unfinished)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("expected the same seed to simulate the same results, got\n%+v\nand\n%+v", first, second)
+	}
+}
@@ -6,15 +6,36 @@ import (
 
 	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
 	"github.com/FlagrantGarden/flfa/pkg/tympan/state"
-	"github.com/google/uuid"
 )
 
 type Skirmish struct {
-	Scenario  string
-	Attackers []string
-	Defenders []string
-	Companies []data.Company
-	Updates   string
+	Scenario        string
+	Attackers       []string
+	Defenders       []string
+	Companies       []data.Company
+	Battlefield     Battlefield
+	Setup           Setup
+	Updates         []Update
+	Undone          []Update
+	Seed            int64
+	Phase           Phase
+	Turn            int
+	MaximumTurns    int `mapstructure:"maximum_turns"`
+	Initiative      []string
+	InitiativeRolls map[string]int `mapstructure:"initiative_rolls"`
+	Scores          map[string]int
+	ActiveCompany   string        `mapstructure:"active_company"`
+	ActiveGroup     string        `mapstructure:"active_group"`
+	ActiveOrder     Order         `mapstructure:"active_order"`
+	ActiveTargets   []string      `mapstructure:"active_targets"`
+	GroupStates     []GroupState  `mapstructure:"group_states"`
+	SpellEffects    []SpellEffect `mapstructure:"spell_effects"`
+	Ended           bool
+	Winner          string
+	// The Agents name the Agent controlling each computer-controlled Company; players control the rest.
+	Agents map[string]string
+	// The Players name the persona playing each Company when the players share a terminal.
+	Players map[string]string
 }
 
 func (skirmish Skirmish) Initialize() *Skirmish {
@@ -24,28 +45,59 @@ func (skirmish Skirmish) Initialize() *Skirmish {
 	return &Skirmish{}
 }
 
-type Updates struct {
-	Company       string
-	Type          string
-	Subtype       string
-	Actor         uuid.UUID
-	Target        uuid.UUID
-	StartLocation Location `mapstructure:"start_location"`
-	EndLocation   Location `mapstructure:"end_location"`
-	Result        string
+type GroupState struct {
+	Id        string
+	Company   string
+	Status    GroupStatus
+	Activated bool
+	Deployed  bool
+	Morale    Morale
+	Location  *Location
+	Spells    []string
 }
 
+type GroupStatus string
+
+const (
+	InPlay    GroupStatus = "in_play"
+	Routed    GroupStatus = "routed"
+	Destroyed GroupStatus = "destroyed"
+	Deserted  GroupStatus = "deserted"
+	Removed   GroupStatus = "removed"
+)
+
+func (groupState GroupState) InPlay() bool {
+	return groupState.Status == InPlay
+}
+
+func (groupState GroupState) Shaken() bool {
+	return groupState.Morale == MoraleShaken
+}
+
+type Morale string
+
+const (
+	MoraleSteady Morale = "steady"
+	MoraleShaken Morale = "shaken"
+	MoraleRouted Morale = "routed"
+)
+
 type Location struct {
 	X int
 	Y int
 }
 
 type Result struct {
-	Activation    string
-	InflictHits   int    `mapstructure:"inflict_hits"`
-	ReceiveHits   int    `mapstructure:"receive_hits"`
-	ActorResolve  string `mapstructure:"actor_resolve"`
-	TargetResolve string `mapstructure:"target_resolve"`
+	Activation      string
+	ActivationRolls []int  `mapstructure:"activation_rolls"`
+	InflictRolls    []int  `mapstructure:"inflict_rolls"`
+	InflictHits     int    `mapstructure:"inflict_hits"`
+	InflictLosses   int    `mapstructure:"inflict_losses"`
+	ReceiveRolls    []int  `mapstructure:"receive_rolls"`
+	ReceiveHits     int    `mapstructure:"receive_hits"`
+	ReceiveLosses   int    `mapstructure:"receive_losses"`
+	ActorResolve    string `mapstructure:"actor_resolve"`
+	TargetResolve   string `mapstructure:"target_resolve"`
 }
 
 type TestResult string
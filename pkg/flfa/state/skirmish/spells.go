@@ -0,0 +1,343 @@
+package skirmish
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/utils"
+	"github.com/d5/tengo/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// A SpellEffect is a spell which was successfully cast and is still affecting its target.
+type SpellEffect struct {
+	Spell    string
+	Caster   string
+	Target   string
+	Company  string
+	Turn     int
+	Duration Duration
+}
+
+// key identifies the effect among every other effect of the same spell on the same target.
+func (effect SpellEffect) key() string {
+	return fmt.Sprintf("%s by '%s' on turn %d", effect.Spell, effect.Caster, effect.Turn)
+}
+
+type Duration string
+
+const (
+	DurationInstant        Duration = "instant"
+	DurationUntilNextTurn  Duration = "until_next_turn"
+	DurationUntilEndOfTurn Duration = "until_end_of_turn"
+	DurationUntilCountered Duration = "until_countered"
+)
+
+// ParseDuration turns the duration of a spell as written, like "Until the start of your next turn.", into a Duration.
+// Spells without a duration are instant; any duration which is not understood lasts until the spell is countered.
+func ParseDuration(text string) Duration {
+	text = strings.ToLower(strings.TrimSpace(text))
+	switch {
+	case text == "":
+		return DurationInstant
+	case strings.Contains(text, "next turn"):
+		return DurationUntilNextTurn
+	case strings.Contains(text, "end of the turn"), strings.Contains(text, "end of this turn"):
+		return DurationUntilEndOfTurn
+	}
+	log.Warn().Msgf("unknown spell duration '%s'; the spell will last until it is countered", text)
+	return DurationUntilCountered
+}
+
+// SpellList returns the spells a Group knows and may Cast.
+func (engine *Engine) SpellList(groupId string) (spells []data.Spell) {
+	groupState, err := engine.GroupState(groupId)
+	if err != nil {
+		return spells
+	}
+	for _, name := range groupState.Spells {
+		spell := data.GetSpellByName(name, engine.Spells)
+		if spell.Name != "" {
+			spells = append(spells, spell)
+		}
+	}
+	return spells
+}
+
+// AddToSpellList adds the named spells to the list of spells a Group knows; the name "all" adds every spell.
+func (engine *Engine) AddToSpellList(groupId string, names ...string) (err error) {
+	done := engine.recording(Update{Type: UpdateAddToSpellList, Subtype: strings.Join(names, ", "), Actor: groupId})
+	defer func() { done(Result{}, err) }()
+	groupState, err := engine.GroupState(groupId)
+	if err != nil {
+		return fmt.Errorf("unable to add to spell list: %s", err)
+	}
+	for _, name := range names {
+		if strings.EqualFold(name, "all") {
+			for _, spell := range engine.Spells {
+				if !utils.Contains(groupState.Spells, spell.Name) {
+					groupState.Spells = append(groupState.Spells, spell.Name)
+				}
+			}
+			continue
+		}
+		spell := data.GetSpellByName(name, engine.Spells)
+		if spell.Name == "" {
+			return fmt.Errorf("unable to add to spell list: no spell named '%s'", name)
+		}
+		if !utils.Contains(groupState.Spells, spell.Name) {
+			groupState.Spells = append(groupState.Spells, spell.Name)
+		}
+	}
+	return nil
+}
+
+// The Groups a spell may target depend on its target type and range. Target types are comma-separated and may be
+// "Self", "Group", or "Another Group", optionally narrowed to "Friendly" or "Enemy" Groups.
+func (engine *Engine) spellTargets(caster *data.Group, spell data.Spell) (targets []string) {
+	targets = []string{}
+	casterCompany := engine.CompanyOf(caster.Id)
+	for _, company := range engine.Skirmish.Companies {
+		for _, group := range engine.GroupsInPlay(company.Name) {
+			if !spellCanTarget(spell.Target, group.Id == caster.Id, company.Name == casterCompany) {
+				continue
+			}
+			if distance, known := engine.Distance(caster.Id, group.Id); known && spell.Range > 0 && distance > float64(spell.Range) {
+				continue
+			}
+			targets = append(targets, group.Id)
+		}
+	}
+	return targets
+}
+
+func spellCanTarget(targetTypes string, self bool, friendly bool) bool {
+	for _, targetType := range strings.Split(strings.ToLower(targetTypes), ",") {
+		targetType = strings.TrimSpace(targetType)
+		switch {
+		case targetType == "self":
+			if self {
+				return true
+			}
+		case strings.Contains(targetType, "group"):
+			if self && strings.Contains(targetType, "another") {
+				continue
+			}
+			if strings.Contains(targetType, "friend") && !friendly {
+				continue
+			}
+			if strings.Contains(targetType, "enem") && friendly {
+				continue
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// The Groups a caster may target with at least one of its spells
+func (engine *Engine) castingTargets(caster *data.Group) (targets []string) {
+	targets = []string{}
+	for _, spell := range engine.SpellList(caster.Id) {
+		for _, id := range engine.spellTargets(caster, spell) {
+			if !utils.Contains(targets, id) {
+				targets = append(targets, id)
+			}
+		}
+	}
+	return targets
+}
+
+// Cast has the active Group, which must have activated to Cast, cast the named spell from its spell list at the target
+// Group. Casting is tested by rolling 2d6 against the spell's Check instead of when activating. If the test passes,
+// the spell takes effect: its in-play scripts are registered for the target until its duration ends and any registered
+// for SpellCast are run right away. If it fails, the active Company's part of the turn ends.
+func (engine *Engine) Cast(spellName string, targetId string) (result Result, err error) {
+	errorPrefix := "unable to cast"
+	done := engine.recording(Update{Type: UpdateCast, Subtype: spellName, Actor: engine.Skirmish.ActiveGroup, Target: targetId})
+	defer func() { done(result, err) }()
+	if engine.Skirmish.ActiveGroup == "" || engine.Skirmish.ActiveOrder != OrderCast {
+		return result, fmt.Errorf("%s: no group has activated to cast", errorPrefix)
+	}
+	caster, err := engine.Group(engine.Skirmish.ActiveGroup)
+	if err != nil {
+		return result, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	target, err := engine.Group(targetId)
+	if err != nil {
+		return result, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	errorPrefix = fmt.Sprintf("unable to cast '%s' by group '%s' on group '%s'", spellName, caster.Name, target.Name)
+
+	var spell data.Spell
+	for _, known := range engine.SpellList(caster.Id) {
+		if strings.EqualFold(known.Name, spellName) {
+			spell = known
+		}
+	}
+	if spell.Name == "" {
+		return result, fmt.Errorf("%s: the spell is not in the group's spell list", errorPrefix)
+	}
+	if !utils.Contains(engine.Skirmish.ActiveTargets, targetId) || !utils.Contains(engine.spellTargets(caster, spell), targetId) {
+		return result, fmt.Errorf("%s: the spell can only target %s within %d\"", errorPrefix, strings.ToLower(spell.Target), spell.Range)
+	}
+
+	rolls := engine.Dice.TwoD6(fmt.Sprintf("'%s' casting '%s'", caster.Name, spell.Name))
+	result.ActivationRolls = rolls
+	log.Trace().Msgf("group '%s' rolled %v against %d+ to cast '%s'", caster.Name, rolls, spell.Check, spell.Name)
+	if sum(rolls) < spell.Check {
+		result.Activation = string(Fail)
+		engine.EndTurn()
+		return result, nil
+	}
+	result.Activation = string(Pass)
+
+	effect := SpellEffect{
+		Spell:    spell.Name,
+		Caster:   caster.Id,
+		Target:   target.Id,
+		Company:  engine.CompanyOf(caster.Id),
+		Turn:     engine.Skirmish.Turn,
+		Duration: ParseDuration(spell.Duration),
+	}
+	engine.Events.RegisterSpellEffect(effect)
+	event := &Event{Name: SpellCast, Actor: caster, Target: target, Subjects: []*data.Group{target}}
+	effects, err := engine.Events.Dispatch(event)
+	if err != nil {
+		return result, fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	if effect.Duration == DurationInstant {
+		engine.Events.UnregisterSpellEffect(effect)
+	} else {
+		engine.Skirmish.SpellEffects = append(engine.Skirmish.SpellEffects, effect)
+	}
+	engine.applySpellEffects(effects)
+
+	engine.FinishActivation()
+
+	return result, nil
+}
+
+func (engine *Engine) applySpellEffects(effects Effects) {
+	for _, effect := range effects.Named("EndEffects") {
+		engine.EndSpellEffects(effect.Group.Id)
+	}
+	for _, effect := range effects.Named("RestoreStrength") {
+		strength := &effect.Group.FightingStrength
+		strength.Current += intFrom(effect.Arguments, 0, 1)
+		if strength.Current > strength.Maximum {
+			strength.Current = strength.Maximum
+		}
+	}
+}
+
+// EndSpellEffects ends every spell effect on the target Group as if their durations had ended.
+func (engine *Engine) EndSpellEffects(targetId string) {
+	done := engine.recording(Update{Type: UpdateEndSpells, Actor: targetId})
+	defer done(Result{}, nil)
+	var remaining, ended []SpellEffect
+	for _, effect := range engine.Skirmish.SpellEffects {
+		if effect.Target == targetId {
+			ended = append(ended, effect)
+			continue
+		}
+		remaining = append(remaining, effect)
+	}
+	engine.endSpellEffects(remaining, ended)
+}
+
+// Spell effects lasting until the start of the caster's next turn end when their Company next becomes active; those
+// lasting until the end of the turn end once every Company has had its part of the turn.
+func (engine *Engine) expireSpellEffects() {
+	var remaining, ended []SpellEffect
+	for _, effect := range engine.Skirmish.SpellEffects {
+		expired := false
+		switch effect.Duration {
+		case DurationUntilNextTurn:
+			expired = engine.Skirmish.ActiveCompany == effect.Company
+		case DurationUntilEndOfTurn:
+			expired = engine.Skirmish.Turn > effect.Turn
+		}
+		if expired {
+			ended = append(ended, effect)
+			continue
+		}
+		remaining = append(remaining, effect)
+	}
+	engine.endSpellEffects(remaining, ended)
+}
+
+// endSpellEffects keeps only the remaining spell effects before unregistering the ended ones, so an ended effect never
+// takes over the scripts of another effect of the same spell ending with it.
+func (engine *Engine) endSpellEffects(remaining []SpellEffect, ended []SpellEffect) {
+	engine.Skirmish.SpellEffects = remaining
+	for _, effect := range ended {
+		engine.Events.UnregisterSpellEffect(effect)
+		log.Trace().Msgf("spell '%s' on group '%s' has ended", effect.Spell, effect.Target)
+	}
+}
+
+func spellTraitName(spell string) string {
+	return fmt.Sprintf("Spell: %s", spell)
+}
+
+// RegisterSpellEffect registers the in-play scripts of the effect's spell for its target. The same spell cast on a
+// Group more than once does not stack, so its scripts are only registered for the first of the effects.
+func (bus *Bus) RegisterSpellEffect(effect SpellEffect) {
+	if bus.engine.ScriptEngine == nil || bus.spellRegistered(effect) {
+		return
+	}
+	spell := data.GetSpellByName(effect.Spell, bus.engine.Spells)
+	for index, inPlay := range spell.Scripting.InPlay {
+		bus.register(registration{Owner: effect.Target, Trait: spellTraitName(spell.Name), Index: index, InPlay: inPlay, Effect: effect.key()})
+	}
+}
+
+// UnregisterSpellEffect removes the in-play scripts registered for the effect. If another effect of the same spell
+// still affects the target, its scripts are registered for that effect instead.
+func (bus *Bus) UnregisterSpellEffect(effect SpellEffect) {
+	for eventName, registrations := range bus.registrations {
+		var remaining []registration
+		for _, registration := range registrations {
+			if registration.Owner == effect.Target && registration.Effect == effect.key() {
+				continue
+			}
+			remaining = append(remaining, registration)
+		}
+		bus.registrations[eventName] = remaining
+	}
+	for _, other := range bus.engine.Skirmish.SpellEffects {
+		if other.Target == effect.Target && other.Spell == effect.Spell && other.key() != effect.key() {
+			bus.RegisterSpellEffect(other)
+		}
+	}
+}
+
+func (bus *Bus) spellRegistered(effect SpellEffect) bool {
+	for _, registrations := range bus.registrations {
+		for _, registration := range registrations {
+			if registration.Owner == effect.Target && registration.Trait == spellTraitName(effect.Spell) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (bus *Bus) addSpellNatives() {
+	bus.AddNative("GroupInPlay", "AddToSpellList", func(dispatch *Dispatch, arguments ...tengo.Object) (tengo.Object, error) {
+		var names []string
+		for _, argument := range arguments {
+			value := tengo.ToInterface(argument)
+			if name, ok := value.(string); ok {
+				names = append(names, name)
+			} else {
+				names = append(names, stringsFrom(value)...)
+			}
+		}
+		return tengo.UndefinedValue, bus.engine.AddToSpellList(dispatch.Group.Id, names...)
+	})
+	bus.AddEffect("Spells", "EndEffects")
+	bus.AddEffect("Spells", "RestoreStrength")
+}
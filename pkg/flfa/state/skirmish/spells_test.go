@@ -0,0 +1,41 @@
+package skirmish
+
+import (
+	"testing"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+)
+
+// The same spell cast on a Group twice runs its scripts once, and ending either effect leaves the other in play.
+func TestSpellEffectsEndOnlyThemselves(t *testing.T) {
+	skirmish := twoCompanySkirmish().Skirmish
+	skirmish.Companies[0].Groups = append(skirmish.Companies[0].Groups, data.Group{Name: "Mages", Id: "alpha-mages"})
+	skirmish.GroupStates = append(skirmish.GroupStates, GroupState{Id: "alpha-mages", Company: "Alpha", Status: InPlay, Morale: MoraleSteady})
+	ward := data.Spell{Name: "Ward", Scripting: data.SpellScripting{InPlay: []data.TraitScriptingInPlay{{
+		RegisterFor: []string{string(ShouldTestResolve)},
+		Then:        []string{`result.test = false`},
+	}}}}
+	engine := NewEngine(skirmish, WithSeed(1), WithScriptEngine(coreScriptEngine(t)), WithSpells([]data.Spell{ward}))
+	engine.Events.RegisterGroups()
+
+	first := SpellEffect{Spell: "Ward", Caster: "alpha-scouts", Target: "alpha-scouts", Company: "Alpha", Turn: 1, Duration: DurationUntilCountered}
+	second := SpellEffect{Spell: "Ward", Caster: "alpha-mages", Target: "alpha-scouts", Company: "Alpha", Turn: 1, Duration: DurationUntilCountered}
+	for _, effect := range []SpellEffect{first, second} {
+		engine.Events.RegisterSpellEffect(effect)
+		engine.Skirmish.SpellEffects = append(engine.Skirmish.SpellEffects, effect)
+	}
+	if registered := len(engine.Events.registrations[ShouldTestResolve]); registered != 1 {
+		t.Fatalf("expected the spell's script to be registered once while both effects last, got %d", registered)
+	}
+
+	engine.endSpellEffects([]SpellEffect{second}, []SpellEffect{first})
+	registrations := engine.Events.registrations[ShouldTestResolve]
+	if len(registrations) != 1 || registrations[0].Effect != second.key() {
+		t.Fatalf("expected the spell's script to stay registered for the remaining effect, got %+v", registrations)
+	}
+
+	engine.EndSpellEffects("alpha-scouts")
+	if registered := len(engine.Events.registrations[ShouldTestResolve]); registered != 0 {
+		t.Errorf("expected no scripts once every effect has ended, got %d", registered)
+	}
+}
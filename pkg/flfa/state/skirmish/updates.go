@@ -0,0 +1,278 @@
+package skirmish
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/rs/zerolog/log"
+)
+
+// An Update records a single command given to the Engine, like activating a Group or having it Attack, along with its
+// outcome. A Skirmish's Updates are an append-only log: replaying them in order from the Skirmish's Setup always
+// produces the same state, because every roll comes from the Skirmish's seed.
+type Update struct {
+	Company       string
+	Turn          int
+	Type          UpdateType
+	Subtype       string
+	Actor         string
+	Target        string
+	StartLocation *Location `mapstructure:"start_location"`
+	EndLocation   *Location `mapstructure:"end_location"`
+	Result        Result
+	Answers       []Answer
+	Notifications []Notification
+}
+
+type UpdateType string
+
+const (
+	UpdateDeploy         UpdateType = "deploy"
+	UpdatePlace          UpdateType = "place"
+	UpdateActivate       UpdateType = "activate"
+	UpdateMove           UpdateType = "move"
+	UpdateAttack         UpdateType = "attack"
+	UpdateShoot          UpdateType = "shoot"
+	UpdateCast           UpdateType = "cast"
+	UpdateFinish         UpdateType = "finish"
+	UpdateEndTurn        UpdateType = "end_turn"
+	UpdateTestResolve    UpdateType = "test_resolve"
+	UpdateAddToSpellList UpdateType = "add_to_spell_list"
+	UpdateEndSpells      UpdateType = "end_spells"
+	UpdateRemove         UpdateType = "remove"
+)
+
+// The Setup is what a Skirmish looked like before it started; together with the seed and the log of Updates, it is
+// enough to rebuild the Skirmish at any point.
+type Setup struct {
+	Companies     []data.Company
+	Attackers     []string
+	Defenders     []string
+	Initiative    []string
+	Answers       []Answer
+	Notifications []Notification
+}
+
+// WithUpdateHook sets a function for the Engine to call every time it appends an Update to the log, such as one which
+// saves the Skirmish.
+func WithUpdateHook(hook func(skirmish *Skirmish, update Update)) Option {
+	return func(engine *Engine) {
+		engine.updateHook = hook
+	}
+}
+
+// recording is called at the start of every command. Commands are often given by other commands, like a failed
+// activation ending the turn; only the outermost command is appended to the log, once it has succeeded. The returned
+// function must be called when the command is done.
+func (engine *Engine) recording(update Update) func(result Result, err error) {
+	update.Company = engine.Skirmish.ActiveCompany
+	update.Turn = engine.Skirmish.Turn
+	if engine.depth == 0 {
+		engine.answers = nil
+		engine.notifications = nil
+		engine.actor = update.Actor
+	}
+	engine.depth++
+	return func(result Result, err error) {
+		engine.depth--
+		if engine.depth > 0 || err != nil {
+			return
+		}
+		update.Result = result
+		update.Answers = engine.answers
+		update.Notifications = engine.notifications
+		engine.Skirmish.Updates = append(engine.Skirmish.Updates, update)
+		if !engine.redoing {
+			engine.Skirmish.Undone = nil
+		}
+		if engine.updateHook != nil {
+			engine.updateHook(engine.Skirmish, update)
+		}
+	}
+}
+
+// querying reports whether the Engine is only being asked about the Skirmish, like which orders a Group may be given,
+// rather than carrying out a command. Trait scripts still run for queries, but nothing they do may be kept, since there
+// is no Update to log it in.
+func (engine *Engine) querying() bool {
+	return engine.depth == 0
+}
+
+// Companies are copied deeply enough that playing the Skirmish never changes its Setup.
+func copyCompanies(companies []data.Company) (copied []data.Company) {
+	for _, company := range companies {
+		company.Groups = append([]data.Group{}, company.Groups...)
+		for index := range company.Groups {
+			group := &company.Groups[index]
+			group.Traits = append([]string{}, group.Traits...)
+			group.ExtraMissiles = append([]data.Missile{}, group.ExtraMissiles...)
+			group.Addenda = copyValue(group.Addenda).(map[string]any)
+		}
+		copied = append(copied, company)
+	}
+	return copied
+}
+
+func copyValue(value any) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		if typed == nil {
+			return typed
+		}
+		copied := make(map[string]any, len(typed))
+		for key, item := range typed {
+			copied[key] = copyValue(item)
+		}
+		return copied
+	case []any:
+		copied := make([]any, len(typed))
+		for index, item := range typed {
+			copied[index] = copyValue(item)
+		}
+		return copied
+	case []string:
+		return append([]string{}, typed...)
+	}
+	return value
+}
+
+// Rebuild resets the Skirmish to its Setup and replays every Update in its log, deriving its current state.
+func (engine *Engine) Rebuild() error {
+	return engine.rebuildFrom(engine.Skirmish.Updates)
+}
+
+func (engine *Engine) rebuildFrom(updates []Update) error {
+	errorPrefix := "unable to rebuild skirmish"
+	setup := engine.Skirmish.Setup
+	if len(setup.Companies) == 0 {
+		return fmt.Errorf("%s: it has not been started", errorPrefix)
+	}
+
+	undone := engine.Skirmish.Undone
+	engine.Skirmish.Companies = copyCompanies(setup.Companies)
+	engine.Skirmish.Attackers = append([]string{}, setup.Attackers...)
+	engine.Skirmish.Defenders = append([]string{}, setup.Defenders...)
+	engine.Skirmish.Initiative = append([]string{}, setup.Initiative...)
+	engine.Skirmish.Updates = nil
+	engine.Skirmish.SpellEffects = nil
+	engine.Skirmish.Scores = nil
+	engine.Skirmish.InitiativeRolls = nil
+	engine.Skirmish.ActiveGroup = ""
+	engine.Skirmish.ActiveOrder = ""
+	engine.Skirmish.ActiveTargets = nil
+	engine.reseed()
+	engine.Events = NewBus(engine)
+	engine.given = setup.Answers
+
+	// Replaying must not clear the Updates which were undone or call the hook for Updates already logged
+	hook := engine.updateHook
+	engine.updateHook = nil
+	engine.redoing = true
+	defer func() {
+		engine.updateHook = hook
+		engine.redoing = false
+		engine.given = nil
+		engine.Skirmish.Undone = undone
+	}()
+
+	if err := engine.Start(); err != nil {
+		return fmt.Errorf("%s: %s", errorPrefix, err)
+	}
+	for index, update := range updates {
+		if err := engine.Apply(update); err != nil {
+			return fmt.Errorf("%s: unable to replay update %d (%s): %s", errorPrefix, index+1, update.Type, err)
+		}
+	}
+	return nil
+}
+
+// Apply gives the Engine the command an Update records, answering any Questions it asks as they were answered before.
+func (engine *Engine) Apply(update Update) (err error) {
+	engine.given = update.Answers
+	defer func() { engine.given = nil }()
+	switch update.Type {
+	case UpdateDeploy:
+		if update.EndLocation == nil {
+			return fmt.Errorf("no location to deploy to")
+		}
+		return engine.Deploy(update.Actor, *update.EndLocation)
+	case UpdatePlace:
+		if update.EndLocation == nil {
+			return fmt.Errorf("no location to place at")
+		}
+		return engine.PlaceGroup(update.Actor, *update.EndLocation)
+	case UpdateActivate:
+		_, err = engine.Activate(update.Actor, Order(update.Subtype))
+	case UpdateMove:
+		if update.EndLocation == nil {
+			return fmt.Errorf("no location to move to")
+		}
+		return engine.Move(*update.EndLocation)
+	case UpdateAttack:
+		_, err = engine.Attack(update.Target)
+	case UpdateShoot:
+		_, err = engine.Shoot(update.Target, update.Subtype)
+	case UpdateCast:
+		_, err = engine.Cast(update.Subtype, update.Target)
+	case UpdateFinish:
+		engine.FinishActivation()
+	case UpdateEndTurn:
+		engine.EndTurn()
+	case UpdateTestResolve:
+		_, err = engine.TestResolve(update.Actor, update.Target)
+	case UpdateAddToSpellList:
+		err = engine.AddToSpellList(update.Actor, strings.Split(update.Subtype, ", ")...)
+	case UpdateEndSpells:
+		engine.EndSpellEffects(update.Actor)
+	case UpdateRemove:
+		err = engine.RemoveGroup(update.Actor, GroupStatus(update.Subtype))
+	default:
+		err = fmt.Errorf("unknown update type '%s'", update.Type)
+	}
+	return err
+}
+
+func (engine *Engine) CanUndo() bool {
+	return len(engine.Skirmish.Updates) > 0
+}
+
+func (engine *Engine) CanRedo() bool {
+	return len(engine.Skirmish.Undone) > 0
+}
+
+// Undo removes the last Update from the log and rebuilds the Skirmish without it. The Update can be reapplied with
+// Redo until a new command is given.
+func (engine *Engine) Undo() error {
+	if !engine.CanUndo() {
+		return fmt.Errorf("unable to undo: nothing has happened yet")
+	}
+	updates := engine.Skirmish.Updates
+	last := updates[len(updates)-1]
+	engine.Skirmish.Undone = append(engine.Skirmish.Undone, last)
+	if err := engine.rebuildFrom(updates[:len(updates)-1]); err != nil {
+		return fmt.Errorf("unable to undo: %s", err)
+	}
+	log.Trace().Msgf("undid %s on turn %d", last.Type, last.Turn)
+	if engine.updateHook != nil {
+		engine.updateHook(engine.Skirmish, last)
+	}
+	return nil
+}
+
+// Redo reapplies the most recently undone Update.
+func (engine *Engine) Redo() error {
+	if !engine.CanRedo() {
+		return fmt.Errorf("unable to redo: nothing has been undone")
+	}
+	undone := engine.Skirmish.Undone
+	next := undone[len(undone)-1]
+	engine.redoing = true
+	err := engine.Apply(next)
+	engine.redoing = false
+	if err != nil {
+		return fmt.Errorf("unable to redo: %s", err)
+	}
+	engine.Skirmish.Undone = undone[:len(undone)-1]
+	return nil
+}
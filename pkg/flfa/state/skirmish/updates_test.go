@@ -0,0 +1,110 @@
+package skirmish
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/dice"
+	"gopkg.in/yaml.v3"
+)
+
+// coreSkirmish starts a Skirmish between the first two core companies with both controlled by the heuristic agent.
+func coreSkirmish(t *testing.T, seed int64) *Engine {
+	t.Helper()
+	traits := coreTraits(t)
+	profiles := readCore[data.Profile](t, "Profiles.yaml")
+	companies := readCore[data.Company](t, "Companies.yaml")[:2]
+	roller := dice.NewRoller(seed)
+	game := &Skirmish{Agents: make(map[string]string)}
+	for _, company := range companies {
+		if err := company.Initialize(profiles, traits, roller); err != nil {
+			t.Fatal(err)
+		}
+		game.Companies = append(game.Companies, company)
+		game.Agents[company.Name] = HeuristicAgent
+	}
+	engine := NewEngine(game, WithSeed(seed), WithScriptEngine(coreScriptEngine(t)), WithTraits(traits),
+		WithSpells(readCore[data.Spell](t, "Spells.yaml")), WithPrompts(readCore[data.Prompt](t, "Prompts.yaml")))
+	if err := engine.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return engine
+}
+
+func snapshot(t *testing.T, skirmish *Skirmish) string {
+	t.Helper()
+	content, err := yaml.Marshal(skirmish)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func fightingStrengths(skirmish *Skirmish) map[string]int {
+	strengths := make(map[string]int)
+	for _, company := range skirmish.Companies {
+		for _, group := range company.Groups {
+			strengths[group.Id] = group.FightingStrength.Current
+		}
+	}
+	return strengths
+}
+
+func answers(updates []Update) (given []Answer) {
+	for _, update := range updates {
+		given = append(given, update.Answers...)
+	}
+	return given
+}
+
+// Undoing commands rebuilds the Skirmish from its Setup without them, and redoing them replays them with the answers
+// the log gives, so the Skirmish ends up just as it was.
+func TestUndoThenRedoRestoresTheSkirmish(t *testing.T) {
+	engine := coreSkirmish(t, 3)
+	starting := fightingStrengths(engine.Skirmish)
+	// Play until a trait has prompted for an answer and a Group has lost Fighting Strength
+	for len(answers(engine.Skirmish.Updates)) == 0 || reflect.DeepEqual(fightingStrengths(engine.Skirmish), starting) {
+		if advanced, err := engine.Advance(); err != nil || !advanced {
+			t.Fatalf("expected the skirmish to go on until a prompt was answered and a group lost strength, got %v", err)
+		}
+	}
+	played := snapshot(t, engine.Skirmish)
+	strengths := fightingStrengths(engine.Skirmish)
+	given := answers(engine.Skirmish.Updates)
+	commands := len(engine.Skirmish.Updates)
+
+	for engine.CanUndo() {
+		if err := engine.Undo(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(engine.Skirmish.Undone) != commands {
+		t.Fatalf("expected %d commands to be undone, got %d", commands, len(engine.Skirmish.Undone))
+	}
+	if !reflect.DeepEqual(fightingStrengths(engine.Skirmish), starting) {
+		t.Errorf("expected undoing every command to restore the starting strengths %v, got %v", starting, fightingStrengths(engine.Skirmish))
+	}
+
+	for engine.CanRedo() {
+		if err := engine.Redo(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !reflect.DeepEqual(fightingStrengths(engine.Skirmish), strengths) {
+		t.Errorf("expected redoing every command to restore the strengths %v, got %v", strengths, fightingStrengths(engine.Skirmish))
+	}
+	if !reflect.DeepEqual(answers(engine.Skirmish.Updates), given) {
+		t.Errorf("expected redoing every command to log the answers %+v, got %+v", given, answers(engine.Skirmish.Updates))
+	}
+	if redone := snapshot(t, engine.Skirmish); redone != played {
+		t.Errorf("expected redoing every command to restore the skirmish as it was played, got\n%s\ninstead of\n%s", redone, played)
+	}
+
+	if err := engine.Rebuild(); err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt := snapshot(t, engine.Skirmish); rebuilt != played {
+		t.Errorf("expected rebuilding the skirmish to restore it as it was played, got\n%s\ninstead of\n%s", rebuilt, played)
+	}
+}
@@ -164,7 +164,7 @@ func (model *Model) UpdateGroupSelection() (cmd tea.Cmd) {
 
 		cmd = model.Group.Init()
 	case SelectingGroupToPromote:
-		model.Groups[choice.Index].PromoteToCaptain(nil, data.FilterTraitsBySource("core", model.Api.Cache.Traits)...)
+		model.Groups[choice.Index].PromoteToCaptain(nil, model.Api.Roller(), data.FilterTraitsBySource("core", model.Api.Cache.Traits)...)
 		cmd = model.SetAndStartSubstate(SelectingOption)
 	case CopyingGroup:
 		model.Groups = append(model.Groups, selectedGroup)
@@ -265,6 +265,7 @@ func (model *Model) UpdateCaptainReplacement() tea.Cmd {
 		model.Groups[model.Indexes.CurrentCaptain].DemoteFromCaptain()
 		model.Groups[model.Indexes.ReplacementCaptain].PromoteToCaptain(
 			nil,
+			model.Api.Roller(),
 			data.FilterTraitsBySource("core", model.Api.Cache.Traits)...,
 		)
 	}
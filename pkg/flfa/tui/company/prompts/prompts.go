@@ -7,6 +7,7 @@ import (
 
 	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
 	tprompts "github.com/FlagrantGarden/flfa/pkg/flfa/tui/traits/prompts"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/dice"
 	pterm "github.com/FlagrantGarden/flfa/pkg/tympan/printers/terminal"
 	"github.com/FlagrantGarden/flfa/pkg/tympan/prompts/confirmer"
 	"github.com/FlagrantGarden/flfa/pkg/tympan/prompts/selector"
@@ -206,9 +207,9 @@ type CaptainRerollChoice struct {
 	Trait   data.Trait
 }
 
-func SelectRerollCaptainTrait(group data.Group, availableTraits []data.Trait) *selection.Selection {
+func SelectRerollCaptainTrait(group data.Group, roller *dice.Roller, availableTraits []data.Trait) *selection.Selection {
 	copy := group
-	copy.PromoteToCaptain(nil, availableTraits...)
+	copy.PromoteToCaptain(nil, roller, availableTraits...)
 	options := []CaptainRerollChoice{
 		{
 			Message: fmt.Sprintf("Keep the new trait (%s) & return", copy.Captain.Name),
@@ -273,8 +274,8 @@ func SelectRerollCaptainTrait(group data.Group, availableTraits []data.Trait) *s
 	)
 }
 
-func SelectRerollCaptainTraitModel(group data.Group, availableTraits []data.Trait) *selection.Model {
-	return selection.NewModel(SelectRerollCaptainTrait(group, availableTraits))
+func SelectRerollCaptainTraitModel(group data.Group, roller *dice.Roller, availableTraits []data.Trait) *selection.Model {
+	return selection.NewModel(SelectRerollCaptainTrait(group, roller, availableTraits))
 }
 
 func SelectCaptainTrait(availableTraits []data.Trait) *selection.Selection {
@@ -65,6 +65,7 @@ func (state SubstateEditing) Start(model *Model) (cmd tea.Cmd) {
 	case RerollingCaptainTrait:
 		model.Selection = prompts.SelectRerollCaptainTraitModel(
 			model.CaptainsGroup(),
+			model.Api.Roller(),
 			data.FilterTraitsBySource("core", model.Api.Cache.Traits),
 		)
 		cmd = model.Selection.Init()
@@ -4,6 +4,7 @@ import (
 	company_prompts "github.com/FlagrantGarden/flfa/pkg/flfa/tui/company/prompts"
 	"github.com/FlagrantGarden/flfa/pkg/flfa/tui/editor/prompts"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 )
 
 type SubstateEditing int
@@ -95,7 +96,9 @@ func (state SubstateEditing) UpdateOnFallThrough(model *Model, msg tea.Msg) (cmd
 
 func (state SubstateEditing) View(model *Model) (view string) {
 	switch state {
-	case SelectingOption, SelectingCompanyToEdit, SelectingCompanyToRemove:
+	case SelectingOption:
+		view = lipgloss.JoinVertical(lipgloss.Left, model.ModulesView(), "", model.Selection.View())
+	case SelectingCompanyToEdit, SelectingCompanyToRemove:
 		view = model.Selection.View()
 	case ConfirmSave, ConfirmRemoval, ConfirmQuitWithoutSaving:
 		view = model.Confirmation.View()
@@ -0,0 +1,19 @@
+package editor
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ModulesView lists the loaded modules with their versions and authors, so it is clear whose data is being edited with.
+func (model *Model) ModulesView() string {
+	var modules []string
+	for _, manifest := range model.Api.Cache.Modules {
+		modules = append(modules, manifest.String())
+	}
+	if len(modules) == 0 {
+		return ""
+	}
+	return lipgloss.NewStyle().Faint(true).Render("Modules: " + strings.Join(modules, ", "))
+}
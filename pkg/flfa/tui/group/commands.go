@@ -1,6 +1,8 @@
 package group
 
 import (
+	"fmt"
+
 	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
 	"github.com/FlagrantGarden/flfa/pkg/tympan/compositor"
 	"github.com/FlagrantGarden/flfa/pkg/tympan/utils"
@@ -129,6 +131,8 @@ func (model *Model) UpdateEditingChoice() (cmd tea.Cmd) {
 		cmd = model.SetAndStartSubstate(ChangingBaseProfile)
 	case "Change Name":
 		cmd = model.SetAndStartSubstate(Renaming)
+	case "Compare Odds":
+		cmd = model.SetAndStartSubstate(ChoosingOpponent)
 	}
 
 	return
@@ -225,3 +229,51 @@ func (model *Model) UpdateTraitRemove() tea.Cmd {
 
 	return model.SetAndStartSubstate(SelectingOption)
 }
+
+// OpponentNames names the groups this Group can be compared against, with the company of those from it; selections
+// only know the chosen name, so the names must be unique.
+func (model *Model) OpponentNames() (names []string) {
+	fromCompany := 0
+	if model.Company != nil {
+		for _, group := range model.Company.Groups {
+			if group.Name != model.Name {
+				fromCompany++
+			}
+		}
+	}
+	for index, opponent := range model.Temp.Opponents {
+		if index < fromCompany {
+			names = append(names, fmt.Sprintf("%s (%s)", opponent.Name, model.Company.Name))
+			continue
+		}
+		names = append(names, opponent.Name)
+	}
+	return names
+}
+
+func (model *Model) UpdateOpponent() tea.Cmd {
+	choice, err := model.Selection.Value()
+	if err != nil {
+		return model.RecordFatalError(err)
+	}
+
+	index := utils.FindIndex(model.OpponentNames(), choice.String)
+	if index < 0 {
+		return model.RecordFatalError(fmt.Errorf("unable to compare odds: no group named '%s'", choice.String))
+	}
+	model.Temp.Opponent = &model.Temp.Opponents[index]
+	return model.SetAndStartSubstate(ComparingOdds)
+}
+
+func (model *Model) UpdateOddsOption() tea.Cmd {
+	choice, err := model.Selection.Value()
+	if err != nil {
+		return model.RecordFatalError(err)
+	}
+
+	model.Temp.Opponent = nil
+	if choice.String == "Compare Against Another Group" {
+		return model.SetAndStartSubstate(ChoosingOpponent)
+	}
+	return model.SetAndStartSubstate(SelectingOption)
+}
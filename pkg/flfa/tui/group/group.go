@@ -40,6 +40,8 @@ type Temp struct {
 	CompanyPoints     int
 	ProfileName       string
 	TraitsWithChoices []*data.Trait
+	Opponents         []data.Group
+	Opponent          *data.Group
 }
 
 const (
@@ -261,3 +263,22 @@ func (model *Model) UpdateCompanyWorkingPointTotal() {
 
 	model.Temp.CompanyPoints = companyPoints + groupPoints
 }
+
+// OddsOpponents returns the groups this Group can be compared against: the other groups in its company, then a new
+// group of every profile.
+func (model *Model) OddsOpponents() (opponents []data.Group) {
+	if model.Company != nil {
+		for _, group := range model.Company.Groups {
+			if group.Name != model.Name {
+				opponents = append(opponents, group)
+			}
+		}
+	}
+	for _, profile := range model.Api.Cache.Profiles {
+		group, err := data.NewGroup(profile.Name(), profile.Name(), model.Api.Cache.Profiles)
+		if err == nil {
+			opponents = append(opponents, group)
+		}
+	}
+	return opponents
+}
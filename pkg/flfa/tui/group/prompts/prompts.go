@@ -59,6 +59,8 @@ func SelectGroupEditingOption(hasAddableTraits bool, hasRemovableTraits bool) *s
 		options = append(options, "Remove Special Trait")
 	}
 
+	options = append(options, "Compare Odds")
+
 	return selector.NewStringSelector(
 		"What would you like to do with this Group?",
 		options,
@@ -106,3 +108,26 @@ func ConfirmChangeBaseProfile(new_profile string) *confirmation.Confirmation {
 func ConfirmChangeBaseProfileModel(new_profile string) *confirmation.Model {
 	return confirmation.NewModel(ConfirmChangeBaseProfile(new_profile))
 }
+
+func SelectOpponent(opponentNames []string) *selection.Selection {
+	return selector.NewStringSelector(
+		"Which group should this Group be compared against?",
+		opponentNames,
+		selector.WithPageSize(5),
+	)
+}
+
+func SelectOpponentModel(opponentNames []string) *selection.Model {
+	return selection.NewModel(SelectOpponent(opponentNames))
+}
+
+func SelectOddsOption() *selection.Selection {
+	return selector.NewStringSelector(
+		"What would you like to do next?",
+		[]string{"Back to Editing", "Compare Against Another Group"},
+	)
+}
+
+func SelectOddsOptionModel() *selection.Model {
+	return selection.NewModel(SelectOddsOption())
+}
@@ -18,6 +18,8 @@ const (
 	AddingSpecialTrait
 	RemovingSpecialTrait
 	MakingTraitChoice
+	ChoosingOpponent
+	ComparingOdds
 )
 
 func (state SubstateEditing) Start(model *Model) (cmd tea.Cmd) {
@@ -45,6 +47,13 @@ func (state SubstateEditing) Start(model *Model) (cmd tea.Cmd) {
 	case MakingTraitChoice:
 		model.TraitChooser = dynamic.New(model.CurrentTraitChoice().Prompt)
 		cmd = model.TraitChooser.Init()
+	case ChoosingOpponent:
+		model.Temp.Opponents = model.OddsOpponents()
+		model.Selection = prompts.SelectOpponentModel(model.OpponentNames())
+		cmd = model.Selection.Init()
+	case ComparingOdds:
+		model.Selection = prompts.SelectOddsOptionModel()
+		cmd = model.Selection.Init()
 	}
 
 	return cmd
@@ -60,6 +69,10 @@ func (state SubstateEditing) UpdateOnEnter(model *Model) (cmd tea.Cmd) {
 		cmd = model.UpdateBaseProfile(true)
 	case MakingTraitChoice, AddingSpecialTrait, RemovingSpecialTrait:
 		cmd = model.UpdateTrait()
+	case ChoosingOpponent:
+		cmd = model.UpdateOpponent()
+	case ComparingOdds:
+		cmd = model.UpdateOddsOption()
 	}
 
 	return cmd
@@ -77,7 +90,7 @@ func (state SubstateEditing) UpdateOnEnded(model *Model) (cmd tea.Cmd) {
 
 func (state SubstateEditing) UpdateOnFallThrough(model *Model, msg tea.Msg) (cmd tea.Cmd) {
 	switch state {
-	case SelectingOption, ChangingBaseProfile, AddingSpecialTrait, RemovingSpecialTrait:
+	case SelectingOption, ChangingBaseProfile, AddingSpecialTrait, RemovingSpecialTrait, ChoosingOpponent, ComparingOdds:
 		_, cmd = model.Selection.Update(msg)
 	case Renaming:
 		_, cmd = model.TextInput.Update(msg)
@@ -112,6 +125,18 @@ func (state SubstateEditing) View(model *Model) (view string) {
 		subview = model.Confirmation.View()
 	case MakingTraitChoice:
 		subview = model.TraitChooser.View()
+	case ChoosingOpponent:
+		subview = lipgloss.JoinVertical(
+			lipgloss.Left,
+			"Comparing the odds:",
+			model.Selection.View(),
+		)
+	case ComparingOdds:
+		subview = lipgloss.JoinVertical(
+			lipgloss.Left,
+			model.OddsView(),
+			model.Selection.View(),
+		)
 	}
 
 	return lipgloss.JoinVertical(
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/state/skirmish"
 	"github.com/charmbracelet/lipgloss"
 )
 
@@ -23,3 +24,16 @@ func (model *Model) GroupEditingOverview() string {
 		table,
 	)
 }
+
+// OddsView shows the chances of the Group fighting its chosen opponent, both attacking and being attacked.
+func (model *Model) OddsView() string {
+	if model.Temp.Opponent == nil {
+		return ""
+	}
+	return lipgloss.JoinVertical(
+		lipgloss.Left,
+		"",
+		skirmish.CalculateOdds(*model.Group, *model.Temp.Opponent).String(),
+		skirmish.CalculateOdds(*model.Temp.Opponent, *model.Group).String(),
+	)
+}
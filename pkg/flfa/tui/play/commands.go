@@ -0,0 +1,479 @@
+package play
+
+import (
+	"fmt"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/state/skirmish"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/tui/play/prompts"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/compositor"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/erikgeiser/promptkit/selection"
+	"github.com/erikgeiser/promptkit/textinput"
+)
+
+type ActionKind int
+
+const (
+	ActionDeploy ActionKind = iota
+	ActionActivate
+	ActionMove
+	ActionAttack
+	ActionShoot
+	ActionCast
+	ActionFinish
+	ActionEndTurn
+	ActionContinue
+	ActionUndo
+	ActionRedo
+	ActionSave
+	ActionQuit
+)
+
+// An Action is a command the active Company can give, with the Group it is given to and its target, if known.
+type Action struct {
+	Label  string
+	Kind   ActionKind
+	Group  string
+	Target string
+	Spell  string
+}
+
+type answer struct {
+	value any
+	err   error
+}
+
+type questionMsg struct {
+	question skirmish.Question
+}
+
+type notificationMsg struct {
+	notifications []skirmish.Notification
+}
+
+type workedMsg struct {
+	err   error
+	fatal bool
+}
+
+// Ask puts the Question to the players and waits for their answer. The engine calls it from the command being given,
+// which is always running outside of the bubbletea loop.
+func (model *Model) Ask(question skirmish.Question) (any, error) {
+	model.questions <- question
+	given := <-model.answers
+	return given.value, given.err
+}
+
+func (model *Model) waitForQuestion() tea.Msg {
+	return questionMsg{question: <-model.questions}
+}
+
+// Notify adds a Notification to the feed as soon as it happens, so the players can see what led up to a Question. The
+// engine may call it from inside the bubbletea loop as well as from a command being given, so it never waits: the
+// Notification is queued and the loop picks up everything queued the next time it is free.
+func (model *Model) Notify(notification skirmish.Notification) {
+	model.queued.Lock()
+	model.pending = append(model.pending, notification)
+	model.queued.Unlock()
+	select {
+	case model.notified <- struct{}{}:
+	default:
+		// The loop has already been told there is something queued
+	}
+}
+
+func (model *Model) waitForNotification() tea.Msg {
+	<-model.notified
+	model.queued.Lock()
+	defer model.queued.Unlock()
+	msg := notificationMsg{notifications: model.pending}
+	model.pending = nil
+	return msg
+}
+
+// Work gives a command to the engine outside of the bubbletea loop so that the loop is free to ask any Questions the
+// command raises.
+func (model *Model) Work(command func() error) tea.Cmd {
+	return func() tea.Msg {
+		return workedMsg{err: command()}
+	}
+}
+
+func (model *Model) begin() tea.Cmd {
+	return func() tea.Msg {
+		var err error
+		if len(model.Engine.Skirmish.Setup.Companies) == 0 {
+			err = model.Engine.Start()
+		} else {
+			err = model.Engine.Rebuild()
+		}
+		return workedMsg{err: err, fatal: true}
+	}
+}
+
+// AvailableActions lists what the active Company can do next: deploy its Groups, activate one, carry out the order of
+// its active Group, or move on. Undoing, redoing, and quitting are always listed last. While a computer-controlled
+// Company is active, the players can only let it carry on.
+func (model *Model) AvailableActions() (actions []Action) {
+	engine := model.Engine
+	current := engine.Skirmish
+	_, computer := engine.AgentFor(current.ActiveCompany)
+
+	switch {
+	case current.Ended:
+	case computer:
+		actions = append(actions, Action{Label: fmt.Sprintf("Let %s carry on", current.ActiveCompany), Kind: ActionContinue})
+	case current.Phase == skirmish.PhaseDeployment:
+		for _, group := range engine.UndeployedGroups(current.ActiveCompany) {
+			actions = append(actions, Action{Label: fmt.Sprintf("Deploy %s", group.Name), Kind: ActionDeploy, Group: group.Id})
+		}
+	case current.ActiveGroup != "":
+		switch current.ActiveOrder {
+		case skirmish.OrderMove:
+			actions = append(actions, Action{Label: "Move", Kind: ActionMove, Group: current.ActiveGroup})
+		case skirmish.OrderAttack, skirmish.OrderShoot:
+			kind := ActionAttack
+			if current.ActiveOrder == skirmish.OrderShoot {
+				kind = ActionShoot
+			}
+			for _, target := range current.ActiveTargets {
+				actions = append(actions, Action{
+					Label:  fmt.Sprintf("%s %s", current.ActiveOrder, model.GroupLabel(target)),
+					Kind:   kind,
+					Group:  current.ActiveGroup,
+					Target: target,
+				})
+			}
+		case skirmish.OrderCast:
+			actions = append(actions, Action{Label: "Cast a spell", Kind: ActionCast, Group: current.ActiveGroup})
+		}
+		actions = append(actions, Action{Label: "Finish activation", Kind: ActionFinish})
+	default:
+		for _, group := range engine.ActivatableGroups() {
+			actions = append(actions, Action{Label: fmt.Sprintf("Activate %s", group.Name), Kind: ActionActivate, Group: group.Id})
+		}
+		actions = append(actions, Action{Label: "End turn", Kind: ActionEndTurn})
+	}
+
+	if engine.CanUndo() {
+		actions = append(actions, Action{Label: "Undo", Kind: ActionUndo})
+	}
+	if engine.CanRedo() {
+		actions = append(actions, Action{Label: "Redo", Kind: ActionRedo})
+	}
+	if model.save != nil {
+		actions = append(actions, Action{Label: "Save", Kind: ActionSave})
+	}
+	return append(actions, Action{Label: "Quit", Kind: ActionQuit})
+}
+
+// GroupLabel names a Group with its Company, since Groups in different Companies may share a name.
+func (model *Model) GroupLabel(id string) string {
+	group, err := model.Engine.Group(id)
+	if err != nil {
+		return id
+	}
+	return fmt.Sprintf("%s (%s)", group.Name, model.Engine.CompanyOf(id))
+}
+
+func (model *Model) groupName(id string) string {
+	if group, err := model.Engine.Group(id); err == nil {
+		return group.Name
+	}
+	return id
+}
+
+func (model *Model) SelectActionModel() *selection.Model {
+	var labels []string
+	for _, action := range model.Actions {
+		labels = append(labels, action.Label)
+	}
+	return prompts.SelectActionModel(model.Engine.Skirmish.ActiveCompany, labels)
+}
+
+func (model *Model) SelectOrderModel() *selection.Model {
+	options, err := model.Engine.ValidActivations(model.Chosen.Group)
+	if err != nil {
+		model.Notice = err.Error()
+	}
+	return prompts.SelectOrderModel(model.groupName(model.Chosen.Group), options)
+}
+
+func (model *Model) SelectSpellModel() *selection.Model {
+	return prompts.SelectSpellModel(model.Engine.SpellList(model.Chosen.Group))
+}
+
+func (model *Model) SelectTargetModel() *selection.Model {
+	var targets []string
+	for _, target := range model.Engine.Skirmish.ActiveTargets {
+		targets = append(targets, model.GroupLabel(target))
+	}
+	return prompts.SelectTargetModel(fmt.Sprintf("Which Group should '%s' target?", model.Chosen.Spell), targets)
+}
+
+func (model *Model) GetLocationModel() *textinput.Model {
+	message := fmt.Sprintf("Where should '%s' move to?", model.groupName(model.Chosen.Group))
+	if model.Chosen.Kind == ActionDeploy {
+		message = fmt.Sprintf("Where should '%s' deploy?", model.groupName(model.Chosen.Group))
+	}
+	return prompts.GetLocationModel(message)
+}
+
+func (model *Model) UpdateFallThrough(msg tea.Msg) (cmd tea.Cmd) {
+	switch model.State {
+	case StatePlaying, StateChoosingOrder, StateChoosingSpell, StateChoosingTarget:
+		_, cmd = model.Selection.Update(msg)
+	case StateChoosingLocation:
+		_, cmd = model.TextInput.Update(msg)
+	case StateAsking:
+		_, cmd = model.Asking.Update(msg)
+	}
+
+	return cmd
+}
+
+func (model *Model) UpdateOnKeyPress(msg tea.KeyMsg) (cmd tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return tea.Quit
+	case "shift+up":
+		model.ScrollFeed(1)
+		return nil
+	case "shift+down":
+		model.ScrollFeed(-1)
+		return nil
+	}
+
+	switch model.State {
+	case StatePlaying:
+		if msg.String() == "enter" {
+			cmd = model.UpdateChooseAction()
+		}
+	case StateChoosingOrder, StateChoosingSpell, StateChoosingTarget, StateChoosingLocation:
+		switch msg.String() {
+		case "esc":
+			cmd = model.SetAndStartState(StatePlaying)
+		case "enter":
+			cmd = model.UpdateChoose()
+		}
+	case StateAsking:
+		// A trait's script is waiting on the answer, so the question cannot be skipped.
+		if msg.String() == "enter" {
+			cmd = model.UpdateAnswer()
+		}
+	case StateHandingOff:
+		if msg.String() == "enter" {
+			model.Seated = model.HandingOffTo
+			cmd = model.SetAndStartState(model.afterHandOff)
+		}
+	}
+
+	return cmd
+}
+
+func (model *Model) UpdateOnSubmodelEnded() (cmd tea.Cmd) {
+	// No submodels send an end message.
+	return cmd
+}
+
+func (model *Model) UpdateOnQuestion(msg questionMsg) tea.Cmd {
+	model.Question = &msg.question
+	return model.seatThen(msg.question.Company, StateAsking)
+}
+
+// seatThen continues play in the given state once the persona playing the Company is at the terminal, handing it off
+// to them first if someone else is. Questions are only shown after handing off, so each side's stay hidden from the
+// other.
+func (model *Model) seatThen(company string, state compositor.State) tea.Cmd {
+	persona := model.Engine.Skirmish.Players[company]
+	if persona == "" || persona == model.Seated {
+		return model.SetAndStartState(state)
+	}
+	model.HandingOffTo = persona
+	model.afterHandOff = state
+	return model.SetAndStartState(StateHandingOff)
+}
+
+func (model *Model) UpdateOnNotification(msg notificationMsg) tea.Cmd {
+	model.Feed = append(model.Feed, msg.notifications...)
+	if model.FeedScrolled > 0 {
+		model.FeedScrolled += len(msg.notifications)
+	}
+	return model.waitForNotification
+}
+
+// ScrollFeed moves the feed back through older Notifications or forward toward the newest.
+func (model *Model) ScrollFeed(lines int) {
+	model.FeedScrolled += lines
+	if maximum := len(model.Feed) - feedLines; model.FeedScrolled > maximum {
+		model.FeedScrolled = maximum
+	}
+	if model.FeedScrolled < 0 {
+		model.FeedScrolled = 0
+	}
+}
+
+func (model *Model) UpdateAnswer() tea.Cmd {
+	value, err := model.Asking.Value()
+	if err != nil {
+		// the prompt is not yet answered validly; keep asking
+		return nil
+	}
+	model.answers <- answer{value: value}
+	model.Question = nil
+	model.Asking = nil
+	model.State = StateWorking
+	return model.waitForQuestion
+}
+
+func (model *Model) UpdateOnWorked(msg workedMsg) tea.Cmd {
+	// The log is the record of what happened, so it replaces what was notified, such as after undoing a command.
+	model.Feed = model.Engine.Feed()
+	model.FeedScrolled = 0
+	if msg.err != nil {
+		if msg.fatal {
+			return model.RecordFatalError(msg.err)
+		}
+		model.Notice = msg.err.Error()
+		return model.SetAndStartState(StatePlaying)
+	}
+	// Computer-controlled Companies take their turns as soon as they are active
+	if _, computer := model.Engine.AgentFor(model.Engine.Skirmish.ActiveCompany); computer && !model.Engine.Skirmish.Ended {
+		return model.working(model.Engine.PlayAgents)
+	}
+	return model.seatThen(model.Engine.Skirmish.ActiveCompany, StatePlaying)
+}
+
+// Undoing takes back the last command a player gave along with every command computer-controlled Companies gave after
+// it; otherwise they would only give them again.
+func (model *Model) undo() error {
+	engine := model.Engine
+	for engine.CanUndo() {
+		last := engine.Skirmish.Updates[len(engine.Skirmish.Updates)-1]
+		if err := engine.Undo(); err != nil {
+			return err
+		}
+		if _, computer := engine.AgentFor(last.Company); !computer {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (model *Model) UpdateChooseAction() tea.Cmd {
+	choice, err := model.Selection.Value()
+	if err != nil {
+		return model.RecordFatalError(err)
+	}
+	model.Notice = ""
+	for _, action := range model.Actions {
+		if action.Label == choice.String {
+			model.Chosen = action
+			break
+		}
+	}
+
+	engine := model.Engine
+	chosen := model.Chosen
+	switch chosen.Kind {
+	case ActionDeploy, ActionMove:
+		return model.SetAndStartState(StateChoosingLocation)
+	case ActionActivate:
+		return model.SetAndStartState(StateChoosingOrder)
+	case ActionCast:
+		return model.SetAndStartState(StateChoosingSpell)
+	case ActionAttack:
+		return model.working(func() error {
+			_, err := engine.Attack(chosen.Target)
+			return err
+		})
+	case ActionShoot:
+		return model.working(func() error {
+			_, err := engine.Shoot(chosen.Target, "")
+			return err
+		})
+	case ActionFinish:
+		return model.working(func() error {
+			engine.FinishActivation()
+			return nil
+		})
+	case ActionEndTurn:
+		return model.working(func() error {
+			engine.EndTurn()
+			return nil
+		})
+	case ActionContinue:
+		return model.working(engine.PlayAgents)
+	case ActionUndo:
+		return model.working(model.undo)
+	case ActionRedo:
+		return model.working(engine.Redo)
+	case ActionSave:
+		if err := model.save(engine.Skirmish); err != nil {
+			model.Notice = err.Error()
+		} else {
+			model.Notice = fmt.Sprintf("Saved %s.", model.Name)
+		}
+		return model.SetAndStartState(StatePlaying)
+	case ActionQuit:
+		return model.SetAndStartState(compositor.StateDone)
+	}
+	return nil
+}
+
+// UpdateChoose handles the player choosing what the chosen action needs before it can be given to the engine.
+func (model *Model) UpdateChoose() tea.Cmd {
+	engine := model.Engine
+	chosen := model.Chosen
+
+	if model.State == StateChoosingLocation {
+		input, err := model.TextInput.Value()
+		if err != nil {
+			// the input is not yet valid; keep prompting
+			return nil
+		}
+		location, _ := prompts.ParseLocation(input)
+		if chosen.Kind == ActionDeploy {
+			return model.working(func() error { return engine.Deploy(chosen.Group, location) })
+		}
+		return model.working(func() error { return engine.Move(location) })
+	}
+
+	choice, err := model.Selection.Value()
+	if err != nil {
+		return model.RecordFatalError(err)
+	}
+	switch model.State {
+	case StateChoosingOrder:
+		options, _ := engine.ValidActivations(chosen.Group)
+		for _, option := range options {
+			if prompts.OrderLabel(option) == choice.String {
+				return model.working(func() error {
+					_, err := engine.Activate(chosen.Group, option.Order)
+					return err
+				})
+			}
+		}
+	case StateChoosingSpell:
+		for _, spell := range engine.SpellList(chosen.Group) {
+			if prompts.SpellLabel(spell) == choice.String {
+				model.Chosen.Spell = spell.Name
+				return model.SetAndStartState(StateChoosingTarget)
+			}
+		}
+	case StateChoosingTarget:
+		for _, target := range engine.Skirmish.ActiveTargets {
+			if model.GroupLabel(target) == choice.String {
+				return model.working(func() error {
+					_, err := engine.Cast(chosen.Spell, target)
+					return err
+				})
+			}
+		}
+	}
+	return model.SetAndStartState(StatePlaying)
+}
+
+func (model *Model) working(command func() error) tea.Cmd {
+	return tea.Batch(model.SetAndStartState(StateWorking), model.Work(command))
+}
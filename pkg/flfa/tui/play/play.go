@@ -0,0 +1,199 @@
+package play
+
+import (
+	"sync"
+	"time"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/state/skirmish"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/tui"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/compositor"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/prompts/dynamic"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type Model struct {
+	tui.SharedModel
+	Name   string
+	Engine *skirmish.Engine
+	// The Question is the one the players are being asked while a trait's script waits for their answer.
+	Question *skirmish.Question
+	Asking   *dynamic.Model
+	// The Actions are the commands the active Company can give right now, as listed for the player to choose from.
+	Actions []Action
+	// The Chosen action is kept while the player picks what it needs, such as the target of a spell.
+	Chosen Action
+	// The Notice is shown until the next action is chosen, such as when a command could not be given.
+	Notice string
+	// The Feed lists every Notification from the skirmish so far; the players can scroll back through it.
+	Feed         []skirmish.Notification
+	FeedScrolled int
+	// When players share the terminal, the Seated persona is the one at it; before anyone else may act, the terminal is
+	// handed off to them and play continues in the next state.
+	Seated        string
+	HandingOffTo  string
+	afterHandOff  compositor.State
+	questions     chan skirmish.Question
+	answers       chan answer
+	notified      chan struct{}
+	queued        sync.Mutex
+	pending       []skirmish.Notification
+	engineOptions []skirmish.Option
+	save          func(game *skirmish.Skirmish) error
+}
+
+const (
+	StatePlaying compositor.State = iota + 100
+	StateChoosingOrder
+	StateChoosingSpell
+	StateChoosingTarget
+	StateChoosingLocation
+	StateAsking
+	StateWorking
+	StateHandingOff
+)
+
+func (model *Model) SetAndStartState(state compositor.State) (cmd tea.Cmd) {
+	switch state {
+	case StatePlaying:
+		model.State = StatePlaying
+		model.Actions = model.AvailableActions()
+		model.Selection = model.SelectActionModel()
+		cmd = model.Selection.Init()
+	case StateChoosingOrder:
+		model.State = StateChoosingOrder
+		model.Selection = model.SelectOrderModel()
+		cmd = model.Selection.Init()
+	case StateChoosingSpell:
+		model.State = StateChoosingSpell
+		model.Selection = model.SelectSpellModel()
+		cmd = model.Selection.Init()
+	case StateChoosingTarget:
+		model.State = StateChoosingTarget
+		model.Selection = model.SelectTargetModel()
+		cmd = model.Selection.Init()
+	case StateChoosingLocation:
+		model.State = StateChoosingLocation
+		model.TextInput = model.GetLocationModel()
+		cmd = model.TextInput.Init()
+	case StateAsking:
+		model.State = StateAsking
+		model.Asking = dynamic.New(model.Question.Info)
+		cmd = model.Asking.Init()
+	case StateWorking:
+		model.State = StateWorking
+	case StateHandingOff:
+		model.State = StateHandingOff
+	case compositor.StateDone:
+		cmd = model.Done
+	case compositor.StateCancelled:
+		cmd = model.Cancelled
+	case compositor.StateReady:
+		model.State = compositor.StateReady
+		cmd = nil
+	}
+
+	return cmd
+}
+
+// NewModel returns a model for playing the skirmish with the cached module data. Whenever a trait's script asks the
+// players a Question, play pauses until it is answered.
+func NewModel(api *flfa.Api, name string, game *skirmish.Skirmish, options ...compositor.Option[*Model]) *Model {
+	model := &Model{
+		SharedModel: tui.SharedModel{
+			Api: api,
+		},
+		Name:      name,
+		questions: make(chan skirmish.Question),
+		answers:   make(chan answer),
+		notified:  make(chan struct{}, 1),
+	}
+
+	for _, option := range options {
+		option(model)
+	}
+
+	engineOptions := append(
+		api.SkirmishOptions(game.Scenario),
+		skirmish.WithAsker(model.Ask),
+		skirmish.WithNotifier(model.Notify),
+	)
+	model.Engine = skirmish.NewEngine(game, append(engineOptions, model.engineOptions...)...)
+
+	return model
+}
+
+// WithEngineOptions passes additional options to the skirmish engine, such as a hook to save the skirmish after every
+// update.
+func WithEngineOptions(options ...skirmish.Option) compositor.Option[*Model] {
+	return func(model *Model) {
+		model.engineOptions = append(model.engineOptions, options...)
+	}
+}
+
+// WithSaver lets the players save the skirmish whenever they choose to.
+func WithSaver(save func(game *skirmish.Skirmish) error) compositor.Option[*Model] {
+	return func(model *Model) {
+		model.save = save
+	}
+}
+
+func AsSubModel() compositor.Option[*Model] {
+	return func(model *Model) {
+		model.IsSubmodel = true
+	}
+}
+
+func (model *Model) Init() tea.Cmd {
+	return tea.Batch(
+		model.SetAndStartState(StateWorking),
+		model.begin(),
+		model.waitForQuestion,
+		model.waitForNotification,
+	)
+}
+
+func (model *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	// For some reason, a race condition on first update occurs
+	// Sleeping for a few milliseconds is enough to prevent it.
+	time.Sleep(time.Duration(5) * time.Millisecond)
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		model.SetSize(msg.Width, msg.Height)
+	// When a key is pressed...
+	case tea.KeyMsg:
+		cmd := model.UpdateOnKeyPress(msg)
+		if cmd != nil || model.State == StateWorking || model.State == StateHandingOff {
+			return model, cmd
+		}
+	case questionMsg:
+		return model, model.UpdateOnQuestion(msg)
+	case notificationMsg:
+		return model, model.UpdateOnNotification(msg)
+	case workedMsg:
+		return model, model.UpdateOnWorked(msg)
+	case compositor.EndMsg:
+		return model, model.UpdateOnSubmodelEnded()
+	}
+
+	// Passthru to sub-model
+	return model, model.UpdateFallThrough(msg)
+}
+
+func (model *Model) View() string {
+	switch model.State {
+	case StatePlaying, StateChoosingOrder, StateChoosingSpell, StateChoosingTarget:
+		return model.PlayView(model.Selection.View())
+	case StateChoosingLocation:
+		return model.PlayView(model.TextInput.View())
+	case StateAsking:
+		return model.PlayView(model.QuestionView())
+	case StateWorking:
+		return model.WorkingView()
+	case StateHandingOff:
+		return model.HandOffView()
+	case compositor.StateBroken:
+		return model.ViewFatalError()
+	}
+	return ""
+}
@@ -0,0 +1,103 @@
+package prompts
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/state/skirmish"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/prompts/selector"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/prompts/texter"
+	"github.com/erikgeiser/promptkit/selection"
+	"github.com/erikgeiser/promptkit/textinput"
+)
+
+func SelectAction(company string, actions []string) *selection.Selection {
+	message := "What do you want to do?"
+	if company != "" {
+		message = fmt.Sprintf("What does %s do?", company)
+	}
+	return selector.NewStringSelector(message, actions, selector.WithPageSize(8))
+}
+
+func SelectActionModel(company string, actions []string) *selection.Model {
+	return selection.NewModel(SelectAction(company, actions))
+}
+
+func OrderLabel(option skirmish.ActivationOption) string {
+	return fmt.Sprintf("%s (%d+)", option.Order, option.Target)
+}
+
+func SelectOrder(group string, options skirmish.ActivationOptions) *selection.Selection {
+	var orders []string
+	for _, option := range options {
+		orders = append(orders, OrderLabel(option))
+	}
+	return selector.NewStringSelector(
+		fmt.Sprintf("Which order should '%s' activate to follow?", group),
+		orders,
+		selector.WithPageSize(5),
+	)
+}
+
+func SelectOrderModel(group string, options skirmish.ActivationOptions) *selection.Model {
+	return selection.NewModel(SelectOrder(group, options))
+}
+
+func SpellLabel(spell data.Spell) string {
+	return fmt.Sprintf("%s (%d+)", spell.Name, spell.Check)
+}
+
+func SelectSpell(spells []data.Spell) *selection.Selection {
+	var names []string
+	for _, spell := range spells {
+		names = append(names, SpellLabel(spell))
+	}
+	return selector.NewStringSelector("Which spell should be cast?", names, selector.WithPageSize(5))
+}
+
+func SelectSpellModel(spells []data.Spell) *selection.Model {
+	return selection.NewModel(SelectSpell(spells))
+}
+
+func SelectTarget(message string, targets []string) *selection.Selection {
+	return selector.NewStringSelector(message, targets, selector.WithPageSize(5))
+}
+
+func SelectTargetModel(message string, targets []string) *selection.Model {
+	return selection.NewModel(SelectTarget(message, targets))
+}
+
+// ParseLocation reads a location entered as two whole numbers of inches, like "12, 4".
+func ParseLocation(input string) (location skirmish.Location, ok bool) {
+	coordinates := strings.FieldsFunc(input, func(character rune) bool {
+		return character == ',' || character == ' ' || character == '(' || character == ')'
+	})
+	if len(coordinates) != 2 {
+		return location, false
+	}
+	x, xErr := strconv.Atoi(coordinates[0])
+	y, yErr := strconv.Atoi(coordinates[1])
+	if xErr != nil || yErr != nil {
+		return location, false
+	}
+	return skirmish.Location{X: x, Y: y}, true
+}
+
+func GetLocation(message string) *textinput.TextInput {
+	return texter.NewValidatableWithCustomMessage(
+		message,
+		func(input string) bool {
+			_, ok := ParseLocation(input)
+			return ok
+		},
+		"Enter the location as two numbers of inches across and up the battlefield, like 12, 4.",
+		texter.WithPlaceholder("x, y"),
+		texter.WithInputWidth(12),
+	)
+}
+
+func GetLocationModel(message string) *textinput.Model {
+	return textinput.NewModel(GetLocation(message))
+}
@@ -0,0 +1,161 @@
+package play
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/state/skirmish"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// How many Notifications the feed shows at once.
+const feedLines = 8
+
+func (model *Model) PlayView(prompt string) string {
+	sections := []string{model.HeaderView(), "", model.GroupsView(), "", model.FeedView(), ""}
+	if model.Notice != "" {
+		sections = append(sections, lipgloss.NewStyle().Foreground(lipgloss.Color("166")).Render(model.Notice), "")
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, append(sections, prompt)...)
+}
+
+// The WorkingView does not show the state of the skirmish, since the engine is busy changing it.
+func (model *Model) WorkingView() string {
+	title := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("32")).Render(model.Name)
+	return lipgloss.JoinVertical(lipgloss.Left, fmt.Sprintf("Playing %s", title), "", "Resolving...")
+}
+
+// The HandOffView hides the skirmish until the player it is being handed to is ready for it.
+func (model *Model) HandOffView() string {
+	persona := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("5")).Render(model.HandingOffTo)
+	message := fmt.Sprintf("Pass the terminal to %s, then press enter.", model.HandingOffTo)
+	if model.afterHandOff == StateAsking {
+		message = fmt.Sprintf("%s has a question to answer; pass the terminal to them, then press enter.", model.HandingOffTo)
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, fmt.Sprintf("%s's turn", persona), "", message)
+}
+
+func (model *Model) HeaderView() string {
+	current := model.Engine.Skirmish
+
+	title := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("32")).Render(model.Name)
+	var progress string
+	switch {
+	case current.Ended && current.Winner != "":
+		progress = fmt.Sprintf("won by %s on turn %d", current.Winner, current.Turn)
+	case current.Ended:
+		progress = fmt.Sprintf("a draw on turn %d", current.Turn)
+	case current.Phase == skirmish.PhaseDeployment:
+		progress = fmt.Sprintf("deployment, %s deploying", current.ActiveCompany)
+	case current.ActiveGroup != "":
+		progress = fmt.Sprintf("turn %d, %s active with '%s' ordered to %s", current.Turn, current.ActiveCompany, model.groupName(current.ActiveGroup), current.ActiveOrder)
+	default:
+		progress = fmt.Sprintf("turn %d, %s active", current.Turn, current.ActiveCompany)
+	}
+
+	header := fmt.Sprintf("Playing %s: %s", title, progress)
+	if len(current.Scores) > 0 {
+		var scores []string
+		for _, company := range current.Companies {
+			scores = append(scores, fmt.Sprintf("%s %d", company.Name, current.Scores[company.Name]))
+		}
+		header = lipgloss.JoinVertical(lipgloss.Left, header, fmt.Sprintf("Scores: %s", strings.Join(scores, ", ")))
+	}
+	return header
+}
+
+func (model *Model) GroupsView() string {
+	engine := model.Engine
+	var table strings.Builder
+	table.WriteString(fmt.Sprintf("%-24s %-20s %-10s %-8s %-4s %-9s %s\n", "Group", "Company", "Status", "Morale", "FS", "Activated", "Location"))
+	for _, groupState := range engine.Skirmish.GroupStates {
+		group, err := engine.Group(groupState.Id)
+		if err != nil {
+			continue
+		}
+		location := "-"
+		if groupState.Location != nil {
+			location = groupState.Location.String()
+		}
+		activated := ""
+		if groupState.Activated {
+			activated = "yes"
+		}
+		table.WriteString(fmt.Sprintf(
+			"%-24s %-20s %-10s %-8s %-4d %-9s %s\n",
+			group.Name,
+			groupState.Company,
+			groupState.Status,
+			groupState.Morale,
+			group.FightingStrength.Current,
+			activated,
+			location,
+		))
+	}
+	return strings.TrimRight(table.String(), "\n")
+}
+
+// The FeedView shows the most recent Notifications, or older ones if the players have scrolled back, each tagged with
+// the turn it happened on and the Group it concerns.
+func (model *Model) FeedView() string {
+	if len(model.Feed) == 0 {
+		return lipgloss.NewStyle().Faint(true).Render("Nothing has happened yet.")
+	}
+	last := len(model.Feed) - model.FeedScrolled
+	first := last - feedLines
+	if first < 0 {
+		first = 0
+	}
+
+	tag := lipgloss.NewStyle().Faint(true)
+	styles := map[skirmish.NotificationKind]lipgloss.Style{
+		skirmish.NotificationTrait:   lipgloss.NewStyle().Foreground(lipgloss.Color("11")),
+		skirmish.NotificationRoll:    lipgloss.NewStyle(),
+		skirmish.NotificationState:   lipgloss.NewStyle().Foreground(lipgloss.Color("32")),
+		skirmish.NotificationMessage: lipgloss.NewStyle().Bold(true),
+	}
+	var lines []string
+	for _, notification := range model.Feed[first:last] {
+		label := fmt.Sprintf("Turn %d", notification.Turn)
+		if notification.Group != "" {
+			label = fmt.Sprintf("%s, %s", label, model.groupName(notification.Group))
+		} else if notification.Company != "" {
+			label = fmt.Sprintf("%s, %s", label, notification.Company)
+		}
+		lines = append(lines, fmt.Sprintf("%s %s", tag.Render(fmt.Sprintf("[%s]", label)), styles[notification.Kind].Render(notification.Message)))
+	}
+	if model.FeedScrolled > 0 {
+		lines = append(lines, tag.Render(fmt.Sprintf("(%d newer; shift+↓ to scroll forward)", model.FeedScrolled)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// The QuestionView tells the players which trait is asking and what happened to make it ask before showing the prompt.
+func (model *Model) QuestionView() string {
+	question := model.Question
+	context := fmt.Sprintf("'%s' of '%s' (%s) asks", question.Trait, model.groupName(question.Owner), question.Company)
+	if question.Group != question.Owner {
+		context = fmt.Sprintf("%s for '%s'", context, model.groupName(question.Group))
+	}
+	if question.Times > 1 {
+		context = fmt.Sprintf("%s (%d of %d)", context, question.Asked, question.Times)
+	}
+	lines := []string{lipgloss.NewStyle().Foreground(lipgloss.Color("11")).Render(context)}
+
+	if len(question.Result) > 0 {
+		var keys []string
+		for key := range question.Result {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var details []string
+		for _, key := range keys {
+			details = append(details, fmt.Sprintf("%s: %v", key, question.Result[key]))
+		}
+		detail := lipgloss.NewStyle().PaddingLeft(2).Faint(true)
+		lines = append(lines, detail.Render(fmt.Sprintf("%s (%s)", question.Event, strings.Join(details, ", "))))
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left, append(lines, "", model.Asking.View())...)
+}
@@ -0,0 +1,82 @@
+package replay
+
+import (
+	"strconv"
+
+	"github.com/FlagrantGarden/flfa/pkg/tympan/compositor"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func (model *Model) LastTurn() int {
+	steps := model.Replay.Steps
+	if len(steps) == 0 {
+		return 0
+	}
+	return steps[len(steps)-1].Update.Turn
+}
+
+func (model *Model) UpdateFallThrough(msg tea.Msg) (cmd tea.Cmd) {
+	switch model.State {
+	case StateJumping:
+		_, cmd = model.TextInput.Update(msg)
+	}
+
+	return cmd
+}
+
+func (model *Model) UpdateOnKeyPress(msg tea.KeyMsg) (cmd tea.Cmd) {
+	if msg.String() == "ctrl+c" {
+		return tea.Quit
+	}
+
+	switch model.State {
+	case StateReplaying:
+		model.Notice = ""
+		var err error
+		switch msg.String() {
+		case "right", "l", "n", " ":
+			err = model.Replay.Forward()
+		case "left", "h", "p":
+			err = model.Replay.Back()
+		case "home":
+			err = model.Replay.GoTo(0)
+		case "end":
+			err = model.Replay.GoTo(len(model.Replay.Steps))
+		case "t":
+			cmd = model.SetAndStartState(StateJumping)
+		case "q", "esc":
+			cmd = model.SetAndStartState(compositor.StateDone)
+		}
+		if err != nil {
+			model.Notice = err.Error()
+		}
+	case StateJumping:
+		switch msg.String() {
+		case "esc":
+			cmd = model.SetAndStartState(StateReplaying)
+		case "enter":
+			cmd = model.UpdateJumpToTurn()
+		}
+	}
+
+	return cmd
+}
+
+func (model *Model) UpdateOnSubmodelEnded() (cmd tea.Cmd) {
+	// No submodels send an end message.
+	return cmd
+}
+
+func (model *Model) UpdateJumpToTurn() (cmd tea.Cmd) {
+	input, err := model.TextInput.Value()
+	if err != nil {
+		// the input is not yet valid; keep prompting
+		return nil
+	}
+	turn, _ := strconv.Atoi(input)
+	if err := model.Replay.JumpToTurn(turn); err != nil {
+		model.Notice = err.Error()
+	}
+
+	return model.SetAndStartState(StateReplaying)
+}
@@ -0,0 +1,25 @@
+package prompts
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/FlagrantGarden/flfa/pkg/tympan/prompts/texter"
+	"github.com/erikgeiser/promptkit/textinput"
+)
+
+func GetTurn(lastTurn int) *textinput.TextInput {
+	return texter.NewValidatableWithCustomMessage(
+		"Which turn do you want to jump to?",
+		func(input string) bool {
+			turn, err := strconv.Atoi(input)
+			return err == nil && turn >= 0 && turn <= lastTurn
+		},
+		fmt.Sprintf("Turn must be a number from 0 to %d.", lastTurn),
+		texter.WithInputWidth(10),
+	)
+}
+
+func GetTurnModel(lastTurn int) *textinput.Model {
+	return textinput.NewModel(GetTurn(lastTurn))
+}
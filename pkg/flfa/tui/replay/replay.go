@@ -0,0 +1,115 @@
+package replay
+
+import (
+	"time"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/state/skirmish"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/tui"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/tui/replay/prompts"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/compositor"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type Model struct {
+	tui.SharedModel
+	Name   string
+	Replay *skirmish.Replay
+	// The Notice is shown until the next key is pressed, such as when stepping past the end of the replay.
+	Notice string
+}
+
+const (
+	StateReplaying compositor.State = iota + 100
+	StateJumping
+)
+
+func (model *Model) SetAndStartState(state compositor.State) (cmd tea.Cmd) {
+	switch state {
+	case StateReplaying:
+		model.State = StateReplaying
+	case StateJumping:
+		model.State = StateJumping
+		model.TextInput = prompts.GetTurnModel(model.LastTurn())
+		cmd = model.TextInput.Init()
+	case compositor.StateDone:
+		cmd = model.Done
+	case compositor.StateCancelled:
+		cmd = model.Cancelled
+	case compositor.StateReady:
+		model.State = compositor.StateReady
+		cmd = nil
+	}
+
+	return cmd
+}
+
+func NewModel(api *flfa.Api, name string, replay *skirmish.Replay, options ...compositor.Option[*Model]) *Model {
+	model := &Model{
+		SharedModel: tui.SharedModel{
+			Api: api,
+		},
+		Name:   name,
+		Replay: replay,
+	}
+
+	for _, option := range options {
+		option(model)
+	}
+
+	return model
+}
+
+// WithTurn starts the replay at the beginning of the given turn instead of before the first deployment.
+func WithTurn(turn int) compositor.Option[*Model] {
+	return func(model *Model) {
+		if turn > 0 {
+			if err := model.Replay.JumpToTurn(turn); err != nil {
+				model.Notice = err.Error()
+			}
+		}
+	}
+}
+
+func AsSubModel() compositor.Option[*Model] {
+	return func(model *Model) {
+		model.IsSubmodel = true
+	}
+}
+
+func (model *Model) Init() tea.Cmd {
+	return model.SetAndStartState(StateReplaying)
+}
+
+func (model *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	// For some reason, a race condition on first update occurs
+	// Sleeping for a few milliseconds is enough to prevent it.
+	time.Sleep(time.Duration(5) * time.Millisecond)
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		model.SetSize(msg.Width, msg.Height)
+	// When a key is pressed...
+	case tea.KeyMsg:
+		cmd := model.UpdateOnKeyPress(msg)
+		if cmd != nil || model.State == StateReplaying {
+			return model, cmd
+		}
+	case compositor.EndMsg:
+		return model, model.UpdateOnSubmodelEnded()
+	}
+
+	// Passthru to sub-model
+	return model, model.UpdateFallThrough(msg)
+}
+
+func (model *Model) View() string {
+	switch model.State {
+	case StateReplaying:
+		return model.ReplayView()
+	case StateJumping:
+		return model.TextInput.View()
+	case compositor.StateBroken:
+		return model.ViewFatalError()
+	}
+	return ""
+}
@@ -0,0 +1,126 @@
+package replay
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa/state/skirmish"
+	"github.com/charmbracelet/lipgloss"
+)
+
+func (model *Model) ReplayView() string {
+	return lipgloss.JoinVertical(
+		lipgloss.Left,
+		model.HeaderView(),
+		"",
+		model.StepView(),
+		"",
+		model.GroupsView(),
+		"",
+		model.FooterView(),
+	)
+}
+
+func (model *Model) HeaderView() string {
+	replay := model.Replay
+	current := replay.Skirmish()
+
+	title := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("32")).Render(model.Name)
+	var progress string
+	switch {
+	case current.Ended && current.Winner != "":
+		progress = fmt.Sprintf("won by %s on turn %d", current.Winner, current.Turn)
+	case current.Ended:
+		progress = fmt.Sprintf("a draw on turn %d", current.Turn)
+	case current.Phase == skirmish.PhaseDeployment:
+		progress = fmt.Sprintf("deployment, %s deploying", current.ActiveCompany)
+	default:
+		progress = fmt.Sprintf("turn %d, %s active", current.Turn, current.ActiveCompany)
+	}
+
+	header := fmt.Sprintf("Replaying %s (step %d of %d): %s", title, replay.Position, len(replay.Steps), progress)
+	if len(current.Scores) > 0 {
+		var scores []string
+		for _, company := range current.Companies {
+			scores = append(scores, fmt.Sprintf("%s %d", company.Name, current.Scores[company.Name]))
+		}
+		header = lipgloss.JoinVertical(lipgloss.Left, header, fmt.Sprintf("Scores: %s", strings.Join(scores, ", ")))
+	}
+	return header
+}
+
+// StepView shows what happened in the current step: the command given, every die rolled, and every trait triggered.
+func (model *Model) StepView() string {
+	step := model.Replay.Current()
+	if step == nil {
+		step = &model.Replay.Opening
+		if len(step.Rolls) == 0 && len(step.Triggers) == 0 {
+			return "The skirmish has not started yet."
+		}
+	}
+
+	var lines []string
+	if step.Number == 0 {
+		lines = append(lines, "Starting the skirmish")
+	} else {
+		lines = append(lines, fmt.Sprintf("%d. %s", step.Number, model.Replay.Engine.Describe(step.Update)))
+	}
+	if step.Diverged {
+		warning := lipgloss.NewStyle().Foreground(lipgloss.Color("166"))
+		lines = append(lines, warning.Render("This step replayed differently than it was played; has the module data changed?"))
+	}
+
+	detail := lipgloss.NewStyle().PaddingLeft(2).Faint(true)
+	for _, roll := range step.Rolls {
+		if len(roll.Rerolled) > 0 {
+			lines = append(lines, detail.Render(fmt.Sprintf("%s: rerolled %v into %v", roll.Source, roll.Previous, roll.Dice)))
+			continue
+		}
+		lines = append(lines, detail.Render(fmt.Sprintf("%s: rolled %dd%d, %v", roll.Source, len(roll.Dice), roll.Size, roll.Dice)))
+	}
+	trait := lipgloss.NewStyle().PaddingLeft(2).Foreground(lipgloss.Color("11"))
+	for _, trigger := range step.Triggers {
+		appliedTo := ""
+		if trigger.Group != trigger.Owner {
+			appliedTo = fmt.Sprintf(" for '%s'", trigger.Group)
+		}
+		lines = append(lines, trait.Render(fmt.Sprintf("%s: '%s' of '%s'%s", trigger.Event, trigger.Trait, trigger.Owner, appliedTo)))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (model *Model) GroupsView() string {
+	engine := model.Replay.Engine
+	var table strings.Builder
+	table.WriteString(fmt.Sprintf("%-24s %-20s %-10s %-8s %-4s %s\n", "Group", "Company", "Status", "Morale", "FS", "Location"))
+	for _, groupState := range engine.Skirmish.GroupStates {
+		group, err := engine.Group(groupState.Id)
+		if err != nil {
+			continue
+		}
+		location := "-"
+		if groupState.Location != nil {
+			location = groupState.Location.String()
+		}
+		table.WriteString(fmt.Sprintf(
+			"%-24s %-20s %-10s %-8s %-4d %s\n",
+			group.Name,
+			groupState.Company,
+			groupState.Status,
+			groupState.Morale,
+			group.FightingStrength.Current,
+			location,
+		))
+	}
+	return strings.TrimRight(table.String(), "\n")
+}
+
+func (model *Model) FooterView() string {
+	help := lipgloss.NewStyle().Faint(true).Render("←/→ step back/forward • t jump to turn • home/end start/finish • q quit")
+	if model.Notice == "" {
+		return help
+	}
+	notice := lipgloss.NewStyle().Foreground(lipgloss.Color("166")).Render(model.Notice)
+	return lipgloss.JoinVertical(lipgloss.Left, notice, help)
+}
@@ -0,0 +1,120 @@
+package seating
+
+import (
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/tui/seating/prompts"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/compositor"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/utils"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/erikgeiser/promptkit/selection"
+)
+
+// Seated reports whether every player has chosen both their persona and their Company.
+func (model *Model) Seated() bool {
+	return len(model.Seats) == model.Count && model.Seats[model.Count-1].Company.Name != ""
+}
+
+// AvailablePersonas lists the personas nobody has chosen yet; each player needs their own.
+func (model *Model) AvailablePersonas() (names []string) {
+	var taken []string
+	for _, seat := range model.Seats {
+		taken = append(taken, seat.Player.Name)
+	}
+	for _, candidate := range model.Api.Cache.Players {
+		if !utils.Contains(taken, candidate.Name) {
+			names = append(names, candidate.Name)
+		}
+	}
+	return names
+}
+
+// AvailableCompanies lists the companies the player in the current seat can play: their persona's own and those from
+// modules, leaving out any chosen already, since no two Companies in a skirmish may share a name.
+func (model *Model) AvailableCompanies() (companies []data.Company) {
+	var taken []string
+	for _, seat := range model.Seats {
+		taken = append(taken, seat.Company.Name)
+	}
+	current := model.Seats[len(model.Seats)-1]
+	for _, company := range append(append([]data.Company{}, current.Player.Data.Companies...), model.Api.Cache.Companies...) {
+		if !utils.Contains(taken, company.Name) {
+			companies = append(companies, company)
+			taken = append(taken, company.Name)
+		}
+	}
+	return companies
+}
+
+func (model *Model) ChoosePersonaModel() *selection.Model {
+	return prompts.ChoosePersonaModel(len(model.Seats)+1, model.AvailablePersonas())
+}
+
+func (model *Model) ChooseCompanyModel() *selection.Model {
+	var names []string
+	for _, company := range model.AvailableCompanies() {
+		names = append(names, company.Name)
+	}
+	return prompts.ChooseCompanyModel(model.Seats[len(model.Seats)-1].Player.Name, names)
+}
+
+func (model *Model) UpdateFallThrough(msg tea.Msg) (cmd tea.Cmd) {
+	switch model.State {
+	case StateChoosingPersona, StateChoosingCompany:
+		_, cmd = model.Selection.Update(msg)
+	}
+
+	return cmd
+}
+
+func (model *Model) UpdateOnKeyPress(msg tea.KeyMsg) (cmd tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		cmd = tea.Quit
+	case "esc":
+		cmd = model.SetAndStartState(compositor.StateCancelled)
+	case "enter":
+		switch model.State {
+		case StateChoosingPersona:
+			cmd = model.UpdateChoosePersona()
+		case StateChoosingCompany:
+			cmd = model.UpdateChooseCompany()
+		}
+	}
+	return cmd
+}
+
+func (model *Model) UpdateOnSubmodelEnded() (cmd tea.Cmd) {
+	// No submodels send an end message.
+	return cmd
+}
+
+func (model *Model) UpdateChoosePersona() tea.Cmd {
+	choice, err := model.Selection.Value()
+	if err != nil {
+		return model.RecordFatalError(err)
+	}
+	for index := range model.Api.Cache.Players {
+		if model.Api.Cache.Players[index].Name == choice.String {
+			model.Seats = append(model.Seats, Seat{Player: &model.Api.Cache.Players[index]})
+			break
+		}
+	}
+	return model.SetAndStartState(StateChoosingCompany)
+}
+
+func (model *Model) UpdateChooseCompany() tea.Cmd {
+	choice, err := model.Selection.Value()
+	if err != nil {
+		return model.RecordFatalError(err)
+	}
+	for _, company := range model.AvailableCompanies() {
+		if company.Name == choice.String {
+			model.Seats[len(model.Seats)-1].Company = company
+			break
+		}
+	}
+	if model.Seated() {
+		return model.SetAndStartState(compositor.StateDone)
+	}
+	return model.SetAndStartState(StateChoosingPersona)
+}
@@ -0,0 +1,32 @@
+package prompts
+
+import (
+	"fmt"
+
+	"github.com/FlagrantGarden/flfa/pkg/tympan/prompts/selector"
+	"github.com/erikgeiser/promptkit/selection"
+)
+
+func ChoosePersona(seat int, personas []string) *selection.Selection {
+	return selector.NewStringSelector(
+		fmt.Sprintf("Player %d, which persona are you playing as?", seat),
+		personas,
+		selector.WithPageSize(5),
+	)
+}
+
+func ChoosePersonaModel(seat int, personas []string) *selection.Model {
+	return selection.NewModel(ChoosePersona(seat, personas))
+}
+
+func ChooseCompany(persona string, companies []string) *selection.Selection {
+	return selector.NewStringSelector(
+		fmt.Sprintf("Which company is %s playing?", persona),
+		companies,
+		selector.WithPageSize(8),
+	)
+}
+
+func ChooseCompanyModel(persona string, companies []string) *selection.Model {
+	return selection.NewModel(ChooseCompany(persona, companies))
+}
@@ -0,0 +1,117 @@
+package seating
+
+import (
+	"time"
+
+	"github.com/FlagrantGarden/flfa/pkg/flfa"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/data"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/state/player"
+	"github.com/FlagrantGarden/flfa/pkg/flfa/tui"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/compositor"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// The seating model has the players sharing a terminal each choose the persona they play as and the Company they
+// play, one after the other, before a hot-seat skirmish.
+type Model struct {
+	tui.SharedModel
+	// The Seats are filled in order; the last one may only have a Player while its Company is being chosen.
+	Seats []Seat
+	// How many players are sharing the terminal
+	Count int
+}
+
+type Seat struct {
+	Player  *player.Player
+	Company data.Company
+}
+
+const (
+	StateChoosingPersona compositor.State = iota + 100
+	StateChoosingCompany
+)
+
+func (model *Model) SetAndStartState(state compositor.State) (cmd tea.Cmd) {
+	switch state {
+	case StateChoosingPersona:
+		model.State = StateChoosingPersona
+		model.Selection = model.ChoosePersonaModel()
+		cmd = model.Selection.Init()
+	case StateChoosingCompany:
+		model.State = StateChoosingCompany
+		model.Selection = model.ChooseCompanyModel()
+		cmd = model.Selection.Init()
+	case compositor.StateDone:
+		cmd = model.Done
+	case compositor.StateCancelled:
+		cmd = model.Cancelled
+	case compositor.StateReady:
+		model.State = compositor.StateReady
+		cmd = nil
+	}
+
+	return cmd
+}
+
+func NewModel(api *flfa.Api, options ...compositor.Option[*Model]) *Model {
+	model := &Model{
+		SharedModel: tui.SharedModel{
+			Api: api,
+		},
+		Count: 2,
+	}
+
+	for _, option := range options {
+		option(model)
+	}
+
+	return model
+}
+
+// WithCount sets how many players are sharing the terminal; there are two unless set otherwise.
+func WithCount(count int) compositor.Option[*Model] {
+	return func(model *Model) {
+		model.Count = count
+	}
+}
+
+func AsSubModel() compositor.Option[*Model] {
+	return func(model *Model) {
+		model.IsSubmodel = true
+	}
+}
+
+func (model *Model) Init() tea.Cmd {
+	return model.SetAndStartState(StateChoosingPersona)
+}
+
+func (model *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	// For some reason, a race condition on first update occurs
+	// Sleeping for a few milliseconds is enough to prevent it.
+	time.Sleep(time.Duration(5) * time.Millisecond)
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		model.SetSize(msg.Width, msg.Height)
+	// When a key is pressed...
+	case tea.KeyMsg:
+		cmd := model.UpdateOnKeyPress(msg)
+		if cmd != nil {
+			return model, cmd
+		}
+	case compositor.EndMsg:
+		return model, model.UpdateOnSubmodelEnded()
+	}
+
+	// Passthru to sub-model
+	return model, model.UpdateFallThrough(msg)
+}
+
+func (model *Model) View() string {
+	switch model.State {
+	case StateChoosingPersona, StateChoosingCompany:
+		return model.SeatingView()
+	case compositor.StateBroken:
+		return model.ViewFatalError()
+	}
+	return ""
+}
@@ -0,0 +1,23 @@
+package seating
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// The SeatingView lists who has sat down so far above the current prompt.
+func (model *Model) SeatingView() string {
+	lines := []string{lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("32")).Render("Hot-seat skirmish"), ""}
+	for index, seat := range model.Seats {
+		company := "choosing a company..."
+		if seat.Company.Name != "" {
+			company = seat.Company.Name
+		}
+		lines = append(lines, fmt.Sprintf("Player %d: %s playing %s", index+1, seat.Player.Name, company))
+	}
+	if len(model.Seats) > 0 {
+		lines = append(lines, "")
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, append(lines, model.Selection.View())...)
+}
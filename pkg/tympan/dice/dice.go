@@ -0,0 +1,287 @@
+package dice
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/d5/tengo/v2"
+)
+
+// A Roller is the single source of dice rolls for a Tympan application. Every roll made with a Roller comes from its
+// seed, so two Rollers created with the same seed and asked for the same rolls in the same order always produce the
+// same results. This is what makes games replayable and tests reproducible: save the seed, and the rolls can be made
+// again.
+type Roller struct {
+	// The Seed the Roller was created or last reseeded with.
+	Seed int64
+	// If specified, the Observer is called with every Roll the Roller makes, including rerolls. This is how an
+	// application keeps a record of where its rolls came from.
+	Observer func(roll Roll)
+	random   *rand.Rand
+}
+
+// A Roll records a set of dice rolled at once, all of the same Size, and the Source which asked for them. When dice
+// are rerolled, the Roll records the Dice after rerolling, which of them were Rerolled by index, and what those dice
+// were Previously.
+type Roll struct {
+	Source   string
+	Size     int
+	Dice     []int
+	Rerolled []int
+	Previous []int
+}
+
+// The selections for choosing which dice to reroll
+const (
+	SelectHighest = "highest"
+	SelectLowest  = "lowest"
+	SelectAll     = "all"
+)
+
+// NewRoller returns a Roller using the specified seed; if the seed is zero, the current time is used instead. The seed
+// actually used is always available from the Roller's Seed field.
+func NewRoller(seed int64) *Roller {
+	roller := &Roller{}
+	roller.Reseed(seed)
+	return roller
+}
+
+// Reseed restarts the Roller's sequence of rolls from the specified seed, or from the current time if the seed is zero.
+func (roller *Roller) Reseed(seed int64) {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	roller.Seed = seed
+	roller.random = rand.New(rand.NewSource(seed))
+}
+
+func (roller *Roller) die(size int) int {
+	return roller.random.Intn(size) + 1
+}
+
+// Roll rolls count dice of the specified size and returns the results, recording the source which asked for them.
+func (roller *Roller) Roll(source string, count int, size int) (rolls []int) {
+	if size < 1 {
+		return rolls
+	}
+	for index := 0; index < count; index++ {
+		rolls = append(rolls, roller.die(size))
+	}
+	roller.observe(Roll{Source: source, Size: size, Dice: rolls})
+	return rolls
+}
+
+// D6 rolls a single six-sided die.
+func (roller *Roller) D6(source string) int {
+	return roller.Roll(source, 1, 6)[0]
+}
+
+// TwoD6 rolls two six-sided dice, as for tests.
+func (roller *Roller) TwoD6(source string) []int {
+	return roller.Roll(source, 2, 6)
+}
+
+// ThreeD6 rolls three six-sided dice, as for a Captain's trait.
+func (roller *Roller) ThreeD6(source string) []int {
+	return roller.Roll(source, 3, 6)
+}
+
+// Reroll returns a copy of the rolls with the dice at the specified indexes rolled again. Indexes outside of the rolls
+// are ignored. The reroll is recorded as a single Roll, noting which dice were rerolled and what they were before.
+func (roller *Roller) Reroll(source string, rolls []int, size int, indexes ...int) []int {
+	rerolled := append([]int{}, rolls...)
+	record := Roll{Source: source, Size: size}
+	for _, index := range indexes {
+		if index < 0 || index >= len(rerolled) {
+			continue
+		}
+		record.Rerolled = append(record.Rerolled, index)
+		record.Previous = append(record.Previous, rerolled[index])
+		rerolled[index] = roller.die(size)
+	}
+	if len(record.Rerolled) > 0 {
+		record.Dice = rerolled
+		roller.observe(record)
+	}
+	return rerolled
+}
+
+// Select returns the indexes of the dice to reroll for a selection: the single highest or lowest die or all of them.
+// Only the dice for which eligible returns true may be selected; if eligible is nil, every die may be.
+func Select(rolls []int, selection string, eligible func(roll int) bool) (indexes []int, err error) {
+	chosen := -1
+	for index, roll := range rolls {
+		if eligible != nil && !eligible(roll) {
+			continue
+		}
+		switch strings.ToLower(selection) {
+		case SelectAll:
+			indexes = append(indexes, index)
+		case SelectHighest:
+			if chosen < 0 || roll > rolls[chosen] {
+				chosen = index
+			}
+		case SelectLowest:
+			if chosen < 0 || roll < rolls[chosen] {
+				chosen = index
+			}
+		default:
+			return indexes, fmt.Errorf("invalid selection '%s'; must be one of: %s, %s, %s", selection, SelectHighest, SelectLowest, SelectAll)
+		}
+	}
+	if chosen >= 0 {
+		indexes = append(indexes, chosen)
+	}
+	return indexes, nil
+}
+
+func Sum(rolls []int) (total int) {
+	for _, roll := range rolls {
+		total += roll
+	}
+	return total
+}
+
+func (roller *Roller) observe(roll Roll) {
+	if roller.Observer != nil {
+		roller.Observer(roll)
+	}
+}
+
+// Module returns the Roller as the attributes of a native tengo module, so scripts roll the same dice as the
+// application. The source function, if specified, is called for every roll a script makes to record where it came
+// from; otherwise script rolls are recorded as coming from "script".
+//
+// The module provides:
+//
+//     d(size)                 // a single die of any size
+//     roll(count, size)       // an array of count dice of the same size
+//     d6(), twoD6(), threeD6()
+//     sum(rolls)              // the total of an array of rolls
+//     testVs(target)          // whether 2d6 meets or beats the target
+//     countHits(rolls, target)
+//     Reroll(selection, result)
+//
+// Reroll takes a result map with a "rolls" array, like the one passed to trait scripts, and rerolls the "highest",
+// "lowest", or "all" of its dice, returning the updated result.
+func (roller *Roller) Module(source func() string) map[string]tengo.Object {
+	sourceOf := func() string {
+		if source == nil {
+			return "script"
+		}
+		return source()
+	}
+	function := func(name string, value tengo.CallableFunc) tengo.Object {
+		return &tengo.UserFunction{Name: name, Value: value}
+	}
+	rollsObject := func(rolls []int) tengo.Object {
+		values := make([]tengo.Object, len(rolls))
+		for index, roll := range rolls {
+			values[index] = &tengo.Int{Value: int64(roll)}
+		}
+		return &tengo.Array{Value: values}
+	}
+
+	return map[string]tengo.Object{
+		"d": function("d", func(arguments ...tengo.Object) (tengo.Object, error) {
+			if len(arguments) != 1 {
+				return nil, tengo.ErrWrongNumArguments
+			}
+			size, ok := tengo.ToInt(arguments[0])
+			if !ok || size < 1 {
+				return tengo.UndefinedValue, nil
+			}
+			return &tengo.Int{Value: int64(roller.Roll(sourceOf(), 1, size)[0])}, nil
+		}),
+		"roll": function("roll", func(arguments ...tengo.Object) (tengo.Object, error) {
+			if len(arguments) != 2 {
+				return nil, tengo.ErrWrongNumArguments
+			}
+			count, ok := tengo.ToInt(arguments[0])
+			if !ok {
+				return &tengo.Error{Value: &tengo.String{Value: "count must be an integer or convertible to an integer"}}, nil
+			}
+			size, ok := tengo.ToInt(arguments[1])
+			if !ok || size < 1 {
+				return &tengo.Error{Value: &tengo.String{Value: "size must be a positive integer or convertible to one"}}, nil
+			}
+			return rollsObject(roller.Roll(sourceOf(), count, size)), nil
+		}),
+		"d6": function("d6", func(arguments ...tengo.Object) (tengo.Object, error) {
+			return &tengo.Int{Value: int64(roller.D6(sourceOf()))}, nil
+		}),
+		"twoD6": function("twoD6", func(arguments ...tengo.Object) (tengo.Object, error) {
+			return rollsObject(roller.TwoD6(sourceOf())), nil
+		}),
+		"threeD6": function("threeD6", func(arguments ...tengo.Object) (tengo.Object, error) {
+			return rollsObject(roller.ThreeD6(sourceOf())), nil
+		}),
+		"sum": function("sum", func(arguments ...tengo.Object) (tengo.Object, error) {
+			if len(arguments) != 1 {
+				return nil, tengo.ErrWrongNumArguments
+			}
+			return &tengo.Int{Value: int64(Sum(toRolls(arguments[0])))}, nil
+		}),
+		"testVs": function("testVs", func(arguments ...tengo.Object) (tengo.Object, error) {
+			if len(arguments) != 1 {
+				return nil, tengo.ErrWrongNumArguments
+			}
+			target, _ := tengo.ToInt(arguments[0])
+			if Sum(roller.TwoD6(sourceOf())) >= target {
+				return tengo.TrueValue, nil
+			}
+			return tengo.FalseValue, nil
+		}),
+		"countHits": function("countHits", func(arguments ...tengo.Object) (tengo.Object, error) {
+			if len(arguments) != 2 {
+				return nil, tengo.ErrWrongNumArguments
+			}
+			target, _ := tengo.ToInt(arguments[1])
+			hits := 0
+			for _, roll := range toRolls(arguments[0]) {
+				if roll >= target {
+					hits++
+				}
+			}
+			return &tengo.Int{Value: int64(hits)}, nil
+		}),
+		"Reroll": function("Reroll", func(arguments ...tengo.Object) (tengo.Object, error) {
+			if len(arguments) != 2 {
+				return nil, tengo.ErrWrongNumArguments
+			}
+			selection, _ := tengo.ToString(arguments[0])
+			result, ok := arguments[1].(*tengo.Map)
+			if !ok {
+				return arguments[1], nil
+			}
+			rolls := toRolls(result.Value["rolls"])
+			if len(rolls) == 0 {
+				return result, nil
+			}
+			indexes, err := Select(rolls, selection, nil)
+			if err != nil {
+				return &tengo.Error{Value: &tengo.String{Value: err.Error()}}, nil
+			}
+			result.Value["rolls"] = rollsObject(roller.Reroll(sourceOf(), rolls, 6, indexes...))
+			return result, nil
+		}),
+	}
+}
+
+func toRolls(object tengo.Object) (rolls []int) {
+	var values []tengo.Object
+	switch typed := object.(type) {
+	case *tengo.Array:
+		values = typed.Value
+	case *tengo.ImmutableArray:
+		values = typed.Value
+	}
+	for _, value := range values {
+		if roll, ok := tengo.ToInt(value); ok {
+			rolls = append(rolls, roll)
+		}
+	}
+	return rolls
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/FlagrantGarden/flfa/pkg/tympan"
+	"github.com/FlagrantGarden/flfa/pkg/tympan/module"
 
 	"github.com/spf13/cobra"
 )
@@ -18,6 +19,9 @@ type VersionCommand struct {
 	BuildDate string
 	Commit    string
 	tympan.Metadata
+	// Modules, if set, returns the manifests of the modules the application has loaded so their versions are displayed
+	// too; it is only called when the command runs.
+	Modules func() ([]module.Manifest, error)
 }
 
 // The Version command is reimplementable and must return a valid cobra Command.
@@ -32,6 +36,15 @@ func (v *VersionCommand) CreateCommand() *cobra.Command {
 		Hidden: true,
 		Run: func(cmd *cobra.Command, args []string) {
 			fmt.Fprint(os.Stdout, Format(v.Name, v.Version, v.BuildDate, v.Commit, v.SourceUrl))
+			if v.Modules == nil {
+				return
+			}
+			manifests, err := v.Modules()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "\n\nunable to list modules: %s\n", err)
+				return
+			}
+			fmt.Fprint(os.Stdout, FormatModules(manifests))
 		},
 	}
 
@@ -62,6 +75,25 @@ func Format(name string, version string, buildDate string, commit string, source
 	return fmt.Sprintf("%s %s %s %s\n\n%s", name, version, commit, dateStr, githubReleaseTagURL(version, sourceUrl))
 }
 
+// FormatModules is used to display the modules a Tympan application has loaded after its version information, one per
+// line with its version and author:
+//     `
+//
+//     Modules:
+//       Core (core) 0.1.0 by FlagrantGarden
+//     `
+func FormatModules(manifests []module.Manifest) string {
+	if len(manifests) == 0 {
+		return "\n\nNo modules loaded\n"
+	}
+	var output strings.Builder
+	output.WriteString("\n\nModules:\n")
+	for _, manifest := range manifests {
+		output.WriteString(fmt.Sprintf("  %s\n", manifest.String()))
+	}
+	return output.String()
+}
+
 // githubReleaseTagURL is a helper function to determine the URL to the appropriate release of the application on GitHub
 func githubReleaseTagURL(version string, sourceUrl string) string {
 	r := regexp.MustCompile(`^v?\d+\.\d+\.\d+(-[\w.]+)?$`)
@@ -0,0 +1,341 @@
+package lint
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/FlagrantGarden/flfa/pkg/tympan/module/schema"
+	"github.com/mitchellh/mapstructure"
+	"gopkg.in/yaml.v3"
+)
+
+// An Issue is a problem found in one of a module's files which would otherwise be silently dropped when the module is
+// loaded, such as YAML which does not parse or a key which does not belong to the data it is in. The Line and Column
+// are where the problem starts; they are zero when the problem is with the file as a whole.
+type Issue struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+// String formats the Issue the way compilers do, such as "Companies.yaml:12:9: unknown key 'profilname'".
+func (issue Issue) String() string {
+	if issue.Line == 0 {
+		return fmt.Sprintf("%s: %s", issue.File, issue.Message)
+	}
+	if issue.Column == 0 {
+		return fmt.Sprintf("%s:%d: %s", issue.File, issue.Line, issue.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", issue.File, issue.Line, issue.Column, issue.Message)
+}
+
+// At returns an Issue in the file at the position of the node; if the node is nil, the Issue is for the whole file.
+func At(file string, node *yaml.Node, format string, arguments ...any) Issue {
+	issue := Issue{File: file, Message: fmt.Sprintf(format, arguments...)}
+	if node != nil {
+		issue.Line = node.Line
+		issue.Column = node.Column
+	}
+	return issue
+}
+
+// Sort orders issues by file and then by their position in the file.
+func Sort(issues []Issue) {
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].File != issues[j].File {
+			return issues[i].File < issues[j].File
+		}
+		if issues[i].Line != issues[j].Line {
+			return issues[i].Line < issues[j].Line
+		}
+		return issues[i].Column < issues[j].Column
+	})
+}
+
+var yamlErrorLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// Parse parses the contents of a YAML file, returning the root node of its document or the Issues which kept it from
+// parsing. An empty file has no root node and no issues.
+func Parse(file string, contents []byte) (root *yaml.Node, issues []Issue) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(contents, &document); err != nil {
+		for _, line := range strings.Split(strings.TrimPrefix(err.Error(), "yaml: unmarshal errors:\n"), "\n") {
+			issue := Issue{File: file, Message: strings.TrimSpace(line)}
+			if match := yamlErrorLine.FindStringSubmatch(issue.Message); match != nil {
+				issue.Line, _ = strconv.Atoi(match[1])
+				issue.Message = match[2]
+			}
+			issues = append(issues, issue)
+		}
+		return nil, issues
+	}
+	if len(document.Content) == 0 {
+		return nil, nil
+	}
+	return resolve(document.Content[0]), nil
+}
+
+// Find returns the node at the path below the node, or nil if there is none. Each step of the path is either a string,
+// the key of a mapping, matched without regard to case just as data is decoded, or an int, the index in a sequence.
+func Find(node *yaml.Node, path ...any) *yaml.Node {
+	for _, step := range path {
+		node = resolve(node)
+		if node == nil {
+			return nil
+		}
+		switch step := step.(type) {
+		case string:
+			if node.Kind != yaml.MappingNode {
+				return nil
+			}
+			var found *yaml.Node
+			for index := 0; index+1 < len(node.Content); index += 2 {
+				if strings.EqualFold(node.Content[index].Value, step) {
+					found = node.Content[index+1]
+					break
+				}
+			}
+			node = found
+		case int:
+			if node.Kind != yaml.SequenceNode || step < 0 || step >= len(node.Content) {
+				return nil
+			}
+			node = node.Content[step]
+		default:
+			return nil
+		}
+	}
+	return resolve(node)
+}
+
+// resolve follows aliases to the node they refer to.
+func resolve(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// Check compares the node with the Schema of the data it is decoded into, returning an Issue for every key which is not
+// one of the properties of its object and every value which cannot be decoded into its property. Keys are matched to
+// properties without regard to case and values are decoded weakly, just as mapstructure does when a module is loaded,
+// so a number may be quoted and a single value may stand in for a list.
+func Check(file string, node *yaml.Node, root *schema.Schema) []Issue {
+	return check(file, node, root, root)
+}
+
+func check(file string, node *yaml.Node, root *schema.Schema, current *schema.Schema) (issues []Issue) {
+	node = resolve(node)
+	current = root.Resolve(current)
+	if node == nil || node.Tag == "!!null" || current == nil {
+		return nil
+	}
+	if len(current.AnyOf) > 0 {
+		return check(file, node, root, alternative(node, root, current.AnyOf))
+	}
+
+	switch current.Type {
+	case "":
+		return nil
+	case schema.Object:
+		if node.Kind != yaml.MappingNode {
+			if current.Properties == nil {
+				return []Issue{At(file, node, "expected a mapping but found %s", describeNode(node))}
+			}
+			return []Issue{At(file, node, "expected the keys of %s but found %s", describe(current), describeNode(node))}
+		}
+		for index := 0; index+1 < len(node.Content); index += 2 {
+			key, value := node.Content[index], node.Content[index+1]
+			property, found := current.Property(key.Value)
+			if !found {
+				property = current.AdditionalProperties
+			}
+			if property.IsNever() {
+				issues = append(issues, At(file, key, "unknown key '%s'; %s may have %s", key.Value, describe(current), strings.Join(propertyNames(current), ", ")))
+				continue
+			}
+			issues = append(issues, check(file, value, root, property)...)
+		}
+	case schema.Array:
+		if node.Kind != yaml.SequenceNode {
+			return []Issue{At(file, node, "expected a list but found %s", describeNode(node))}
+		}
+		for _, item := range node.Content {
+			issues = append(issues, check(file, item, root, current.Items)...)
+		}
+	default:
+		if node.Kind != yaml.ScalarNode {
+			return []Issue{At(file, node, "expected %s but found %s", describe(current), describeNode(node))}
+		}
+		if !scalarFits(node, current.Type) {
+			return []Issue{At(file, node, "expected %s but found '%s'", describe(current), node.Value)}
+		}
+	}
+	return issues
+}
+
+// alternative picks the schema the node is meant to match from those it may match any of: the first which expects the
+// same kind of node, or else the last, so that a problem is reported against what the node looks like it is meant to be.
+func alternative(node *yaml.Node, root *schema.Schema, alternatives []*schema.Schema) *schema.Schema {
+	for _, candidate := range alternatives {
+		candidate = root.Resolve(candidate)
+		if candidate == nil {
+			continue
+		}
+		switch candidate.Type {
+		case "":
+			return candidate
+		case schema.Object:
+			if node.Kind == yaml.MappingNode {
+				return candidate
+			}
+		case schema.Array:
+			if node.Kind == yaml.SequenceNode {
+				return candidate
+			}
+		default:
+			if node.Kind == yaml.ScalarNode {
+				return candidate
+			}
+		}
+	}
+	return alternatives[len(alternatives)-1]
+}
+
+// scalarFits reports whether a weakly typed decode can put the scalar into a value of the type.
+func scalarFits(node *yaml.Node, valueType string) bool {
+	value := strings.TrimSpace(node.Value)
+	switch valueType {
+	case schema.Boolean:
+		if node.Tag == "!!bool" || value == "" {
+			return true
+		}
+		_, err := strconv.ParseBool(value)
+		return err == nil
+	case schema.Integer:
+		if node.Tag == "!!int" || node.Tag == "!!bool" || value == "" {
+			return true
+		}
+		if _, err := strconv.ParseInt(value, 0, 64); err == nil {
+			return true
+		}
+		number, err := strconv.ParseFloat(value, 64)
+		return err == nil && number == float64(int64(number))
+	case schema.Number:
+		if node.Tag == "!!int" || node.Tag == "!!float" || node.Tag == "!!bool" || value == "" {
+			return true
+		}
+		_, err := strconv.ParseFloat(value, 64)
+		return err == nil
+	}
+	return true
+}
+
+func propertyNames(object *schema.Schema) (names []string) {
+	for name := range object.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func describe(current *schema.Schema) string {
+	switch current.Type {
+	case schema.Object:
+		if current.Title == "" {
+			return "this entry"
+		}
+		return fmt.Sprintf("a %s", current.Title)
+	case schema.Array:
+		return "a list"
+	case schema.Boolean:
+		return "true or false"
+	case schema.Integer:
+		return "a whole number"
+	case schema.Number:
+		return "a number"
+	case schema.String:
+		return "text"
+	}
+	return "a value"
+}
+
+func describeNode(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	}
+	return fmt.Sprintf("'%s'", node.Value)
+}
+
+// Decode decodes the node into the target just as the module loader would, weakly typed with mapstructure.
+func Decode(node *yaml.Node, target any) error {
+	var value any
+	if err := resolve(node).Decode(&value); err != nil {
+		return err
+	}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		WeaklyTypedInput: true,
+		Result:           target,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(value)
+}
+
+// An Entry is one of the entries in a module data file, decoded into its data type, along with the node it was decoded
+// from so later checks can say where a problem is.
+type Entry[T any] struct {
+	Data T
+	Node *yaml.Node
+}
+
+// Entries lints a module data file which stores its data in a list under the "entries" key, checking the file against
+// the Schema generated for the data type. It returns every entry which could be decoded along with every Issue found in
+// the file.
+func Entries[T any](file string, contents []byte) (entries []Entry[T], issues []Issue) {
+	root, issues := Parse(file, contents)
+	if len(issues) > 0 {
+		return nil, issues
+	}
+	if root == nil || root.Kind != yaml.MappingNode {
+		return nil, []Issue{At(file, root, "expected a list of entries under the 'entries' key")}
+	}
+	issues = Check(file, root, schema.Entries(reflect.TypeOf((*T)(nil)).Elem()))
+	list := Find(root, "entries")
+	if list == nil {
+		return nil, append(issues, At(file, root, "expected a list of entries under the 'entries' key"))
+	}
+
+	nodes := list.Content
+	if list.Kind != yaml.SequenceNode {
+		// Decoding is weak, so a single entry is a list of one
+		nodes = []*yaml.Node{list}
+	}
+	checked := len(issues) == 0
+	for _, node := range nodes {
+		node = resolve(node)
+		var data T
+		if err := Decode(node, &data); err != nil {
+			// The check has already reported why, unless it missed something decoding did not
+			if checked {
+				issues = append(issues, At(file, node, "unable to decode entry: %s", err))
+			}
+			continue
+		}
+		entries = append(entries, Entry[T]{Data: data, Node: node})
+	}
+	return entries, issues
+}
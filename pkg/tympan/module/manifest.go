@@ -0,0 +1,122 @@
+package module
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+// ManifestFileName is the name of the file in the root folder of every module which describes it.
+const ManifestFileName = "Module.yaml"
+
+// A Manifest describes a module: who made it, how it is identified and displayed, which version it is, and where to
+// find its source and project. It is read from the module's Module.yaml before any of the module's data, and its Id is
+// the Source of every entry the module provides. The Configuration is whatever the module's configuration block holds,
+// for the application to interpret. Requires maps the id of each module this one needs, such as for its profiles,
+// traits, or script libraries, to a Constraint on that module's version.
+type Manifest struct {
+	Author        string
+	Id            string
+	Display       string
+	Version       string
+	SourceUrl     string            `mapstructure:"source_url"`
+	ProjectUrl    string            `mapstructure:"project_url"`
+	Requires      map[string]string `mapstructure:"requires"`
+	Configuration map[string]any    `mapstructure:"-"`
+}
+
+// A ManifestFile is the contents of Module.yaml: the manifest is stored under the "module" key with the configuration
+// block beside it, and the requirements may be in either.
+type ManifestFile struct {
+	Module        Manifest          `mapstructure:"module"`
+	Requires      map[string]string `mapstructure:"requires"`
+	Configuration map[string]any    `mapstructure:"configuration"`
+}
+
+// GetManifest requires the path to the root folder of a module and an Afero file system. It reads and parses the
+// module's Module.yaml, returning an error if the file cannot be read or parsed or does not give the module an id.
+func GetManifest(modulePath string, afs *afero.Afero) (Manifest, error) {
+	modulePath, err := filepath.Abs(modulePath)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("could not find absolute path for module at '%s'", modulePath)
+	}
+
+	manifestPath := filepath.Join(modulePath, ManifestFileName)
+	manifestBytes, err := afs.ReadFile(manifestPath)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("unable to read manifest '%s': %s", manifestPath, err)
+	}
+	return ParseManifest(manifestPath, manifestBytes)
+}
+
+// GetEmbeddedManifest requires the path to the root folder of a module in an embedded file system and the file system.
+// It reads and parses the module's Module.yaml, just like GetManifest.
+func GetEmbeddedManifest(modulePath string, efs *embed.FS) (Manifest, error) {
+	// Can't use filepath.Join - on windows it uses a '\' which fails; *must* be '/'
+	manifestPath := strings.Join([]string{modulePath, ManifestFileName}, "/")
+	manifestBytes, err := efs.ReadFile(manifestPath)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("unable to read manifest '%s': %s", manifestPath, err)
+	}
+	return ParseManifest(manifestPath, manifestBytes)
+}
+
+// ParseManifest parses the contents of a Module.yaml file; the path is only used to describe errors. A manifest without
+// a display name is displayed by its id.
+func ParseManifest(manifestPath string, manifestBytes []byte) (Manifest, error) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(bytes.NewBuffer(manifestBytes)); err != nil {
+		return Manifest{}, fmt.Errorf("unable to read manifest '%s': %s", manifestPath, err)
+	}
+
+	var parsed ManifestFile
+	if err := v.Unmarshal(&parsed); err != nil {
+		return Manifest{}, fmt.Errorf("unable to parse manifest '%s': %s", manifestPath, err)
+	}
+
+	manifest := parsed.Module
+	if manifest.Id == "" {
+		return Manifest{}, fmt.Errorf("unable to parse manifest '%s': the module has no id", manifestPath)
+	}
+	if manifest.Display == "" {
+		manifest.Display = manifest.Id
+	}
+	if manifest.Requires == nil {
+		manifest.Requires = make(map[string]string)
+	}
+	for id, constraint := range parsed.Requires {
+		manifest.Requires[id] = constraint
+	}
+	for id, constraint := range manifest.Requires {
+		if _, err := ParseConstraint(constraint); err != nil {
+			return Manifest{}, fmt.Errorf("unable to parse manifest '%s': invalid requirement on '%s': %s", manifestPath, id, err)
+		}
+	}
+	manifest.Configuration = parsed.Configuration
+	if manifest.Configuration == nil {
+		manifest.Configuration = make(map[string]any)
+	}
+	return manifest, nil
+}
+
+// String describes the module for people, such as "Core (core) 0.1.0 by FlagrantGarden".
+func (manifest Manifest) String() string {
+	var output strings.Builder
+	output.WriteString(manifest.Display)
+	if manifest.Display != manifest.Id {
+		output.WriteString(fmt.Sprintf(" (%s)", manifest.Id))
+	}
+	if manifest.Version != "" {
+		output.WriteString(fmt.Sprintf(" %s", manifest.Version))
+	}
+	if manifest.Author != "" {
+		output.WriteString(fmt.Sprintf(" by %s", manifest.Author))
+	}
+	return output.String()
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -38,25 +39,27 @@ type CachableWithSubtype[T any] interface {
 	WithSubtype(subtype string) T
 }
 
-// ReadAndParseData must be told what data type it is looking for, given the path to the file to read, and an Afero
-// file system to use. It expects that the data is stored in a slice under the "entries" key in a yaml file. It will
-// look for the absolute path to the file, determine the Source for the data (as its parent folder), use viper to read
-// and unmarshal the data, and then return all entries with their Source set. If any step fails, it will return an
-// empty slice of the specified data type and the error.
+// ReadAndParseData must be told what data type it is looking for, given the path to the file to read, the Source for
+// the data (usually the id from the module's Manifest), and an Afero file system to use. It expects that the data is
+// stored in a slice under the "entries" key in a yaml file. It will look for the absolute path to the file, use viper to
+// read and unmarshal the data, and then return all entries with their Source set. If no Source is given, the name of
+// the data file's parent folder is used instead. If any step fails, it will return an empty slice of the specified data
+// type and the error.
 //
 // You need not call ReadAndParseData directly when interacting with a module; you can instead use the more convenient
 // GetDataByFile function, which only needs the module path, the type name of the data file as it is stored on disk,
-// and an Afero file system.
+// the Source, and an Afero file system.
 //
 // This function is used by both GetDataByFile and GetDataByFolder.
-func ReadAndParseData[T Cachable[T]](dataFilePath string, afs *afero.Afero) ([]T, error) {
+func ReadAndParseData[T Cachable[T]](dataFilePath string, source string, afs *afero.Afero) ([]T, error) {
 	dataFilePath, err := filepath.Abs(dataFilePath)
 	if err != nil {
 		return []T{}, fmt.Errorf("could not find absolute path for data file '%s'", dataFilePath)
 	}
 
-	// Determine source of the data:
-	source := filepath.Base(filepath.Dir(dataFilePath))
+	if source == "" {
+		source = filepath.Base(filepath.Dir(dataFilePath))
+	}
 
 	var data struct {
 		Entries []T `mapstructure:"entries"`
@@ -64,13 +67,13 @@ func ReadAndParseData[T Cachable[T]](dataFilePath string, afs *afero.Afero) ([]T
 
 	v := viper.New()
 	v.SetFs(afs)
-	v.AddConfigPath(dataFilePath)
+	v.SetConfigFile(dataFilePath)
 	err = v.ReadInConfig()
 	if err != nil {
 		return []T{}, fmt.Errorf("unable to read data file '%s': %s", dataFilePath, err.Error())
 	}
 
-	err = viper.Unmarshal(&data)
+	err = v.Unmarshal(&data)
 	if err != nil {
 		return []T{}, fmt.Errorf("unable to parse data file '%s' %s", dataFilePath, err)
 	}
@@ -86,12 +89,12 @@ func ReadAndParseData[T Cachable[T]](dataFilePath string, afs *afero.Afero) ([]T
 }
 
 // GetDataByFile must be told what data type it is looking for and given the path to the module folder, the name of the
-// data type, and an Afero file system to use. It expects that the data is stored in a slice under the "entries" key in
+// data type, the Source for the data, and an Afero file system to use. It expects that the data is stored in a slice under the "entries" key in
 // a yaml file named the same as the passed data type. It will look for the absolute path to the module folder,
 // determine the name of the yaml file, and then call ReadAndParseData with the passed data type and determined file
 // path, returning the slice of discovered entries. If any step fails, it will return an empty slice of the specified
 // data type and the error.
-func GetDataByFile[T Cachable[T]](modulePath string, dataTypeName string, afs *afero.Afero) ([]T, error) {
+func GetDataByFile[T Cachable[T]](modulePath string, dataTypeName string, source string, afs *afero.Afero) ([]T, error) {
 	modulePath, err := filepath.Abs(modulePath)
 	if err != nil {
 		log.Error().Msgf("could not find absolute path for module at '%s'", modulePath)
@@ -101,7 +104,7 @@ func GetDataByFile[T Cachable[T]](modulePath string, dataTypeName string, afs *a
 	moduleDataFilePath := filepath.Join(modulePath, dataFileName)
 	log.Trace().Msgf("Loading data from %s", moduleDataFilePath)
 
-	entries, err := ReadAndParseData[T](moduleDataFilePath, afs)
+	entries, err := ReadAndParseData[T](moduleDataFilePath, source, afs)
 	if err != nil {
 		return []T{}, err
 	}
@@ -110,18 +113,24 @@ func GetDataByFile[T Cachable[T]](modulePath string, dataTypeName string, afs *a
 }
 
 // GetDataByFolder must be told what data type it is looking for and given the path to the module folder, the name of
-// the data folder to look in, and an Afero file system to use. It expects that the data is stored in multiple yaml
+// the data folder to look in, the Source for the data, and an Afero file system to use. It expects that the data is stored in multiple yaml
 // files whose name is the subtype for all entries in that file. It expects that each file stores the data in a slice
 // under the "entries" key. It will look for the absolute path to the module folder, combine that with the data folder
 // name, and then walk the data folder, calling ReadAndParseData on each yaml file it finds, setting their subtype
-// before returning the combined slice of all discovered entries from every parsed data file. If any step fails, it will
-// return an empty slice of the specified data type and the error.
-func GetDataByFolder[T CachableWithSubtype[T]](modulePath string, dataFolderName string, afs *afero.Afero) ([]T, error) {
+// before returning the combined slice of all discovered entries from every parsed data file. If no Source is given, the
+// name of the module folder is used instead. If any step fails, it will return an empty slice of the specified data
+// type and the error.
+func GetDataByFolder[T CachableWithSubtype[T]](modulePath string, dataFolderName string, source string, afs *afero.Afero) ([]T, error) {
 	modulePath, err := filepath.Abs(modulePath)
 	if err != nil {
 		return []T{}, fmt.Errorf("could not find absolute path for module at '%s'", modulePath)
 	}
 
+	// The data files are in a folder of their own, so their parent folder is not named for the module
+	if source == "" {
+		source = filepath.Base(modulePath)
+	}
+
 	moduleFolderPath := filepath.Join(modulePath, dataFolderName)
 	log.Trace().Msgf("Loading %s from %s", dataFolderName, moduleFolderPath)
 
@@ -135,7 +144,7 @@ func GetDataByFolder[T CachableWithSubtype[T]](modulePath string, dataFolderName
 		log.Trace().Msgf("Walking %s", path)
 		isDataFile, _ := filepath.Match("*.yaml", filepath.Base(path))
 		if isDataFile {
-			entries, err := ReadAndParseData[T](path, afs)
+			entries, err := ReadAndParseData[T](path, source, afs)
 			if err != nil {
 				return err
 			}
@@ -145,28 +154,31 @@ func GetDataByFolder[T CachableWithSubtype[T]](modulePath string, dataFolderName
 				entry = entry.WithSubtype(subtype)
 				returnEntries = append(returnEntries, entry)
 			}
-
-			returnEntries = append(returnEntries, entries...)
 		}
 		return nil
 	})
+	if err != nil {
+		return []T{}, err
+	}
 	return returnEntries, nil
 }
 
-// ReadAndParseEmbeddedData must be told what data type it is looking for, given the path to the file to read, and a
-// pointer to the embedded file system to use. It expects that the data is stored in a slice under the "entries" key in
-// a yaml file. It will look for the absolute path to the file, determine the Source for the data (as its parent
-// folder), use viper to read and unmarshal the data, and then return all entries with their Source set. If any step
-// fails, it will return an empty slice of the specified data type and the error.
+// ReadAndParseEmbeddedData must be told what data type it is looking for, given the path to the file to read, the
+// Source for the data (usually the id from the module's Manifest), and a pointer to the embedded file system to use. It
+// expects that the data is stored in a slice under the "entries" key in a yaml file. It will use viper to read and
+// unmarshal the data, and then return all entries with their Source set. If no Source is given, the name of the data
+// file's parent folder is used instead. If any step fails, it will return an empty slice of the specified data type
+// and the error.
 //
 // You need not call ReadAndParseEmbeddedData directly when interacting with a module; you can instead use the more
 // convenient GetEmbeddedDataByFile function, which only needs the module path, the type name of the data file as it is
-// stored on disk, and an embedded file system.
+// stored on disk, the Source, and an embedded file system.
 //
 // This function is used by both GetEmbeddedDataByFile and GetEmbeddedDataByFolder.
-func ReadAndParseEmbeddedData[T Cachable[T]](dataFilePath string, efs *embed.FS) ([]T, error) {
-	// Determine source of data file:
-	source := filepath.Base(filepath.Dir(filepath.Dir(dataFilePath)))
+func ReadAndParseEmbeddedData[T Cachable[T]](dataFilePath string, source string, efs *embed.FS) ([]T, error) {
+	if source == "" {
+		source = path.Base(path.Dir(dataFilePath))
+	}
 
 	var data struct {
 		Entries []T `mapstructure:"entries"`
@@ -200,17 +212,17 @@ func ReadAndParseEmbeddedData[T Cachable[T]](dataFilePath string, efs *embed.FS)
 }
 
 // GetEmbeddedDataByFile must be told what data type it is looking for and given the path to the module folder, the name
-// of the data type, and an embedded file system to use. It expects that the data is stored in a slice under the
+// of the data type, the Source for the data, and an embedded file system to use. It expects that the data is stored in a slice under the
 // "entries" key in a yaml file named the same as the passed data type. It will determine the name of the yaml file and
 // then call ReadAndParseData with the passed data type and determined file path, returning the slice of discovered
 // entries. If any step fails, it will return an empty slice of the specified data type and the error.
-func GetEmbeddedDataByFile[T Cachable[T]](modulePath string, dataTypeName string, efs *embed.FS) ([]T, error) {
+func GetEmbeddedDataByFile[T Cachable[T]](modulePath string, dataTypeName string, source string, efs *embed.FS) ([]T, error) {
 	dataFileName := fmt.Sprintf("%s.yaml", dataTypeName)
 	// Can't use filepath.Join - on windows it uses a '\' which fails; *must* be '/'
 	moduleDataFilePath := strings.Join([]string{modulePath, dataFileName}, "/")
 	log.Trace().Msgf("Loading data from %s", moduleDataFilePath)
 
-	entries, err := ReadAndParseEmbeddedData[T](moduleDataFilePath, efs)
+	entries, err := ReadAndParseEmbeddedData[T](moduleDataFilePath, source, efs)
 	if err != nil {
 		return []T{}, err
 	}
@@ -219,13 +231,18 @@ func GetEmbeddedDataByFile[T Cachable[T]](modulePath string, dataTypeName string
 }
 
 // GetEmbeddedDataByFolder must be told what data type it is looking for and given the path to the module folder, the
-// name of the data folder to look in, and an embedded file system to use. It expects that the data is stored in
+// name of the data folder to look in, the Source for the data, and an embedded file system to use. It expects that the data is stored in
 // multiple yaml files whose name is the subtype for all entries in that file. It expects that each file stores the data
 // in a slice under the "entries" key. It will join the module folder path with the data folder name and then walk the
 // data folder, calling ReadAndParseEmbeddedData on each yaml file it finds, setting their subtype before returning the
-// combined slice of all discovered entries from every parsed data file. If any step fails, it will return an empty
-// slice of the specified data type and the error.
-func GetEmbeddedDataByFolder[T CachableWithSubtype[T]](modulePath string, dataFolderName string, efs *embed.FS) ([]T, error) {
+// combined slice of all discovered entries from every parsed data file. If no Source is given, the name of the module
+// folder is used instead. If any step fails, it will return an empty slice of the specified data type and the error.
+func GetEmbeddedDataByFolder[T CachableWithSubtype[T]](modulePath string, dataFolderName string, source string, efs *embed.FS) ([]T, error) {
+	// The data files are in a folder of their own, so their parent folder is not named for the module
+	if source == "" {
+		source = path.Base(modulePath)
+	}
+
 	// Can't use filepath.Join - on windows it uses a '\' which fails; *must* be '/'
 	moduleFolderPath := strings.Join([]string{modulePath, dataFolderName}, "/")
 	log.Trace().Msgf("Loading %s from %s", dataFolderName, moduleFolderPath)
@@ -240,7 +257,7 @@ func GetEmbeddedDataByFolder[T CachableWithSubtype[T]](modulePath string, dataFo
 		log.Logger.Trace().Msgf("Walking %s", path)
 		isDataFile, _ := filepath.Match("*.yaml", filepath.Base(path))
 		if isDataFile {
-			entries, err := ReadAndParseEmbeddedData[T](path, efs)
+			entries, err := ReadAndParseEmbeddedData[T](path, source, efs)
 			if err != nil {
 				return err
 			}
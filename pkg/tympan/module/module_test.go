@@ -0,0 +1,51 @@
+package module
+
+import (
+	"testing"
+
+	"github.com/FlagrantGarden/flfa/emfs"
+	"github.com/spf13/afero"
+)
+
+type testEntry struct {
+	Name    string
+	Source  string
+	Subtype string
+}
+
+func (entry testEntry) WithSource(source string) testEntry {
+	entry.Source = source
+	return entry
+}
+
+func (entry testEntry) WithSubtype(subtype string) testEntry {
+	entry.Subtype = subtype
+	return entry
+}
+
+// Data read from a folder without a Source must still take its Source from the module, not from the data folder.
+func TestDataByFolderDefaultsToTheModuleAsSource(t *testing.T) {
+	embedded, err := GetEmbeddedDataByFolder[testEntry]("modules/core", "Traits", "", &emfs.EmbeddedModulesFS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	afs := &afero.Afero{Fs: afero.NewMemMapFs()}
+	afs.MkdirAll("/modules/scouts/Traits", 0755)
+	afs.WriteFile("/modules/scouts/Traits/Special.yaml", []byte("entries:\n  - name: Stealthy\n"), 0644)
+	onDisk, err := GetDataByFolder[testEntry]("/modules/scouts", "Traits", "", afs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for source, entries := range map[string][]testEntry{"core": embedded, "scouts": onDisk} {
+		if len(entries) == 0 {
+			t.Errorf("expected entries from '%s'", source)
+		}
+		for _, entry := range entries {
+			if entry.Source != source || entry.Subtype == "" {
+				t.Errorf("expected '%s' to have the source '%s' and a subtype, got '%s' and '%s'", entry.Name, source, entry.Source, entry.Subtype)
+			}
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package module
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Version is a module's semantic version: major.minor.patch with an optional prerelease, such as 1.2.0-beta.1. A
+// leading v is ignored and missing minor or patch numbers are zero.
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string
+}
+
+// ParseVersion parses a semantic version, returning an error if it is not one.
+func ParseVersion(version string) (parsed Version, err error) {
+	trimmed := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	trimmed, _, _ = strings.Cut(trimmed, "+")
+	trimmed, parsed.Prerelease, _ = strings.Cut(trimmed, "-")
+
+	numbers := strings.Split(trimmed, ".")
+	if trimmed == "" || len(numbers) > 3 {
+		return parsed, fmt.Errorf("'%s' is not a semantic version", version)
+	}
+	for index, number := range numbers {
+		value, err := strconv.Atoi(number)
+		if err != nil || value < 0 {
+			return parsed, fmt.Errorf("'%s' is not a semantic version", version)
+		}
+		switch index {
+		case 0:
+			parsed.Major = value
+		case 1:
+			parsed.Minor = value
+		case 2:
+			parsed.Patch = value
+		}
+	}
+	return parsed, nil
+}
+
+// Compare returns -1 if the version is lower than the other, 1 if it is higher, and 0 if they are the same. A
+// prerelease is lower than its release; prereleases of the same release are compared as semantic versioning requires.
+func (version Version) Compare(other Version) int {
+	for _, difference := range []int{version.Major - other.Major, version.Minor - other.Minor, version.Patch - other.Patch} {
+		if difference < 0 {
+			return -1
+		}
+		if difference > 0 {
+			return 1
+		}
+	}
+	switch {
+	case version.Prerelease == other.Prerelease:
+		return 0
+	case version.Prerelease == "":
+		return 1
+	case other.Prerelease == "":
+		return -1
+	}
+	return comparePrereleases(version.Prerelease, other.Prerelease)
+}
+
+// comparePrereleases compares prereleases one dot-separated identifier at a time: numbers are compared numerically and
+// are lower than any other identifier, which are compared as text. A prerelease whose identifiers all match the start
+// of a longer one is the lower of the two.
+func comparePrereleases(prerelease string, other string) int {
+	identifiers, otherIdentifiers := strings.Split(prerelease, "."), strings.Split(other, ".")
+	for index := 0; index < len(identifiers) && index < len(otherIdentifiers); index++ {
+		identifier, otherIdentifier := identifiers[index], otherIdentifiers[index]
+		number, numberErr := strconv.ParseUint(identifier, 10, 64)
+		otherNumber, otherNumberErr := strconv.ParseUint(otherIdentifier, 10, 64)
+		switch {
+		case numberErr == nil && otherNumberErr == nil:
+			if number != otherNumber {
+				if number < otherNumber {
+					return -1
+				}
+				return 1
+			}
+		case numberErr == nil:
+			return -1
+		case otherNumberErr == nil:
+			return 1
+		default:
+			if compared := strings.Compare(identifier, otherIdentifier); compared != 0 {
+				return compared
+			}
+		}
+	}
+	switch {
+	case len(identifiers) < len(otherIdentifiers):
+		return -1
+	case len(identifiers) > len(otherIdentifiers):
+		return 1
+	}
+	return 0
+}
+
+func (version Version) String() string {
+	if version.Prerelease == "" {
+		return fmt.Sprintf("%d.%d.%d", version.Major, version.Minor, version.Patch)
+	}
+	return fmt.Sprintf("%d.%d.%d-%s", version.Major, version.Minor, version.Patch, version.Prerelease)
+}
+
+// A Constraint is the range of versions a module requires of another, written as comparisons which must all hold,
+// separated by commas or spaces: >=0.1.0, <2.0.0 for example. Each comparison is one of =, !=, >, >=, <, or <= followed
+// by a version; a bare version must match exactly; ^1.2.0 allows any version from 1.2.0 with the same major version
+// (or the same minor version, before 1.0.0); ~1.2.0 allows any version from 1.2.0 with the same minor version. An
+// empty constraint or * allows any version.
+type Constraint struct {
+	Text        string
+	comparisons []comparison
+}
+
+type comparison struct {
+	operator string
+	version  Version
+}
+
+// ParseConstraint parses a Constraint, returning an error if any of its comparisons is malformed.
+func ParseConstraint(constraint string) (parsed Constraint, err error) {
+	parsed.Text = strings.TrimSpace(constraint)
+	fields := strings.FieldsFunc(parsed.Text, func(character rune) bool {
+		return character == ',' || character == ' '
+	})
+	for index := 0; index < len(fields); index++ {
+		field := fields[index]
+		if field == "*" {
+			continue
+		}
+		operator := field[:len(field)-len(strings.TrimLeft(field, "=!<>^~"))]
+		versionText := strings.TrimPrefix(field, operator)
+		// Allow a space between the operator and the version, as in ">= 0.1.0"
+		if versionText == "" && index+1 < len(fields) {
+			index++
+			versionText = fields[index]
+		}
+		version, err := ParseVersion(versionText)
+		if err != nil {
+			return parsed, fmt.Errorf("unable to parse constraint '%s': %s", constraint, err)
+		}
+		switch operator {
+		case "", "=", "==":
+			parsed.comparisons = append(parsed.comparisons, comparison{"=", version})
+		case "!=", ">", ">=", "<", "<=":
+			parsed.comparisons = append(parsed.comparisons, comparison{operator, version})
+		case "^":
+			upper := Version{Major: version.Major + 1}
+			if version.Major == 0 {
+				upper = Version{Minor: version.Minor + 1}
+			}
+			parsed.comparisons = append(parsed.comparisons, comparison{">=", version}, comparison{"<", upper})
+		case "~":
+			upper := Version{Major: version.Major, Minor: version.Minor + 1}
+			parsed.comparisons = append(parsed.comparisons, comparison{">=", version}, comparison{"<", upper})
+		default:
+			return parsed, fmt.Errorf("unable to parse constraint '%s': unknown operator '%s'", constraint, operator)
+		}
+	}
+	return parsed, nil
+}
+
+// Allows reports whether the version satisfies every comparison in the Constraint.
+func (constraint Constraint) Allows(version Version) bool {
+	for _, comparison := range constraint.comparisons {
+		compared := version.Compare(comparison.version)
+		var allowed bool
+		switch comparison.operator {
+		case "=":
+			allowed = compared == 0
+		case "!=":
+			allowed = compared != 0
+		case ">":
+			allowed = compared > 0
+		case ">=":
+			allowed = compared >= 0
+		case "<":
+			allowed = compared < 0
+		case "<=":
+			allowed = compared <= 0
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckRequirements makes sure every module the manifest requires is among the available manifests at a version its
+// constraint allows, returning an error describing the first requirement which is not met.
+func (manifest Manifest) CheckRequirements(available map[string]Manifest) error {
+	for _, id := range manifest.RequiredIds() {
+		text := manifest.Requires[id]
+		constraint, err := ParseConstraint(text)
+		if err != nil {
+			return fmt.Errorf("module '%s' has an invalid requirement on '%s': %s", manifest.Id, id, err)
+		}
+		required, ok := available[id]
+		if !ok {
+			return fmt.Errorf("module '%s' requires module '%s' (%s), which is not installed", manifest.Id, id, constraint.Text)
+		}
+		version, err := ParseVersion(required.Version)
+		if err != nil {
+			return fmt.Errorf("module '%s' requires module '%s' %s, but its version is invalid: %s", manifest.Id, id, constraint.Text, err)
+		}
+		if !constraint.Allows(version) {
+			return fmt.Errorf("module '%s' requires module '%s' %s, but version %s is installed", manifest.Id, id, constraint.Text, required.Version)
+		}
+	}
+	return nil
+}
+
+// RequiredIds returns the ids of the modules the manifest requires, in alphabetical order.
+func (manifest Manifest) RequiredIds() (ids []string) {
+	for id := range manifest.Requires {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// OrderByRequirements returns the manifests in the order their modules must be loaded, each after every module it
+// requires; modules which do not depend on each other stay in the order given. It returns an error if two manifests
+// share an id, if a required module is missing or at a version the requirement does not allow, or if modules require
+// each other in a cycle.
+func OrderByRequirements(manifests []Manifest) (ordered []Manifest, err error) {
+	available := make(map[string]Manifest)
+	for _, manifest := range manifests {
+		if _, exists := available[manifest.Id]; exists {
+			return nil, fmt.Errorf("more than one module has the id '%s'", manifest.Id)
+		}
+		available[manifest.Id] = manifest
+	}
+	for _, manifest := range manifests {
+		if err := manifest.CheckRequirements(available); err != nil {
+			return nil, err
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	states := make(map[string]int)
+	var path []string
+	var visit func(manifest Manifest) error
+	visit = func(manifest Manifest) error {
+		switch states[manifest.Id] {
+		case visited:
+			return nil
+		case visiting:
+			start := 0
+			for index, id := range path {
+				if id == manifest.Id {
+					start = index
+				}
+			}
+			cycle := append(append([]string{}, path[start:]...), manifest.Id)
+			return fmt.Errorf("modules require each other in a cycle: %s", strings.Join(cycle, " -> "))
+		}
+		states[manifest.Id] = visiting
+		path = append(path, manifest.Id)
+		for _, id := range manifest.RequiredIds() {
+			if err := visit(available[id]); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		states[manifest.Id] = visited
+		ordered = append(ordered, manifest)
+		return nil
+	}
+	for _, manifest := range manifests {
+		if err := visit(manifest); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
@@ -0,0 +1,119 @@
+package module
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVersionCompare(t *testing.T) {
+	ascending := []string{
+		"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.10",
+		"1.0.0-rc.1", "1.0.0", "1.0.1", "1.2.0", "2.0.0",
+	}
+	for index, text := range ascending {
+		version, err := ParseVersion(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if compared := version.Compare(version); compared != 0 {
+			t.Errorf("expected %s to equal itself, got %d", text, compared)
+		}
+		for _, higherText := range ascending[index+1:] {
+			higher, err := ParseVersion(higherText)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version.Compare(higher) != -1 || higher.Compare(version) != 1 {
+				t.Errorf("expected %s to be lower than %s", text, higherText)
+			}
+		}
+	}
+}
+
+func TestParseConstraint(t *testing.T) {
+	for constraint, versions := range map[string]map[string]bool{
+		"^0.2.0":          {"0.2.0": true, "0.2.5": true, "0.1.9": false, "0.3.0": false, "1.0.0": false},
+		"^1.2.0":          {"1.2.0": true, "1.9.3": true, "1.1.0": false, "2.0.0": false},
+		"~1.2.0":          {"1.2.0": true, "1.2.9": true, "1.3.0": false, "1.1.9": false},
+		">= 0.1.0":        {"0.1.0": true, "3.0.0": true, "0.0.9": false},
+		">=0.1.0, <2.0.0": {"0.1.0": true, "1.9.9": true, "2.0.0": false},
+		"1.0.0":           {"1.0.0": true, "1.0.1": false},
+		"*":               {"0.0.1": true, "9.0.0": true},
+		"":                {"0.0.1": true},
+	} {
+		parsed, err := ParseConstraint(constraint)
+		if err != nil {
+			t.Errorf("expected '%s' to parse, got %s", constraint, err)
+			continue
+		}
+		for text, allowed := range versions {
+			version, err := ParseVersion(text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Allows(version) != allowed {
+				t.Errorf("expected '%s' allowing %s to be %t", constraint, text, allowed)
+			}
+		}
+	}
+	for _, constraint := range []string{"=> 1.0.0", ">= one", "^"} {
+		if _, err := ParseConstraint(constraint); err == nil {
+			t.Errorf("expected '%s' not to parse", constraint)
+		}
+	}
+}
+
+func TestOrderByRequirements(t *testing.T) {
+	manifest := func(id string, version string, requires map[string]string) Manifest {
+		return Manifest{Id: id, Version: version, Requires: requires}
+	}
+
+	ordered, err := OrderByRequirements([]Manifest{
+		manifest("rangers", "1.0.0", map[string]string{"scouts": "^1.0.0", "core": ">= 0.1.0"}),
+		manifest("scouts", "1.2.0", map[string]string{"core": ">= 0.1.0"}),
+		manifest("core", "0.1.0", nil),
+		manifest("extras", "1.0.0", nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, manifest := range ordered {
+		ids = append(ids, manifest.Id)
+	}
+	if order := strings.Join(ids, ", "); order != "core, scouts, rangers, extras" {
+		t.Errorf("expected the modules in the order core, scouts, rangers, extras, got %s", order)
+	}
+
+	for name, test := range map[string]struct {
+		manifests []Manifest
+		expected  string
+	}{
+		"missing": {
+			[]Manifest{manifest("rangers", "1.0.0", map[string]string{"scouts": "^1.0.0"})},
+			"module 'rangers' requires module 'scouts' (^1.0.0), which is not installed",
+		},
+		"wrong version": {
+			[]Manifest{
+				manifest("rangers", "1.0.0", map[string]string{"scouts": "^1.0.0"}),
+				manifest("scouts", "2.0.0", nil),
+			},
+			"module 'rangers' requires module 'scouts' ^1.0.0, but version 2.0.0 is installed",
+		},
+		"circular": {
+			[]Manifest{
+				manifest("rangers", "1.0.0", map[string]string{"scouts": "*"}),
+				manifest("scouts", "1.0.0", map[string]string{"rangers": "*"}),
+			},
+			"modules require each other in a cycle: rangers -> scouts -> rangers",
+		},
+		"duplicate": {
+			[]Manifest{manifest("scouts", "1.0.0", nil), manifest("scouts", "1.1.0", nil)},
+			"more than one module has the id 'scouts'",
+		},
+	} {
+		if _, err := OrderByRequirements(test.manifests); err == nil || err.Error() != test.expected {
+			t.Errorf("expected the %s requirement to fail with \"%s\", got %v", name, test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Draft is the version of JSON Schema every generated Schema follows.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// The types a Schema may give a value.
+const (
+	Object  = "object"
+	Array   = "array"
+	String  = "string"
+	Integer = "integer"
+	Number  = "number"
+	Boolean = "boolean"
+)
+
+// A Schema is a JSON Schema describing the data a module file may hold. Editors which understand JSON Schema can use
+// one to validate and autocomplete a module's YAML files, and the linter checks modules against them.
+//
+// A Schema without a Type allows any value. Every struct is described once in the root Schema's Defs and referred to
+// everywhere else by its Ref.
+type Schema struct {
+	Schema string `json:"$schema,omitempty"`
+	Ref    string `json:"$ref,omitempty"`
+	Title  string `json:"title,omitempty"`
+	Type   string `json:"type,omitempty"`
+	// The Properties of an object are matched to its keys without regard to case, just as data is decoded. JSON Schema
+	// matches them exactly, so each is repeated in PatternProperties under a pattern matching its name in any case.
+	Properties        map[string]*Schema `json:"properties,omitempty"`
+	PatternProperties map[string]*Schema `json:"patternProperties,omitempty"`
+	// The AdditionalProperties are the schema for the values of keys which are not Properties; Never allows no others.
+	AdditionalProperties *Schema `json:"additionalProperties,omitempty"`
+	Items                *Schema `json:"items,omitempty"`
+	// A value matches the Schema if it matches any of the schemas in AnyOf.
+	AnyOf    []*Schema          `json:"anyOf,omitempty"`
+	Required []string           `json:"required,omitempty"`
+	Defs     map[string]*Schema `json:"$defs,omitempty"`
+
+	never bool
+}
+
+// Never is the Schema no value matches, written as false.
+var Never = &Schema{never: true}
+
+// IsNever reports whether the Schema is Never.
+func (schema *Schema) IsNever() bool {
+	return schema != nil && schema.never
+}
+
+func (schema *Schema) MarshalJSON() ([]byte, error) {
+	if schema.never {
+		return []byte("false"), nil
+	}
+	type plain Schema
+	return json.Marshal((*plain)(schema))
+}
+
+// Resolve returns the Schema in the root's Defs which the Schema refers to, or the Schema itself if it is not a Ref.
+func (root *Schema) Resolve(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		schema = root.Defs[strings.TrimPrefix(schema.Ref, "#/$defs/")]
+	}
+	return schema
+}
+
+// Property returns the Schema of the object's property with the name, matched without regard to case.
+func (schema *Schema) Property(name string) (property *Schema, found bool) {
+	if property, found = schema.Properties[name]; found {
+		return property, true
+	}
+	for key, property := range schema.Properties {
+		if strings.EqualFold(key, name) {
+			return property, true
+		}
+	}
+	return nil, false
+}
+
+// For generates the Schema of the data type from its fields the same way mapstructure decodes them when a module is
+// loaded. Each field is a property named by its mapstructure tag or else by its own name with the first letter
+// lowercased; fields tagged "-" are left out and the fields of squashed structs are the outer struct's own. Since data
+// is decoded weakly, a list may also be given as a single item.
+func For(dataType reflect.Type) *Schema {
+	root := &Schema{Defs: make(map[string]*Schema)}
+	generated := root.generate(dataType)
+	defs := root.Defs
+	*root = *generated
+	root.Schema = Draft
+	if len(defs) > 0 {
+		root.Defs = defs
+	}
+	return root
+}
+
+// Entries generates the Schema of a module data file which stores entries of the data type in a list under the
+// "entries" key.
+func Entries(dataType reflect.Type) *Schema {
+	root := &Schema{Schema: Draft, Defs: make(map[string]*Schema)}
+	root.Title = "data file"
+	root.Type = Object
+	root.Properties = map[string]*Schema{"entries": root.generate(reflect.SliceOf(dataType))}
+	root.AdditionalProperties = Never
+	root.Required = []string{"entries"}
+	return root
+}
+
+// generate returns the Schema of the data type, adding the structs it refers to into the root's Defs.
+func (root *Schema) generate(dataType reflect.Type) *Schema {
+	for dataType.Kind() == reflect.Pointer {
+		dataType = dataType.Elem()
+	}
+
+	switch dataType.Kind() {
+	case reflect.Struct:
+		name := dataType.Name()
+		if name == "" {
+			return root.object(dataType)
+		}
+		reference := &Schema{Ref: "#/$defs/" + name}
+		if _, defined := root.Defs[name]; !defined {
+			// Claim the name first so a struct which contains itself refers to its own definition
+			root.Defs[name] = &Schema{}
+			root.Defs[name] = root.object(dataType)
+			root.Defs[name].Title = name
+		}
+		return reference
+	case reflect.Map:
+		return &Schema{Type: Object, AdditionalProperties: root.generate(dataType.Elem())}
+	case reflect.Slice, reflect.Array:
+		// Decoding is weak, so a single value may stand in for a list of one
+		items := root.generate(dataType.Elem())
+		return &Schema{AnyOf: []*Schema{{Type: Array, Items: items}, items}}
+	case reflect.Bool:
+		return &Schema{Type: Boolean}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: Integer}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: Number}
+	case reflect.String:
+		return &Schema{Type: String}
+	}
+	return &Schema{}
+}
+
+func (root *Schema) object(dataType reflect.Type) *Schema {
+	object := &Schema{Type: Object, Properties: make(map[string]*Schema), AdditionalProperties: Never}
+	for index := 0; index < dataType.NumField(); index++ {
+		field := dataType.Field(index)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if name == "-" {
+			continue
+		}
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if strings.Contains(options, "squash") && fieldType.Kind() == reflect.Struct {
+			for key, property := range root.object(fieldType).Properties {
+				object.Properties[key] = property
+			}
+			continue
+		}
+		if name == "" {
+			name = lowerFirst(field.Name)
+		}
+		object.Properties[name] = root.generate(field.Type)
+	}
+	if len(object.Properties) > 0 {
+		object.PatternProperties = make(map[string]*Schema, len(object.Properties))
+		for name, property := range object.Properties {
+			object.PatternProperties[caseless(name)] = property
+		}
+	}
+	return object
+}
+
+// caseless returns a pattern matching the whole of the name in any case; JSON Schema patterns are ECMA 262 regular
+// expressions, which have no flag for ignoring case within the pattern.
+func caseless(name string) string {
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	for _, letter := range name {
+		upper, lower := unicode.ToUpper(letter), unicode.ToLower(letter)
+		if upper == lower {
+			pattern.WriteString(regexp.QuoteMeta(string(letter)))
+			continue
+		}
+		pattern.WriteString("[" + string(upper) + string(lower) + "]")
+	}
+	pattern.WriteString("$")
+	return pattern.String()
+}
+
+func lowerFirst(name string) string {
+	first, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(first)) + name[size:]
+}
@@ -0,0 +1,38 @@
+package schema
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+// Data is decoded without regard to the case of its keys, so editors checking a module against its Schema must accept
+// a property in any case too.
+func TestPropertiesMatchInAnyCase(t *testing.T) {
+	type move struct {
+		Activation int
+		Distance   int `mapstructure:"distance"`
+		ToHit      int `mapstructure:"to_hit"`
+	}
+	generated := For(reflect.TypeOf(move{}))
+	object := generated.Resolve(generated)
+	for key, name := range map[string]string{"Distance": "distance", "distance": "distance", "TO_HIT": "to_hit", "activation": "activation"} {
+		matched := false
+		for pattern, property := range object.PatternProperties {
+			if regexp.MustCompile(pattern).MatchString(key) {
+				matched = true
+				if property != object.Properties[name] {
+					t.Errorf("expected '%s' to match the property '%s'", key, name)
+				}
+			}
+		}
+		if !matched {
+			t.Errorf("expected a pattern property matching '%s'", key)
+		}
+	}
+	for pattern := range object.PatternProperties {
+		if regexp.MustCompile(pattern).MatchString("distances") {
+			t.Errorf("expected '%s' to match only whole names", pattern)
+		}
+	}
+}
@@ -88,10 +88,22 @@ func ConvertToTengoMap(data any) (map[string]any, error) {
 			}
 			tengoMap[name] = mapValue
 		} else if value.Kind() == reflect.Slice {
-			// slices must be turned into []any
+			// slices must be turned into []any; any structs in them must be converted to maps, too
 			array := make([]any, value.Len())
 			for i := 0; i < value.Len(); i++ {
-				array[i] = value.Index(i).Interface()
+				item := value.Index(i)
+				if item.Kind() == reflect.Pointer {
+					item = reflect.Indirect(item)
+				}
+				if item.Kind() == reflect.Struct {
+					mapValue, err := ConvertToTengoMap(item.Interface())
+					if err != nil {
+						return map[string]any{}, err
+					}
+					array[i] = mapValue
+				} else {
+					array[i] = value.Index(i).Interface()
+				}
 			}
 			tengoMap[name] = array
 		} else {
@@ -2,8 +2,10 @@ package scripting
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
+	"github.com/FlagrantGarden/flfa/pkg/tympan/dice"
 	"github.com/FlagrantGarden/flfa/pkg/tympan/utils"
 	"github.com/d5/tengo/v2"
 	"github.com/d5/tengo/v2/stdlib"
@@ -25,6 +27,8 @@ type Engine struct {
 	Importer *LibraryImporter
 	// The array of scripts enables you to cache a script for future use and introspection
 	Scripts []*Script
+	// The Dice are the roller scripts use through the native dice module; see UseDice.
+	Dice *dice.Roller
 }
 
 // The EngineSettings configure how the tengo script engine behaves, providing some useful shorthands so you don't need
@@ -34,6 +38,7 @@ type EngineSettings struct {
 	// available to the script without every script needing to redeclare them.
 	ScriptHeader string
 	// The RandomSeed allows you to specify a seed to initialize for randomization in go instead of in your tengo scripts.
+	// It seeds the engine's dice when InitializeDice is called; if it is zero, the dice are seeded from the current time.
 	RandomSeed int64
 	// If specified, MaximumObjectAllocations limits the number of objects any one script can create.
 	MaximumObjectAllocations int64
@@ -48,6 +53,10 @@ type EngineSettings struct {
 	ApplicationLibraries []Library
 	// The list of Tympan scripting modules that the engine should cache and make available to scripts.
 	ApplicationModules []Module
+	// The native modules are written in go instead of tengo and made available to scripts just like the standard
+	// libraries; each one is a map of attribute names to tengo objects, usually functions wrapped as a
+	// tengo.UserFunction. They are how an application exposes its own state and behavior to scripts.
+	NativeModules map[string]map[string]tengo.Object
 	// The list of standard libraries that a script can have utilize.
 	ValidStandardLibraryNames []string
 }
@@ -92,6 +101,22 @@ func NewEngine() *Engine {
 	}
 }
 
+// Copy returns a new engine with the same settings, libraries, native modules, and dice but none of the cached scripts.
+// Native modules added to or rebound on the copy, such as those an application binds to the state of one game, leave
+// the original engine as it was.
+func (engine *Engine) Copy() *Engine {
+	settings := engine.Settings
+	settings.StandardLibraries = append([]string{}, engine.Settings.StandardLibraries...)
+	settings.ApplicationLibraries = append([]Library{}, engine.Settings.ApplicationLibraries...)
+	settings.ApplicationModules = append([]Module{}, engine.Settings.ApplicationModules...)
+	settings.ValidStandardLibraryNames = append([]string{}, engine.Settings.ValidStandardLibraryNames...)
+	settings.NativeModules = make(map[string]map[string]tengo.Object, len(engine.Settings.NativeModules))
+	for name, attributes := range engine.Settings.NativeModules {
+		settings.NativeModules[name] = attributes
+	}
+	return &Engine{Settings: settings, Dice: engine.Dice}
+}
+
 // ?
 func (importer *LibraryImporter) Get(name string) tengo.Importable {
 	if mod := importer.mods.Get(name); mod != nil {
@@ -129,6 +154,12 @@ func (engine *Engine) InitializeLibraryImporter() {
 	engine.Importer = &LibraryImporter{
 		mods: stdlib.GetModuleMap(engine.Settings.StandardLibraries...),
 		fallback: func(name string) tengo.Importable {
+			// native modules are looked up when imported instead of when the importer is initialized so they can be
+			// added or replaced at any time.
+			if attributes, ok := engine.Settings.NativeModules[name]; ok {
+				return &tengo.BuiltinModule{Attrs: attributes}
+			}
+
 			// Set the source to an empty string to keep from setting things on fire
 			source := ""
 			// loop first over application libraries; if the name specified matches,
@@ -318,6 +349,74 @@ func (engine *Engine) RemoveApplicationModule(moduleName string) error {
 	)
 }
 
+// Returns the list of native modules the engine is currently configured to be able to load
+func (engine *Engine) NativeModuleNames() (names []string) {
+	for name := range engine.Settings.NativeModules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AddNativeModule adds a module written in go to the engine and appends the declaration for using the module to the
+// script header, just like AddStandardLibrary. If a native module with the same name already exists, its attributes
+// are replaced without redeclaring it in the script header; this makes it safe to rebind a native module to new state,
+// such as when an application needs its functions to operate on a different object than before.
+//
+// Because the declaration is only added to the script header for scripts added after the module, you should add any
+// native modules before adding scripts which need them.
+func (engine *Engine) AddNativeModule(name string, attributes map[string]tengo.Object) {
+	if engine.Settings.NativeModules == nil {
+		engine.Settings.NativeModules = make(map[string]map[string]tengo.Object)
+	}
+	if _, exists := engine.Settings.NativeModules[name]; !exists {
+		engine.Settings.ScriptHeader += fmt.Sprintf("%s := import(\"%s\")\n", name, name)
+	}
+	engine.Settings.NativeModules[name] = attributes
+}
+
+// RemoveNativeModule drops the specified native module from the engine's configuration, deleting it from the
+// NativeModules setting and removing its entry from the ScriptHeader.
+func (engine *Engine) RemoveNativeModule(name string) error {
+	if _, exists := engine.Settings.NativeModules[name]; !exists {
+		return fmt.Errorf("unable to remove '%s' as native module; not found in current list: %s",
+			name,
+			engine.NativeModuleNames(),
+		)
+	}
+	delete(engine.Settings.NativeModules, name)
+	scriptLines := strings.Split(engine.Settings.ScriptHeader, "\n")
+	for scriptLineIndex, scriptLine := range scriptLines {
+		if strings.HasPrefix(scriptLine, fmt.Sprintf("%s :=", name)) {
+			engine.Settings.ScriptHeader = strings.Join(utils.RemoveIndex(scriptLines, scriptLineIndex), "\n")
+			break
+		}
+	}
+	return nil
+}
+
+// InitializeDice creates the engine's dice from the RandomSeed setting and makes them available to scripts as the
+// native dice module. If the engine already has dice, it does nothing.
+func (engine *Engine) InitializeDice() {
+	if engine.Dice != nil {
+		return
+	}
+	engine.UseDice(dice.NewRoller(engine.Settings.RandomSeed), nil)
+}
+
+// UseDice replaces the engine's dice with the specified roller and rebinds the native dice module to it, so every
+// roll a script makes comes from the same roller as the application's own rolls. The source function, if specified,
+// names where each roll a script makes came from, such as the script currently running. Like any native module, the
+// dice module should be added before any scripts which need it.
+//
+// For example, to make the rolls for a game reproducible from its seed:
+//
+//    myengine.UseDice(dice.NewRoller(game.Seed), nil)
+func (engine *Engine) UseDice(roller *dice.Roller, source func() string) {
+	engine.Dice = roller
+	engine.AddNativeModule("dice", roller.Module(source))
+}
+
 // Adds a new script to the engine from a given name and script body as string. At the time the script is added, all
 // necessary actions are taken to ensure the script can be run immediately after. This means that you want to be sure
 // to configure the engine with desired libraries and settings before adding any scripts. The script is not
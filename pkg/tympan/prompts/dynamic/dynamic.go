@@ -69,6 +69,26 @@ func (model *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 }
 
+// The Value method returns the answer given to the active prompt: true or false for a confirmation, the value of the
+// chosen choice for a selection, or the string entered for a text input. It returns an error if the prompt has not
+// been answered validly or no prompt is active.
+func (model *Model) Value() (any, error) {
+	switch model.ActiveType {
+	case Confirmation:
+		return model.Confirmation.Value()
+	case Selection:
+		choice, err := model.Selection.Value()
+		if err != nil {
+			return nil, err
+		}
+		return choice.Value, nil
+	case TextInput:
+		return model.TextInput.Value()
+	default:
+		return nil, fmt.Errorf("dynamic prompt (type %s) is not a valid type to answer", model.ActiveType)
+	}
+}
+
 // The New function takes the data for dynamic prompt info and returns a dynamic prompt model configured per the values
 // and options defined in the Info object.
 func New(info Info) *Model {